@@ -1,57 +1,177 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"github.com/khralenok/all-wallets-api/internal/api/handlers"
 	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/api/openapi"
+	"github.com/khralenok/all-wallets-api/internal/api/routes"
+	"github.com/khralenok/all-wallets-api/internal/blob"
+	"github.com/khralenok/all-wallets-api/internal/config"
 	"github.com/khralenok/all-wallets-api/internal/database"
+	"github.com/khralenok/all-wallets-api/internal/events"
+	"github.com/khralenok/all-wallets-api/internal/logging"
+	"github.com/khralenok/all-wallets-api/internal/metrics"
+	"github.com/khralenok/all-wallets-api/internal/password"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+	"github.com/khralenok/all-wallets-api/internal/store"
+	"github.com/khralenok/all-wallets-api/internal/validation"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	cfg, args, err := config.Load(os.Args[1:])
+
+	if err != nil {
+		fatal("Can't load config", err)
+	}
+
+	if len(args) > 0 {
+		fatal("Unexpected arguments", fmt.Errorf("%q", args))
+	}
+
+	if err := cfg.ValidateServer(); err != nil {
+		fatal("Invalid config", err)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.Log.Level)
+
+	if err != nil {
+		fatal("Can't create logger", err)
 	}
 
-	if err := database.Connect(); err != nil {
-		log.Fatal("Database connection failed:", err)
+	slog.SetDefault(logger)
+
+	if err := database.Connect(cfg.Database.DSN()); err != nil {
+		fatal("Database connection failed", err)
 	}
 
 	defer database.DB.Close()
 
-	router := gin.Default()
+	if err := validation.Register(); err != nil {
+		fatal("Validation rules registration failed", err)
+	}
+
+	passwords, err := password.NewHasher(cfg.Password.Hash, cfg.Password.Argon2, cfg.Password.BcryptCost)
+
+	if err != nil {
+		fatal("Can't create password hasher", err)
+	}
+
+	passwordPolicy, err := password.NewPolicy(cfg.Password.MinLength, cfg.Password.MaxBytes, cfg.Password.BreachedList)
+
+	if err != nil {
+		fatal("Can't load password policy", err)
+	}
+
+	appStore := store.New(database.DB)
+	jwt := middleware.NewJWT(cfg.JWT.Secret, cfg.JWT.TTL)
+
+	// Postgres backend shares buckets between API instances
+	var limiter ratelimit.Limiter = ratelimit.NewMemory()
+
+	if cfg.RateLimit.Backend == "postgres" {
+		limiter = appStore.RateLimits
+	}
+
+	broker, err := events.NewBroker(cfg.Database.DSN(), store.WalletEventsChannel, appStore.Events)
+
+	if err != nil {
+		fatal("Can't listen to wallet events", err)
+	}
+
+	go broker.Run(context.Background())
+
+	blobs, err := blob.NewLocal(cfg.Attachments.Dir)
+
+	if err != nil {
+		fatal("Can't open attachments directory", err)
+	}
+
+	h := handlers.New(appStore, jwt, limiter, passwords, passwordPolicy, broker, blobs, cfg)
+	auth := jwt.AuthMiddleware(appStore.Users, appStore.APIKeys)
+	idempotent := middleware.Idempotency(appStore.Idempotency)
+
+	metrics.RegisterServer(database.DB, appStore.ExchangeRates.GetLastFetchTime)
+
+	router := gin.New()
+
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", err)
+	}
+
+	router.Use(middleware.RequestID(), middleware.AccessLog(logger), middleware.Recovery(logger), middleware.Metrics())
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", middleware.RequestIDHeader, middleware.IdempotencyKeyHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader, middleware.IdempotentReplayedHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	//User Management
-	router.POST("/signin", handlers.CreateUser)
-	router.POST("/login", handlers.LoginUser)
-	router.GET("/profile", middleware.AuthMiddleware(), handlers.GetProfile)
-	router.PUT("/delete-user", middleware.AuthMiddleware(), handlers.DeleteUser)
+	routes.Register(router, h, auth, idempotent, limiter, cfg.RateLimit)
 
-	//Wallets Management
-	router.POST("/new-wallet", middleware.AuthMiddleware(), handlers.CreateWallet)
-	router.GET("/wallet/:id", middleware.AuthMiddleware(), handlers.GetWallet)
-	router.DELETE("/delete-wallet/:id", middleware.AuthMiddleware(), handlers.DeleteWallet)
+	if err := openapi.CheckRoutes(router.Routes()); err != nil {
+		fatal("OpenAPI document doesn't match routes", err)
+	}
 
-	//Wallet Users Management
-	router.POST("/share-wallet", middleware.AuthMiddleware(), handlers.CreateWalletUser)
-	router.DELETE("/remove-wallet-user/wallet/:wallet_id/username/:username/", middleware.AuthMiddleware(), handlers.DeleteWalletUser)
+	server := &http.Server{
+		Addr:              cfg.Server.Addr(),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
 
-	//Transactions Management
-	router.POST("/add-income", middleware.AuthMiddleware(), func(context *gin.Context) { handlers.CreateTransaction(context, true) })
-	router.POST("/add-expense", middleware.AuthMiddleware(), func(context *gin.Context) { handlers.CreateTransaction(context, false) })
-	router.GET("/transactions/:wallet_id", middleware.AuthMiddleware(), handlers.GetWalletTransactions)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+
+	go func() {
+		slog.Info("API server listens", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("API server failed", err)
+		}
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining in-flight requests")
+	h.StartDraining()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	// Event streams never finish by themselves, so they are ended before shutdown waits for in-flight requests
+	if err := broker.Close(); err != nil {
+		slog.Error("Can't close wallet events listener", "error", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Graceful shutdown failed", "error", err)
+	}
+}
 
-	router.Run(":8080")
+// Log error and stop process
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,55 @@
+// Command webhook-receiver is local endpoint for trying webhooks out. It checks signature of every delivery, logs it and answers with configured status, so retries can be tried too:
+//
+//	go run ./cmd/webhook-receiver -addr :9090 -secret whsec_... -status 500
+package main
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/webhook"
+)
+
+// Deliveries signed earlier are refused as replayed
+const signatureTolerance = 5 * time.Minute
+
+func main() {
+	addr := flag.String("addr", ":9090", "Address to listen on")
+	secret := flag.String("secret", "", "Secret returned when webhook was created")
+	status := flag.Int("status", http.StatusNoContent, "Status to answer valid deliveries with")
+	flag.Parse()
+
+	if *secret == "" {
+		slog.Error("Secret is required")
+		os.Exit(1)
+	}
+
+	http.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			http.Error(w, "can't read body", http.StatusBadRequest)
+			return
+		}
+
+		if err := webhook.Verify(*secret, r.Header.Get(webhook.SignatureHeader), payload, signatureTolerance, time.Now()); err != nil {
+			slog.Warn("Delivery refused", "delivery_id", r.Header.Get(webhook.DeliveryHeader), "error", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		slog.Info("Delivery received", "delivery_id", r.Header.Get(webhook.DeliveryHeader), "event", r.Header.Get(webhook.EventHeader), "payload", string(payload))
+		w.WriteHeader(*status)
+	})
+
+	slog.Info("Webhook receiver listens", "addr", *addr)
+
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		slog.Error("Webhook receiver failed", "error", err)
+		os.Exit(1)
+	}
+}
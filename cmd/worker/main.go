@@ -1,55 +1,218 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/khralenok/all-wallets-api/internal/blob"
 	"github.com/khralenok/all-wallets-api/internal/commands"
+	"github.com/khralenok/all-wallets-api/internal/config"
 	"github.com/khralenok/all-wallets-api/internal/database"
+	"github.com/khralenok/all-wallets-api/internal/logging"
+	"github.com/khralenok/all-wallets-api/internal/metrics"
+	"github.com/khralenok/all-wallets-api/internal/notify"
+	"github.com/khralenok/all-wallets-api/internal/store"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	// Config flags go before command name, command flags after it
+	cfg, args, err := config.Load(os.Args[1:])
+
+	if err != nil {
+		fatal(context.Background(), "Can't load config", err)
 	}
 
-	if err := database.Connect(); err != nil {
-		log.Fatal("Database connection failed:", err)
+	if err := cfg.Validate(); err != nil {
+		fatal(context.Background(), "Invalid config", err)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.Log.Level)
+
+	if err != nil {
+		fatal(context.Background(), "Can't create logger", err)
+	}
+
+	if len(args) < 1 {
+		fatal(context.Background(), "Expected some command", nil)
+	}
+
+	job, jobArgs := args[0], args[1:]
+
+	// Every record of this run shares run id, the same way API records share request id
+	slog.SetDefault(logger.With("job", job))
+	ctx := logging.WithRequestID(context.Background(), logging.NewID())
+
+	if err := database.Connect(cfg.Database.DSN()); err != nil {
+		fatal(ctx, "Database connection failed", err)
 	}
 
 	defer database.DB.Close()
 
+	appStore := store.New(database.DB)
+
 	snapshotCmd := flag.NewFlagSet("snapshot", flag.ExitOnError)
 	snapshotWalletID := snapshotCmd.Int("id", 0, "Id of wallet you want to make snapshot for")
 
-	switch os.Args[1] {
+	purgeWalletsCmd := flag.NewFlagSet("purge-wallets", flag.ExitOnError)
+	purgeWalletsDays := purgeWalletsCmd.Int("days", int(store.ArchivedWalletRetention.Hours()/24), "Purge wallets archived more than this number of days ago")
+
+	purgeUsersCmd := flag.NewFlagSet("purge-users", flag.ExitOnError)
+	purgeUsersDays := purgeUsersCmd.Int("days", int(store.DeletedUserGracePeriod.Hours()/24), "Purge personal data of users deleted more than this number of days ago")
+
+	purgeRateLimitsCmd := flag.NewFlagSet("purge-rate-limits", flag.ExitOnError)
+	purgeRateLimitsHours := purgeRateLimitsCmd.Int("hours", 24, "Delete rate limit buckets untouched for more than this number of hours")
+
+	purgeIdempotencyKeysCmd := flag.NewFlagSet("purge-idempotency-keys", flag.ExitOnError)
+
+	dispatchWebhooksCmd := flag.NewFlagSet("dispatch-webhooks", flag.ExitOnError)
+	dispatchWebhooksBatch := dispatchWebhooksCmd.Int("batch", 20, "Number of deliveries sent concurrently")
+	dispatchWebhooksTimeout := dispatchWebhooksCmd.Int("timeout", 10, "Seconds receiver has to answer")
+
+	sendNotificationDigestsCmd := flag.NewFlagSet("send-notification-digests", flag.ExitOnError)
+	sendNotificationDigestsBatch := sendNotificationDigestsCmd.Int("batch", 100, "Number of users whose digests are loaded at once")
+
+	purgeWebhookDeliveriesCmd := flag.NewFlagSet("purge-webhook-deliveries", flag.ExitOnError)
+	purgeWebhookDeliveriesDays := purgeWebhookDeliveriesCmd.Int("days", 30, "Delete finished webhook deliveries created more than this number of days ago")
+
+	purgeWalletEventsCmd := flag.NewFlagSet("purge-wallet-events", flag.ExitOnError)
+	purgeWalletEventsHours := purgeWalletEventsCmd.Int("hours", 1, "Delete wallet events recorded more than this number of hours ago")
+
+	purgeAttachmentsCmd := flag.NewFlagSet("purge-attachments", flag.ExitOnError)
+	purgeAttachmentsHours := purgeAttachmentsCmd.Int("hours", 24, "Keep orphaned files younger than this number of hours")
+
+	start := time.Now()
+	slog.InfoContext(ctx, "Job started")
+
+	switch job {
 	case "snapshot":
-		err := commands.UpdateWalletSnapshot(snapshotCmd, snapshotWalletID)
+		err = commands.UpdateWalletSnapshot(ctx, appStore, snapshotCmd, jobArgs, snapshotWalletID)
 
-		if err != nil {
-			fmt.Println("Error: ", err.Error())
-			os.Exit(1)
+		if err == nil {
+			slog.InfoContext(ctx, "Balance was successfuly updated", "wallet_id", *snapshotWalletID, "duration", time.Since(start))
 		}
 
-		fmt.Println("Balance was successfuly updated!")
-		os.Exit(0)
-
 	case "xrates":
-		err := commands.UpdateExchangeRates()
+		err = commands.UpdateExchangeRates(ctx, appStore, cfg.ExchangeRates.AppID)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Exchange rates were successfuly updated", "duration", time.Since(start))
+		}
+
+	case "purge-wallets":
+		var purged int
+		purged, err = commands.PurgeArchivedWallets(ctx, appStore, purgeWalletsCmd, jobArgs, purgeWalletsDays)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Archived wallets were successfuly purged", "purged", purged, "duration", time.Since(start))
+		}
+
+	case "purge-users":
+		var purged int
+		purged, err = commands.PurgeDeletedUsers(ctx, appStore, purgeUsersCmd, jobArgs, purgeUsersDays)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Deleted users were successfuly purged", "purged", purged, "duration", time.Since(start))
+		}
+
+	case "purge-rate-limits":
+		var purged int
+		purged, err = commands.PurgeRateLimits(ctx, appStore, purgeRateLimitsCmd, jobArgs, purgeRateLimitsHours)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Stale rate limit buckets were successfuly purged", "purged", purged, "duration", time.Since(start))
+		}
 
-		if err != nil {
-			fmt.Println("Error: ", err.Error())
-			os.Exit(1)
+	case "purge-idempotency-keys":
+		var purged int
+		purged, err = commands.PurgeIdempotencyKeys(ctx, appStore, purgeIdempotencyKeysCmd, jobArgs)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Expired idempotency keys were successfuly purged", "purged", purged, "duration", time.Since(start))
 		}
 
-		fmt.Println("Exchange rates were successfuly updated!")
-		os.Exit(0)
+	case "dispatch-webhooks":
+		var delivered, failed int
+		delivered, failed, err = commands.DispatchWebhooks(ctx, appStore, cfg.Webhooks.AllowedNetworks, dispatchWebhooksCmd, jobArgs, dispatchWebhooksBatch, dispatchWebhooksTimeout)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Webhook deliveries were dispatched", "delivered", delivered, "failed", failed, "duration", time.Since(start))
+		}
+
+	case "purge-webhook-deliveries":
+		var purged int
+		purged, err = commands.PurgeWebhookDeliveries(ctx, appStore, purgeWebhookDeliveriesCmd, jobArgs, purgeWebhookDeliveriesDays)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Finished webhook deliveries were successfuly purged", "purged", purged, "duration", time.Since(start))
+		}
+
+	case "purge-wallet-events":
+		var purged int
+		purged, err = commands.PurgeWalletEvents(ctx, appStore, purgeWalletEventsCmd, jobArgs, purgeWalletEventsHours)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Wallet events were successfuly purged", "purged", purged, "duration", time.Since(start))
+		}
+
+	case "send-notification-digests":
+		notifier, closeNotifier, notifierErr := notify.New(cfg.Notifications)
+
+		if notifierErr != nil {
+			fatal(ctx, "Can't create notifier", notifierErr)
+		}
+
+		var sent, failed int
+		sent, failed, err = commands.SendNotificationDigests(ctx, appStore, notifier, sendNotificationDigestsCmd, jobArgs, sendNotificationDigestsBatch)
+
+		if closeErr := closeNotifier(); closeErr != nil {
+			slog.WarnContext(ctx, "Can't close notifier", "error", closeErr)
+		}
+
+		if err == nil {
+			slog.InfoContext(ctx, "Notification digests were sent", "sent", sent, "failed", failed, "duration", time.Since(start))
+		}
+
+	case "purge-attachments":
+		blobs, blobsErr := blob.NewLocal(cfg.Attachments.Dir)
+
+		if blobsErr != nil {
+			fatal(ctx, "Can't open attachments directory", blobsErr)
+		}
+
+		var purged int
+		purged, err = commands.PurgeOrphanedAttachments(ctx, appStore, blobs, purgeAttachmentsCmd, jobArgs, purgeAttachmentsHours)
+
+		if err == nil {
+			slog.InfoContext(ctx, "Orphaned attachment files were successfuly purged", "purged", purged, "duration", time.Since(start))
+		}
 
 	default:
-		fmt.Println("expected some command")
-		os.Exit(1)
+		fatal(ctx, "Unknown command", nil)
+	}
+
+	metrics.ObserveJob(job, time.Since(start), err)
+
+	if cfg.Metrics.PushgatewayURL != "" {
+		if pushErr := metrics.Push(ctx, cfg.Metrics.PushgatewayURL, job); pushErr != nil {
+			slog.WarnContext(ctx, "Can't push job metrics", "error", pushErr)
+		}
+	}
+
+	if err != nil {
+		fatal(ctx, "Job failed", err)
 	}
 }
+
+// Log error and stop process. Deferred calls don't run, so connection is closed by OS.
+func fatal(ctx context.Context, msg string, err error) {
+	if err != nil {
+		slog.ErrorContext(ctx, msg, "error", err)
+	} else {
+		slog.ErrorContext(ctx, msg)
+	}
+
+	os.Exit(1)
+}
@@ -8,21 +8,46 @@ CREATE TABLE currency_metadata (
 
 CREATE TABLE users (
   id SERIAL PRIMARY KEY,
-  username TEXT UNIQUE NOT NULL,
+  username TEXT NOT NULL,
   user_pwd TEXT NOT NULL,
   base_currency TEXT NOT NULL,
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   is_deleted BOOLEAN DEFAULT FALSE,
-  deleted_at TIMESTAMP DEFAULT NULL
+  deleted_at TIMESTAMP DEFAULT NULL,
+  token_version INT NOT NULL DEFAULT 0, -- bumped to revoke all previously issued tokens
+  purged_at TIMESTAMP DEFAULT NULL -- personal data was erased after deletion grace period
 );
 
+-- Username of deleted user can be taken again
+CREATE UNIQUE INDEX idx_active_usernames ON users(username) WHERE is_deleted = FALSE;
+
+CREATE TABLE user_two_factor (
+  user_id INT PRIMARY KEY REFERENCES users(id),
+  totp_secret TEXT NOT NULL,
+  is_enabled BOOLEAN NOT NULL DEFAULT FALSE, -- FALSE until enrollment is confirmed with a code
+  last_used_step BIGINT, -- codes of this or earlier time steps are refused, so a code can't be replayed
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE recovery_codes (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id),
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
+
 CREATE TABLE wallets (
   id SERIAL PRIMARY KEY,
   wallet_name TEXT NOT NULL,
   currency TEXT NOT NULL,
   balance INT NOT NULL DEFAULT 0,
   last_snapshot TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  is_archived BOOLEAN DEFAULT FALSE,
+  archived_at TIMESTAMP DEFAULT NULL,
+  require_2fa BOOLEAN NOT NULL DEFAULT FALSE -- members must log in with second factor to access the wallet
 );
 
 CREATE TABLE wallet_users (
@@ -37,11 +62,62 @@ CREATE TABLE transactions (
   amount INT NOT NULL,
   is_deposit BOOLEAN NOT NULL,
   category TEXT NOT NULL, 
+  description TEXT NOT NULL DEFAULT '',
+  payee TEXT NOT NULL DEFAULT '',
+  -- Description, payee, category and tags for full-text search, refreshed by app whenever they change
+  search_vector TSVECTOR NOT NULL DEFAULT '',
   wallet_id INT REFERENCES wallets(id),
   creator_id INT REFERENCES users(id),
-  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+  -- Insertion time. Snapshots fold transactions by it, so backdated ones are still counted
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  -- When money actually moved, chosen by user. Reports and search use it
+  occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+CREATE INDEX idx_transactions_wallet_created ON transactions(wallet_id, created_at);
+CREATE INDEX idx_transactions_wallet_occurred ON transactions(wallet_id, occurred_at);
+CREATE INDEX idx_transactions_search ON transactions USING GIN (search_vector);
+
+-- Tags are free-form labels, names are unique within wallet
+CREATE TABLE tags (
+  id SERIAL PRIMARY KEY,
+  wallet_id INT NOT NULL REFERENCES wallets(id),
+  name TEXT NOT NULL,
+  UNIQUE (wallet_id, name)
+);
+
+CREATE TABLE transaction_tags (
+  transaction_id INT NOT NULL REFERENCES transactions(id),
+  tag_id INT NOT NULL REFERENCES tags(id),
+  PRIMARY KEY (transaction_id, tag_id)
+);
+
+CREATE INDEX idx_transaction_tags_tag ON transaction_tags(tag_id);
+
+-- Breakdown of transaction by categories, amounts sum up to transaction amount. Transaction without splits belongs to its own category only
+CREATE TABLE transaction_splits (
+  id SERIAL PRIMARY KEY,
+  transaction_id INT NOT NULL REFERENCES transactions(id),
+  category TEXT NOT NULL,
+  amount INT NOT NULL CHECK (amount > 0)
 );
 
+CREATE INDEX idx_transaction_splits_transaction ON transaction_splits(transaction_id);
+
+-- Files themselves are kept in blob store under storage_key
+CREATE TABLE transaction_attachments (
+  id SERIAL PRIMARY KEY,
+  transaction_id INT NOT NULL REFERENCES transactions(id),
+  uploader_id INT NOT NULL REFERENCES users(id),
+  file_name TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  storage_key TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_transaction_attachments_transaction ON transaction_attachments(transaction_id);
 
 CREATE TABLE exchange_rates (
   from_currency TEXT,
@@ -69,6 +145,112 @@ CREATE TABLE recurrent_payments (
 
 CREATE INDEX idx_next_recurrent_payments ON recurrent_payments(next_run);
 
+CREATE TABLE api_keys (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id),
+  key_name TEXT NOT NULL,
+  key_id TEXT NOT NULL UNIQUE, -- public part of the key used to find it
+  key_hash TEXT NOT NULL,
+  scopes TEXT[] NOT NULL,
+  wallet_ids INT[] NOT NULL DEFAULT '{}', -- empty means all wallets of the user
+  two_factor BOOLEAN NOT NULL DEFAULT FALSE,
+  expires_at TIMESTAMP DEFAULT NULL,
+  last_used_at TIMESTAMP DEFAULT NULL,
+  revoked_at TIMESTAMP DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys(user_id);
+
+CREATE TABLE idempotency_keys (
+  user_id INT NOT NULL REFERENCES users(id),
+  idempotency_key TEXT NOT NULL,
+  request_hash TEXT NOT NULL,
+  status_code INT DEFAULT NULL, -- NULL while the first request is being served
+  content_type TEXT DEFAULT NULL,
+  response_body BYTEA DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, idempotency_key)
+);
+
+-- Events streamed to API instances. NOTIFY carries only event id, because Postgres limits its payload to 8000 bytes, and instances load event from here. Rows are needed only for a moment and are purged by the worker
+CREATE TABLE wallet_events (
+  id BIGSERIAL PRIMARY KEY,
+  wallet_id INT NOT NULL, -- no foreign key, event about permanently deleted wallet outlives it
+  payload TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_wallet_events_created ON wallet_events(created_at);
+
+CREATE TABLE webhooks (
+  id SERIAL PRIMARY KEY,
+  wallet_id INT NOT NULL REFERENCES wallets(id),
+  creator_id INT NOT NULL REFERENCES users(id),
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL, -- kept as is, payloads are signed with it
+  event_types TEXT[] NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhooks_wallet ON webhooks(wallet_id);
+
+-- Outbox: rows are inserted in the same DB transaction as the change they are about and sent by the worker
+CREATE TABLE webhook_deliveries (
+  id BIGSERIAL PRIMARY KEY,
+  webhook_id INT NOT NULL REFERENCES webhooks(id),
+  event_type TEXT NOT NULL,
+  payload TEXT NOT NULL, -- exact bytes that are signed and sent
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- pushed forward while worker sends it, so other workers skip it
+  last_status_code INT DEFAULT NULL,
+  last_error TEXT DEFAULT NULL,
+  delivered_at TIMESTAMP DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at);
+
+CREATE TABLE notifications (
+  id BIGSERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id),
+  kind TEXT NOT NULL,
+  wallet_id INT REFERENCES wallets(id),
+  message TEXT NOT NULL,
+  data JSONB NOT NULL DEFAULT '{}',
+  read_at TIMESTAMP DEFAULT NULL,
+  emailed_at TIMESTAMP DEFAULT NULL, -- set once notification was sent in digest
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, id);
+CREATE INDEX idx_notifications_digest ON notifications(user_id) WHERE read_at IS NULL AND emailed_at IS NULL;
+
+-- Users without row get defaults: every kind in app, no email, no large expense alerts
+CREATE TABLE notification_preferences (
+  user_id INT PRIMARY KEY REFERENCES users(id),
+  email TEXT DEFAULT NULL,
+  email_digest BOOLEAN NOT NULL DEFAULT FALSE,
+  muted_kinds TEXT[] NOT NULL DEFAULT '{}',
+  large_expense_threshold NUMERIC(30, 12) DEFAULT NULL, -- in user base currency
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE rate_limit_buckets (
+  bucket_key TEXT PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE login_failures (
+  username TEXT PRIMARY KEY, -- not a reference, unknown usernames are locked the same way
+  failures INT NOT NULL DEFAULT 0,
+  last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  locked_until TIMESTAMP
+);
+
 INSERT INTO currency_metadata (code, name, type, decimal_places, symbol) VALUES
 ('USD', 'US Dollar', 'fiat', 2, '$'),
 ('KZT', 'Kazakhstani Tenge', 'fiat', 0, '₸'),
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/apikey"
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Create API key for scripts. Key may be restricted to some wallets of the user, it's shown only once and can't be recovered.
+func (h *Handler) CreateAPIKey(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.NewAPIKeyInput
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	for _, walletID := range input.WalletIDs {
+		if err := h.store.WalletUsers.CheckMember(ctx, userID, walletID); err != nil {
+			respondError(context, err, "Failed to fetch supplicant user data")
+			return
+		}
+	}
+
+	key, keyID, keyHash, err := apikey.Generate()
+
+	if err != nil {
+		respondError(context, err, "Can't generate API key")
+		return
+	}
+
+	newKey := models.APIKey{
+		UserID:    userID,
+		Name:      strings.TrimSpace(input.Name),
+		KeyID:     keyID,
+		Scopes:    input.Scopes,
+		WalletIDs: input.WalletIDs,
+		TwoFactor: context.GetBool("twoFactor"),
+	}
+
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		newKey.ExpiresAt = &expiresAt
+	}
+
+	created, err := h.store.APIKeys.Create(ctx, newKey, keyHash)
+
+	if err != nil {
+		respondError(context, err, "Can't save API key")
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"api_key": created, "key": key})
+}
+
+// Response with all API keys of the user that weren't revoked. Keys themselves are never shown again.
+func (h *Handler) GetAPIKeys(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	keys, err := h.store.APIKeys.GetByUser(context.Request.Context(), userID)
+
+	if err != nil {
+		respondError(context, err, "Can't get API keys from database")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// Revoke API key, requests made with it are rejected right away
+func (h *Handler) RevokeAPIKey(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	keyID, err := strconv.Atoi(context.Param("id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	if err := h.store.APIKeys.Revoke(context.Request.Context(), userID, keyID); err != nil {
+		respondError(context, err, "Can't revoke API key")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "message": "API key was revoked"})
+}
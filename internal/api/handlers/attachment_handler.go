@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/blob"
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Content types attachments may have, as detected from file content
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"image/gif":       true,
+	"application/pdf": true,
+}
+
+// Longer file names are cut, they are kept only to be suggested on download
+const maxAttachmentNameLength = 255
+
+// Multipart headers and boundaries on top of file itself
+const multipartOverhead = 64 << 10
+
+// Stop reading upload after attachment size limit. Must be placed before middlewares reading body, so they don't buffer more than the limit either.
+func (h *Handler) LimitAttachmentSize(context *gin.Context) {
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, int64(h.cfg.Attachments.MaxSize)+multipartOverhead)
+	context.Next()
+}
+
+// Attach receipt or other file to transaction. Body size is limited by LimitAttachmentSize. File is sent as multipart form field "file", its type is detected from content. Can be performed only by wallet admin.
+func (h *Handler) UploadAttachment(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, transactionID, ok := transactionParams(context)
+
+	if !ok {
+		return
+	}
+
+	if !h.checkWalletAdmin(context, userID, walletID) {
+		return
+	}
+
+	maxSize := int64(h.cfg.Attachments.MaxSize)
+
+	fileHeader, err := context.FormFile("file")
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) || (err == nil && fileHeader.Size > maxSize) {
+		problem.Respond(context, http.StatusRequestEntityTooLarge, problem.CodeAttachmentTooLarge, fmt.Sprintf("Attachment can't be larger than %d bytes", maxSize))
+		return
+	}
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Attachment must be sent as multipart form field file")
+		return
+	}
+
+	file, err := fileHeader.Open()
+
+	if err != nil {
+		respondError(context, err, "Can't read attachment")
+		return
+	}
+
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		respondError(context, err, "Can't read attachment")
+		return
+	}
+
+	// Parameters like charset are irrelevant for allowed types
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+
+	if !attachmentTypes[contentType] {
+		problem.Respond(context, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType, "Attachment must be JPEG, PNG, WebP or GIF image or PDF document")
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondError(context, err, "Can't read attachment")
+		return
+	}
+
+	key, err := attachmentKey(walletID)
+
+	if err != nil {
+		respondError(context, err, "Can't generate attachment key")
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if err := h.blobs.Put(ctx, key, file); err != nil {
+		respondError(context, err, "Can't save attachment")
+		return
+	}
+
+	newAttachment := models.Attachment{
+		TransactionID: transactionID,
+		UploaderID:    userID,
+		FileName:      attachmentName(fileHeader.Filename),
+		ContentType:   contentType,
+		Size:          fileHeader.Size,
+		StorageKey:    key,
+	}
+
+	created, err := h.store.Attachments.Create(ctx, walletID, newAttachment)
+
+	if err != nil {
+		h.deleteBlob(context, key)
+		respondError(context, err, "Can't save attachment")
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"attachment": created})
+}
+
+// Response with attachment file. Can be performed by any wallet user.
+func (h *Handler) DownloadAttachment(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, transactionID, ok := transactionParams(context)
+
+	if !ok {
+		return
+	}
+
+	attachmentID, err := strconv.Atoi(context.Param("attachment_id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if !h.checkWalletMember(context, userID, walletID) {
+		return
+	}
+
+	attachment, err := h.store.Attachments.Get(ctx, walletID, transactionID, attachmentID)
+
+	if err != nil {
+		respondError(context, err, "Can't get attachment from database")
+		return
+	}
+
+	content, err := h.blobs.Open(ctx, attachment.StorageKey)
+
+	if errors.Is(err, blob.ErrNotFound) {
+		problem.Respond(context, http.StatusNotFound, problem.CodeNotFound, "Attachment file is missing")
+		return
+	} else if err != nil {
+		respondError(context, err, "Can't read attachment")
+		return
+	}
+
+	defer content.Close()
+
+	headers := map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+	}
+
+	context.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, headers)
+}
+
+// Delete attachment with its file. Can be performed only by wallet admin.
+func (h *Handler) DeleteAttachment(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, transactionID, ok := transactionParams(context)
+
+	if !ok {
+		return
+	}
+
+	attachmentID, err := strconv.Atoi(context.Param("attachment_id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	if !h.checkWalletAdmin(context, userID, walletID) {
+		return
+	}
+
+	deleted, err := h.store.Attachments.Delete(context.Request.Context(), walletID, transactionID, attachmentID)
+
+	if err != nil {
+		respondError(context, err, "Can't delete attachment")
+		return
+	}
+
+	h.deleteBlob(context, deleted.StorageKey)
+
+	context.Status(http.StatusNoContent)
+}
+
+// Delete file no attachment refers to. Failure is only logged, worker purges orphaned files anyway.
+func (h *Handler) deleteBlob(context *gin.Context, key string) {
+	ctx := context.Request.Context()
+
+	if err := h.blobs.Delete(ctx, key); err != nil {
+		slog.WarnContext(ctx, "Can't delete attachment file", "key", key, "error", err)
+	}
+}
+
+// Return wallet and transaction ids from path. Otherwise respond with problem.
+func transactionParams(context *gin.Context) (int, int, bool) {
+	walletID, err := strconv.Atoi(context.Param("id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return 0, 0, false
+	}
+
+	transactionID, err := strconv.Atoi(context.Param("transaction_id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return 0, 0, false
+	}
+
+	return walletID, transactionID, true
+}
+
+// Return new random blob key for attachment of the wallet. Keys are grouped by wallet, so files of one wallet can be found on disk.
+func attachmentKey(walletID int) (string, error) {
+	random := make([]byte, 16)
+
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("wallets/%d/%s", walletID, hex.EncodeToString(random)), nil
+}
+
+// Return base name of uploaded file without path clients may send, cut to maxAttachmentNameLength characters
+func attachmentName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+
+	if name == "." || name == "/" || !utf8.ValidString(name) {
+		return "attachment"
+	}
+
+	if utf8.RuneCountInString(name) > maxAttachmentNameLength {
+		name = string([]rune(name)[:maxAttachmentNameLength])
+	}
+
+	return name
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Response with all currencies wallets and users can use
+func (h *Handler) GetCurrencies(context *gin.Context) {
+	currencies, err := h.store.Currencies.GetAll(context.Request.Context())
+
+	if err != nil {
+		respondError(context, err, "Can't get currency metadata")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"currencies": currencies})
+}
+
+// Response with latest exchange rate between currencies provided via url query, e.g. ?from=USD&to=KZT
+func (h *Handler) GetExchangeRate(context *gin.Context) {
+	var input models.ExchangeRateQuery
+
+	if err := context.ShouldBindQuery(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	exchangeRate, err := h.store.ExchangeRates.Get(context.Request.Context(), input.From, input.To)
+
+	if err != nil {
+		respondError(context, err, "Can't get exchange rate")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"exchange_rate": exchangeRate})
+}
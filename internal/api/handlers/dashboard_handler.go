@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/logic"
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Response with user total balance and every wallet user participates in with its balance, last activity, income and expense of current month and member count
+func (h *Handler) GetDashboard(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	ctx := context.Request.Context()
+
+	user, err := h.store.Users.GetByID(ctx, userID)
+
+	if err != nil {
+		respondError(context, err, "Can't get user data from database")
+		return
+	}
+
+	monthStart := logic.MonthStart(time.Now())
+
+	wallets, err := h.store.Wallets.GetDashboard(ctx, user, monthStart)
+
+	if err != nil {
+		respondError(context, err, "Can't get wallets list from database")
+		return
+	}
+
+	userOutput := models.UserOutput{ID: user.ID, Username: user.Username, BaseCurrency: user.BaseCurrency}
+	var rawBalance float64
+
+	for _, wallet := range wallets {
+		nextBalance, err := strconv.ParseFloat(wallet.UserCurrencyBalance, 64)
+
+		if err != nil {
+			respondError(context, err, "Can't calculate user balance")
+			return
+		}
+
+		rawBalance += nextBalance
+	}
+
+	userOutput.Balance = strconv.FormatFloat(rawBalance, 'f', -1, 64)
+
+	context.JSON(http.StatusOK, gin.H{"user": userOutput, "month_start": monthStart, "wallets": wallets})
+}
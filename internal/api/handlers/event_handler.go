@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/logic"
+	"github.com/khralenok/all-wallets-api/internal/metrics"
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// How often comment is sent to idle stream, so proxies don't close it
+const eventStreamHeartbeat = 15 * time.Second
+
+// Stream wallet events to wallet member as server-sent events. Stream ends when member is removed, wallet is archived or server shuts down, client is expected to reconnect in the last case.
+func (h *Handler) StreamWalletEvents(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, err := strconv.Atoi(context.Param("id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if !h.checkWalletMember(context, userID, walletID) {
+		return
+	}
+
+	events, unsubscribe := h.broker.Subscribe(walletID)
+	defer unsubscribe()
+
+	// Server write timeout is meant for regular requests, stream has to outlive it
+	if err := http.NewResponseController(context.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(ctx, "Can't disable write deadline for event stream", "error", err)
+	}
+
+	metrics.EventStreams.Inc()
+	defer metrics.EventStreams.Dec()
+
+	context.Header("Content-Type", "text/event-stream")
+	context.Header("Cache-Control", "no-cache")
+	context.Header("X-Accel-Buffering", "no")
+	context.Status(http.StatusOK)
+	fmt.Fprint(context.Writer, ": connected\n\n")
+	context.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			context.SSEvent(event.Type, event)
+			context.Writer.Flush()
+
+			if event.Type == models.EventWalletArchived || (event.Type == models.EventMemberRemoved && eventUserID(event) == userID) {
+				return
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(context.Writer, ": heartbeat\n\n")
+			context.Writer.Flush()
+		}
+	}
+}
+
+// Notify wallet members and webhooks about event derived from already saved change, so failure is only logged. Events about changes themselves are recorded by store together with the change.
+func (h *Handler) publish(context *gin.Context, walletID int, eventType string, data map[string]any) {
+	event := models.WalletEvent{Type: eventType, WalletID: walletID, Data: data}
+
+	if err := h.store.Events.Publish(context.Request.Context(), event); err != nil {
+		slog.ErrorContext(context.Request.Context(), "Can't publish wallet event", "error", err, "type", eventType, "wallet_id", walletID)
+	}
+}
+
+// Notify wallet members about new wallet balance
+func (h *Handler) publishBalance(context *gin.Context, walletID, decimalPlaces int) {
+	balance, err := h.store.Wallets.GetBalance(context.Request.Context(), walletID)
+
+	if err != nil {
+		slog.ErrorContext(context.Request.Context(), "Can't get wallet balance for event", "error", err, "wallet_id", walletID)
+		return
+	}
+
+	h.publish(context, walletID, models.EventBalanceUpdated, map[string]any{"balance": logic.FormatOutputValue(balance, decimalPlaces)})
+}
+
+// Return id of user event is about. Event travels as JSON, so numbers come back as float64.
+func eventUserID(event models.WalletEvent) int {
+	userID, _ := event.Data["user_id"].(float64)
+
+	return int(userID)
+}
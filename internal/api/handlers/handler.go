@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/blob"
+	"github.com/khralenok/all-wallets-api/internal/config"
+	"github.com/khralenok/all-wallets-api/internal/events"
+	"github.com/khralenok/all-wallets-api/internal/password"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Handler serves HTTP API on top of store repositories.
+type Handler struct {
+	store          *store.Store
+	jwt            *middleware.JWT
+	limiter        ratelimit.Limiter
+	passwords      *password.Hasher
+	passwordPolicy *password.Policy
+	cfg            config.Config
+	broker         *events.Broker
+	blobs          blob.BlobStore
+	// Set once server starts shutting down, so readiness probe fails while in-flight requests are drained
+	draining atomic.Bool
+}
+
+// Constructor function for handler
+func New(store *store.Store, jwt *middleware.JWT, limiter ratelimit.Limiter, passwords *password.Hasher, passwordPolicy *password.Policy, broker *events.Broker, blobs blob.BlobStore, cfg config.Config) *Handler {
+	return &Handler{store: store, jwt: jwt, limiter: limiter, passwords: passwords, passwordPolicy: passwordPolicy, broker: broker, blobs: blobs, cfg: cfg}
+}
+
+// Mapping of domain errors to problems. More specific errors must go before the generic kinds they wrap.
+var storeErrors = []struct {
+	err    error
+	status int
+	code   string
+	detail string
+}{
+	{store.ErrUserNotFound, http.StatusNotFound, problem.CodeUserNotFound, "There is no such user"},
+	{store.ErrWalletNotFound, http.StatusNotFound, problem.CodeWalletNotFound, "There is no such wallet"},
+	{store.ErrNotWalletUser, http.StatusForbidden, problem.CodeForbidden, "You are not a user of this wallet"},
+	{store.ErrNotWalletAdmin, http.StatusForbidden, problem.CodeForbidden, "You have not enough permissions for this action"},
+	{store.ErrUsernameTaken, http.StatusConflict, problem.CodeUsernameTaken, "This username already taken"},
+	{store.ErrAlreadyWalletUser, http.StatusConflict, problem.CodeAlreadyWalletUser, "This user already have access to this wallet"},
+	{store.ErrInvalidHandover, http.StatusBadRequest, problem.CodeInvalidHandover, "New admin must be another user of this wallet"},
+	{store.ErrTwoFactorEnabled, http.StatusConflict, problem.CodeTwoFactorEnabled, "Second factor is already enabled"},
+	{store.ErrTwoFactorNotFound, http.StatusNotFound, problem.CodeNotFound, "Second factor enrollment wasn't started"},
+	{store.ErrAPIKeyNotFound, http.StatusNotFound, problem.CodeNotFound, "There is no such API key"},
+	{store.ErrWebhookNotFound, http.StatusNotFound, problem.CodeNotFound, "There is no such webhook"},
+	{store.ErrNotificationNotFound, http.StatusNotFound, problem.CodeNotFound, "There is no such notification"},
+	{store.ErrTransactionNotFound, http.StatusNotFound, problem.CodeNotFound, "There is no such transaction"},
+	{store.ErrAttachmentNotFound, http.StatusNotFound, problem.CodeNotFound, "There is no such attachment"},
+	{store.ErrInsufficientFunds, http.StatusConflict, problem.CodeInsufficientFunds, "Insufficient funds. You cannot spend more than your current wallet balance."},
+	{store.ErrNotFound, http.StatusNotFound, problem.CodeNotFound, "Requested resource doesn't exist"},
+	{store.ErrForbidden, http.StatusForbidden, problem.CodeForbidden, "You have not enough permissions for this action"},
+	{store.ErrConflict, http.StatusConflict, problem.CodeConflict, "Request conflicts with current state"},
+}
+
+// Write problem matching the store error to response. Unexpected errors are reported as internal with provided detail, never exposing the error itself, which is logged instead.
+func respondError(context *gin.Context, err error, internalDetail string) {
+	for _, known := range storeErrors {
+		if errors.Is(err, known.err) {
+			problem.Respond(context, known.status, known.code, known.detail)
+			return
+		}
+	}
+
+	slog.ErrorContext(context.Request.Context(), internalDetail, "error", err, "route", context.FullPath())
+	problem.Internal(context, internalDetail)
+}
+
+// Bind JSON body of request about a wallet. If route has wallet id in path, like /api/v1/wallets/:id/..., it replaces wallet id from body, so v1 clients don't have to repeat it.
+func bindWalletInput(context *gin.Context, input any, walletID *int) error {
+	if context.Request.Body == nil {
+		return errors.New("empty request body")
+	}
+
+	if err := json.NewDecoder(context.Request.Body).Decode(input); err != nil {
+		return err
+	}
+
+	if param := context.Param("id"); param != "" {
+		id, err := strconv.Atoi(param)
+
+		if err != nil {
+			return err
+		}
+
+		*walletID = id
+	}
+
+	return binding.Validator.ValidateStruct(input)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Respond with provided error and return decoded problem
+func respondWith(t *testing.T, err error) (*httptest.ResponseRecorder, problem.Problem) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(http.MethodGet, "/api/v1/wallets/1", nil)
+
+	respondError(context, err, "Can't fetch wallet data")
+
+	var body struct {
+		Status int    `json:"status"`
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	}
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Can't decode problem %s: %v", recorder.Body, err)
+	}
+
+	return recorder, problem.Problem{Status: body.Status, Code: body.Code, Detail: body.Detail}
+}
+
+func TestRespondErrorMapsStoreErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, known := range storeErrors {
+		// Store wraps domain errors with details, mapping must see through them
+		for _, err := range []error{known.err, fmt.Errorf("%w: wallet 1", known.err)} {
+			t.Run(err.Error(), func(t *testing.T) {
+				recorder, p := respondWith(t, err)
+
+				if recorder.Code != known.status || p.Status != known.status || p.Code != known.code {
+					t.Errorf("response = %d %d %q, want %d %q", recorder.Code, p.Status, p.Code, known.status, known.code)
+				}
+
+				if contentType := recorder.Header().Get("Content-Type"); contentType != problem.ContentType {
+					t.Errorf("Content-Type = %q, want %q", contentType, problem.ContentType)
+				}
+			})
+		}
+	}
+}
+
+func TestRespondErrorFallsBackToKind(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("budget %w", store.ErrNotFound), http.StatusNotFound, problem.CodeNotFound},
+		{fmt.Errorf("budget: %w", store.ErrForbidden), http.StatusForbidden, problem.CodeForbidden},
+		{fmt.Errorf("budget: %w", store.ErrConflict), http.StatusConflict, problem.CodeConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err.Error(), func(t *testing.T) {
+			recorder, p := respondWith(t, test.err)
+
+			if recorder.Code != test.status || p.Code != test.code {
+				t.Errorf("response = %d %q, want %d %q", recorder.Code, p.Code, test.status, test.code)
+			}
+		})
+	}
+}
+
+func TestRespondErrorHidesUnexpectedErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder, p := respondWith(t, errors.New("pq: password authentication failed for user wallets"))
+
+	if recorder.Code != http.StatusInternalServerError || p.Code != problem.CodeInternal {
+		t.Errorf("response = %d %q, want %d %q", recorder.Code, p.Code, http.StatusInternalServerError, problem.CodeInternal)
+	}
+
+	if strings.Contains(recorder.Body.String(), "pq:") || p.Detail != "Can't fetch wallet data" {
+		t.Errorf("detail = %q, want internal detail without the error", p.Detail)
+	}
+}
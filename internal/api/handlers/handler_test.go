@@ -0,0 +1,319 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/handlers"
+	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/api/routes"
+	"github.com/khralenok/all-wallets-api/internal/config"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/password"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+	"github.com/khralenok/all-wallets-api/internal/store"
+	"github.com/khralenok/all-wallets-api/internal/store/storetest"
+	"github.com/khralenok/all-wallets-api/internal/validation"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	if err := validation.Register(); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+// API served by real routes on top of in-memory store
+type testServer struct {
+	router    *gin.Engine
+	store     *store.Store
+	jwt       *middleware.JWT
+	passwords *password.Hasher
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	return newTestServerWithConfig(t, config.Default())
+}
+
+func newTestServerWithConfig(t *testing.T, cfg config.Config) *testServer {
+	t.Helper()
+
+	appStore := storetest.New()
+	jwt := middleware.NewJWT("test-secret", time.Hour)
+	limiter := ratelimit.NewMemory()
+
+	// Minimal cost keeps tests fast, login compares hashes made with the same one
+	passwords, err := password.NewHasher(password.Bcrypt, cfg.Password.Argon2, bcrypt.MinCost)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passwordPolicy, err := password.NewPolicy(cfg.Password.MinLength, cfg.Password.MaxBytes, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := handlers.New(appStore, jwt, limiter, passwords, passwordPolicy, nil, nil, cfg)
+
+	router := gin.New()
+	routes.Register(router, h, jwt.AuthMiddleware(appStore.Users, appStore.APIKeys), middleware.Idempotency(appStore.Idempotency), limiter, cfg.RateLimit)
+
+	return &testServer{router: router, store: appStore, jwt: jwt, passwords: passwords}
+}
+
+// Create user and return token to authenticate with
+func (s *testServer) createUser(t *testing.T, username, userPassword string) (int, string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	hash, err := s.passwords.Hash(userPassword)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.store.Users.Create(ctx, username, hash, "USD"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := s.store.Users.GetIDByUsername(ctx, username)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := s.jwt.Generate(userID, 0, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return userID, token
+}
+
+// Create wallet administered by the user
+func (s *testServer) createWallet(t *testing.T, userID int, currency string) int {
+	t.Helper()
+
+	ctx := context.Background()
+
+	wallet, err := s.store.Wallets.Create(ctx, models.NewWalletRequest{WalletName: "Family", Currency: currency})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.store.WalletUsers.Add(ctx, wallet.ID, userID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	return wallet.ID
+}
+
+func (s *testServer) do(method, path, token, body string) *httptest.ResponseRecorder {
+	return s.doFrom("192.0.2.1", method, path, token, body)
+}
+
+// Send request from provided client IP
+func (s *testServer) doFrom(ip, method, path, token, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.RemoteAddr = ip + ":1234"
+	request.Header.Set("Content-Type", "application/json")
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestProblemResponses(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.createUser(t, "alice", "correct horse battery")
+	spectatorID, spectatorToken := s.createUser(t, "bob", "correct horse battery")
+	_, strangerToken := s.createUser(t, "mallory", "correct horse battery")
+	walletID := s.createWallet(t, userID, "USD")
+
+	if _, err := s.store.WalletUsers.Add(context.Background(), walletID, spectatorID, "spectator"); err != nil {
+		t.Fatal(err)
+	}
+
+	transactionsPath := fmt.Sprintf("/api/v1/wallets/%d/transactions", walletID)
+	membersPath := fmt.Sprintf("/api/v1/wallets/%d/members", walletID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		code   string
+		// Field errors as JSON, empty if problem must have none
+		errors string
+	}{
+		{
+			name:   "invalid fields",
+			method: http.MethodPost,
+			path:   "/api/v1/wallets",
+			body:   `{"wallet_name": "", "currency": "usd"}`,
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeValidationFailed,
+			errors: `[{"field":"wallet_name","rule":"required"},{"field":"currency","rule":"currency"}]`,
+		},
+		{
+			name:   "invalid nested fields",
+			method: http.MethodPost,
+			path:   transactionsPath,
+			body:   `{"type": "expense", "amount": 2, "category": "Food", "payee": "` + strings.Repeat("x", 101) + `", "splits": [{"category": "Food", "amount": 1}, {"category": "", "amount": 1}]}`,
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeValidationFailed,
+			errors: `[{"field":"payee","rule":"max","param":"100"},{"field":"splits[1].category","rule":"required"}]`,
+		},
+		{
+			name:   "malformed body",
+			method: http.MethodPost,
+			path:   "/api/v1/wallets",
+			body:   `{"wallet_name": "Trip",`,
+			status: http.StatusBadRequest,
+			code:   problem.CodeInvalidInput,
+		},
+		{
+			name:   "unknown currency",
+			method: http.MethodPost,
+			path:   "/api/v1/wallets",
+			body:   `{"wallet_name": "Trip", "currency": "XXX"}`,
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeUnsupportedCurrency,
+		},
+		{
+			name:   "expense above balance",
+			method: http.MethodPost,
+			path:   transactionsPath,
+			body:   `{"type": "expense", "amount": 10, "category": "Food"}`,
+			status: http.StatusConflict,
+			code:   problem.CodeInsufficientFunds,
+		},
+		{
+			name:   "missing wallet",
+			method: http.MethodGet,
+			path:   "/api/v1/wallets/999",
+			status: http.StatusNotFound,
+			code:   problem.CodeWalletNotFound,
+		},
+		{
+			name:   "missing user",
+			method: http.MethodPost,
+			path:   membersPath,
+			body:   `{"username": "carol", "user_role": "spectator"}`,
+			status: http.StatusNotFound,
+			code:   problem.CodeUserNotFound,
+		},
+		{
+			name:   "not a wallet user",
+			method: http.MethodGet,
+			path:   fmt.Sprintf("/api/v1/wallets/%d", walletID),
+			token:  strangerToken,
+			status: http.StatusForbidden,
+			code:   problem.CodeForbidden,
+		},
+		{
+			name:   "not a wallet admin",
+			method: http.MethodPost,
+			path:   transactionsPath,
+			token:  spectatorToken,
+			body:   `{"type": "income", "amount": 10, "category": "Salary"}`,
+			status: http.StatusForbidden,
+			code:   problem.CodeForbidden,
+		},
+		{
+			name:   "already a wallet user",
+			method: http.MethodPost,
+			path:   membersPath,
+			body:   `{"username": "bob", "user_role": "spectator"}`,
+			status: http.StatusConflict,
+			code:   problem.CodeAlreadyWalletUser,
+		},
+		{
+			name:   "taken username",
+			method: http.MethodPost,
+			path:   "/api/v1/users",
+			body:   `{"username": "bob", "user_pwd": "correct horse battery", "base_currency": "USD"}`,
+			status: http.StatusConflict,
+			code:   problem.CodeUsernameTaken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requestToken := token
+
+			if test.token != "" {
+				requestToken = test.token
+			}
+
+			response := s.do(test.method, test.path, requestToken, test.body)
+
+			if response.Code != test.status {
+				t.Fatalf("status = %d, want %d, body %s", response.Code, test.status, response.Body)
+			}
+
+			assertProblem(t, response, test.status, test.code)
+
+			var body struct {
+				Errors json.RawMessage `json:"errors"`
+			}
+
+			if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Can't decode problem %s: %v", response.Body, err)
+			}
+
+			if string(body.Errors) != test.errors {
+				t.Errorf("field errors = %s, want %s", body.Errors, test.errors)
+			}
+		})
+	}
+}
+
+// Fail unless response is problem with provided status and code
+func assertProblem(t *testing.T, response *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	if contentType := response.Header().Get("Content-Type"); contentType != problem.ContentType {
+		t.Errorf("Content-Type = %q, want %q", contentType, problem.ContentType)
+	}
+
+	var body struct {
+		Status int    `json:"status"`
+		Code   string `json:"code"`
+	}
+
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Can't decode problem %s: %v", response.Body, err)
+	}
+
+	if body.Status != status || body.Code != code {
+		t.Errorf("problem status and code = %d %q, want %d %q", body.Status, body.Code, status, code)
+	}
+}
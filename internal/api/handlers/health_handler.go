@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Liveness probe. Respond OK as long as the process is able to serve requests.
+func (h *Handler) Healthz(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness probe. Respond OK only if database is reachable, exchange rates are fresh enough and server isn't shutting down.
+func (h *Handler) Readyz(context *gin.Context) {
+	ctx := context.Request.Context()
+	checks := gin.H{}
+	isReady := true
+
+	if h.draining.Load() {
+		checks["server"] = "shutting down"
+		isReady = false
+	} else {
+		checks["server"] = "ok"
+	}
+
+	if err := h.store.Health.Ping(ctx); err != nil {
+		checks["database"] = "unreachable"
+		isReady = false
+	} else {
+		checks["database"] = "ok"
+	}
+
+	fetchedAt, err := h.store.ExchangeRates.GetLastFetchTime(ctx)
+
+	switch {
+	case err != nil:
+		checks["exchange_rates"] = "unavailable"
+		isReady = false
+	case time.Since(fetchedAt) > h.cfg.Server.MaxRatesAge:
+		checks["exchange_rates"] = "stale since " + fetchedAt.Format(time.RFC3339)
+		isReady = false
+	default:
+		checks["exchange_rates"] = "ok"
+	}
+
+	if !isReady {
+		context.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "checks": checks})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
+
+// Make readiness probe fail from now on. Called when server starts graceful shutdown.
+func (h *Handler) StartDraining() {
+	h.draining.Store(true)
+}
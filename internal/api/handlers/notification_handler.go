@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/logic"
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Response with latest notifications of the user and number of unread ones. With "unread=true" query parameter only unread notifications are listed.
+func (h *Handler) GetNotifications(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	unreadOnly, err := strconv.ParseBool(context.DefaultQuery("unread", "false"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Unread parameter should be boolean")
+		return
+	}
+
+	notifications, unread, err := h.store.Notifications.GetByUser(context.Request.Context(), userID, unreadOnly)
+
+	if err != nil {
+		respondError(context, err, "Can't get notifications from database")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"notifications": notifications, "unread_count": unread})
+}
+
+// Mark notification as read, so it isn't emailed in digest
+func (h *Handler) MarkNotificationRead(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	notificationID, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	if err := h.store.Notifications.MarkRead(context.Request.Context(), userID, notificationID); err != nil {
+		respondError(context, err, "Can't mark notification as read")
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// Mark all notifications of the user as read
+func (h *Handler) MarkAllNotificationsRead(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	marked, err := h.store.Notifications.MarkAllRead(context.Request.Context(), userID)
+
+	if err != nil {
+		respondError(context, err, "Can't mark notifications as read")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "marked": marked})
+}
+
+// Response with notification preferences of the user
+func (h *Handler) GetNotificationPreferences(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	preferences, err := h.store.Notifications.GetPreferences(context.Request.Context(), userID)
+
+	if err != nil {
+		respondError(context, err, "Can't get notification preferences from database")
+		return
+	}
+
+	context.JSON(http.StatusOK, preferences)
+}
+
+// Replace notification preferences of the user
+func (h *Handler) UpdateNotificationPreferences(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.NotificationPreferences
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	if err := h.store.Notifications.SavePreferences(context.Request.Context(), userID, input); err != nil {
+		respondError(context, err, "Can't save notification preferences")
+		return
+	}
+
+	if input.MutedKinds == nil {
+		input.MutedKinds = []string{}
+	}
+
+	context.JSON(http.StatusOK, input)
+}
+
+// Notify user about being added to wallet. Member is already added, so failure is only logged.
+func (h *Handler) notifyInvitation(context *gin.Context, inviterID, walletID, userID int, role string) {
+	ctx := context.Request.Context()
+
+	inviter, err := h.store.Users.GetByID(ctx, inviterID)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Can't get inviter for notification", "error", err, "wallet_id", walletID)
+		return
+	}
+
+	wallet, err := h.store.Wallets.GetByID(ctx, walletID)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Can't get wallet for notification", "error", err, "wallet_id", walletID)
+		return
+	}
+
+	message := fmt.Sprintf("%s added you to wallet %s as %s", inviter.Username, wallet.WalletName, role)
+	data := map[string]any{"inviter": inviter.Username, "wallet_name": wallet.WalletName, "user_role": role}
+
+	if err := h.store.Notifications.Create(ctx, userID, models.NotificationInvitation, walletID, message, data); err != nil {
+		slog.ErrorContext(ctx, "Can't create invitation notification", "error", err, "wallet_id", walletID)
+	}
+}
+
+// Notify wallet users whose large expense threshold the expense reaches. Expense is already saved, so failure is only logged.
+func (h *Handler) notifyLargeExpense(context *gin.Context, transaction models.Transaction, decimalPlaces int) {
+	ctx := context.Request.Context()
+
+	creator, err := h.store.Users.GetByID(ctx, transaction.CreatorID)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Can't get expense creator for notification", "error", err, "wallet_id", transaction.WalletID)
+		return
+	}
+
+	wallet, err := h.store.Wallets.GetByID(ctx, transaction.WalletID)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Can't get wallet for notification", "error", err, "wallet_id", transaction.WalletID)
+		return
+	}
+
+	amount := logic.FormatOutputValue(transaction.Amount, decimalPlaces)
+	message := fmt.Sprintf("%s spent %s %s on %s in wallet %s", creator.Username, amount, wallet.Currency, transaction.Category, wallet.WalletName)
+	data := map[string]any{"transaction_id": transaction.ID, "amount": amount, "currency": wallet.Currency, "category": transaction.Category, "creator": creator.Username}
+
+	if _, err := h.store.Notifications.NotifyLargeExpense(ctx, transaction, message, data); err != nil {
+		slog.ErrorContext(ctx, "Can't create large expense notifications", "error", err, "wallet_id", transaction.WalletID)
+	}
+}
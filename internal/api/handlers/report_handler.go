@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/logic"
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Response with income and expense of every wallet category for period given by from and to dates. Split transactions are counted by their split lines. Can be performed by any wallet user.
+func (h *Handler) GetCategoryReport(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	walletID, err := strconv.Atoi(context.Param("id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	var input models.ReportInput
+
+	if err := context.ShouldBindQuery(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	// Unlike dashboard, month starts in server time zone the same way period dates do
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	if input.From != nil {
+		from = *input.From
+	}
+
+	var until *time.Time
+
+	// To includes the whole day
+	if input.To != nil {
+		nextDay := input.To.AddDate(0, 0, 1)
+		until = &nextDay
+	}
+
+	if until != nil && !until.After(from) {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Report period must end after it starts")
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if !h.checkWalletMember(context, userID, walletID) {
+		return
+	}
+
+	decimalPlaces, err := h.store.Wallets.GetDecimalPlaces(ctx, walletID)
+
+	if err != nil {
+		respondError(context, err, "Can't get wallet currency metadata")
+		return
+	}
+
+	totals, err := h.store.Transactions.GetCategoryTotals(ctx, walletID, from, until)
+
+	if err != nil {
+		respondError(context, err, "Can't get category totals from database")
+		return
+	}
+
+	categories := make([]models.CategoryTotalOutput, len(totals))
+
+	for i, total := range totals {
+		categories[i] = models.CategoryTotalOutput{
+			Category: total.Category,
+			Income:   logic.FormatOutputValue(total.Income, decimalPlaces),
+			Expense:  logic.FormatOutputValue(total.Expense, decimalPlaces),
+		}
+	}
+
+	context.JSON(http.StatusOK, gin.H{"from": from, "to": input.To, "categories": categories})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/logic"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/search"
+)
+
+const defaultSearchLimit = 50
+
+// Response with transactions from all wallets of the user matching query, best matches first. Wallets API key can't access and wallets requiring second factor the session didn't pass are skipped.
+func (h *Handler) Search(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.SearchInput
+
+	if err := context.ShouldBindQuery(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	query, err := search.Parse(input.Query)
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, err.Error())
+		return
+	}
+
+	query.Limit = input.Limit
+
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+
+	query.TwoFactor = context.GetBool("twoFactor")
+
+	if key, ok := middleware.APIKey(context); ok {
+		query.WalletIDs = key.WalletIDs
+	}
+
+	hits, err := h.store.Transactions.Search(context.Request.Context(), userID, query)
+
+	if err != nil {
+		respondError(context, err, "Can't search transactions")
+		return
+	}
+
+	results := make([]models.SearchResult, len(hits))
+
+	for i, hit := range hits {
+		results[i] = models.SearchResult{
+			Transaction: logic.FormatTransaction(hit.Transaction, hit.DecimalPlaces),
+			WalletID:    hit.Transaction.WalletID,
+			WalletName:  hit.WalletName,
+			Currency:    hit.Currency,
+			Rank:        hit.Rank,
+		}
+	}
+
+	context.JSON(http.StatusOK, gin.H{"results": results})
+}
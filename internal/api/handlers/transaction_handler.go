@@ -2,75 +2,144 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
 	"github.com/khralenok/all-wallets-api/internal/logic"
+	"github.com/khralenok/all-wallets-api/internal/metrics"
 	"github.com/khralenok/all-wallets-api/internal/models"
 	"github.com/khralenok/all-wallets-api/internal/store"
+	"github.com/khralenok/all-wallets-api/internal/validation"
 )
 
-// Create new transaction
-func CreateTransaction(context *gin.Context, isDeposit bool) {
+// Create new transaction. Type is defined by route for legacy endpoints, otherwise it's taken from request body.
+func (h *Handler) CreateTransaction(context *gin.Context, transactionType string) {
 	userID := context.MustGet("userID").(int)
+	ctx := context.Request.Context()
 
-	input, err := validateTransactionInput(userID, context)
+	input, err := h.validateTransactionInput(userID, context)
 
 	if err != nil {
 		return
 	}
 
-	decimalPlaces, err := store.GetWalletDecimalPlaces(input.WalletID)
+	if transactionType == "" {
+		transactionType = input.Type
+	}
+
+	transaction, decimalPlaces, ok := h.buildTransaction(context, userID, input, transactionType)
+
+	if !ok {
+		return
+	}
+
+	// Balance is checked by store under wallet lock, so concurrent expenses can't overdraw it together
+	newTransaction, err := h.store.Transactions.Add(ctx, transaction)
+
+	if errors.Is(err, store.ErrInsufficientFunds) {
+		metrics.BalanceCheckFailures.Inc()
+	}
 
 	if err != nil {
+		respondError(context, err, "Failed to insert new transaction data into the database")
 		return
 	}
 
-	formatedAmount := logic.FormatInputValue(input.Amount, decimalPlaces)
+	metrics.TransactionsCreated.WithLabelValues(transactionTypeOf(newTransaction)).Inc()
 
-	if !isDeposit {
-		if isAllowed, err := store.CheckIfBalanceIsEnough(input.WalletID, formatedAmount); !isAllowed {
-			if err != nil {
-				context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't approve wallet have enough funds to write expense"})
-				return
-			}
+	h.publishBalance(context, input.WalletID, decimalPlaces)
 
-			context.JSON(http.StatusConflict, gin.H{"error": "Conflict", "message": "Insufficient funds. You cannot spend more than your current wallet balance."})
-			return
-		}
+	if !newTransaction.IsDeposit {
+		h.notifyLargeExpense(context, newTransaction, decimalPlaces)
 	}
 
-	newTransaction, err := store.AddTransaction(formatedAmount, input.WalletID, isDeposit, input.Category, context)
+	context.JSON(http.StatusCreated, gin.H{"new_transaction": newTransaction})
+}
+
+// Replace transaction fields with ones from request body, type is required. Omitted occurred_at keeps the previous one. Can be performed only by wallet admin.
+func (h *Handler) UpdateTransaction(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	transactionID, err := strconv.Atoi(context.Param("transaction_id"))
 
 	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
 		return
 	}
 
-	context.JSON(http.StatusCreated, gin.H{"new_transaction": newTransaction})
+	input, err := h.validateTransactionInput(userID, context)
+
+	if err != nil {
+		return
+	}
+
+	transaction, decimalPlaces, ok := h.buildTransaction(context, userID, input, input.Type)
+
+	if !ok {
+		return
+	}
+
+	transaction.ID = transactionID
+
+	updated, err := h.store.Transactions.Update(context.Request.Context(), transaction)
+
+	if errors.Is(err, store.ErrInsufficientFunds) {
+		metrics.BalanceCheckFailures.Inc()
+	}
+
+	if err != nil {
+		respondError(context, err, "Failed to update transaction data in the database")
+		return
+	}
+
+	h.publishBalance(context, input.WalletID, decimalPlaces)
+
+	context.JSON(http.StatusOK, gin.H{"transaction": updated})
 }
 
-// Get user transactions
-func GetWalletTransactions(context *gin.Context) {
-	_ = context.MustGet("userID").(int)
+// Get wallet transactions with their tags and attachments. Query parameters q, tag and payee narrow the list.
+func (h *Handler) GetWalletTransactions(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
 
-	walletID, err := strconv.Atoi(context.Param("wallet_id"))
+	walletID, err := strconv.Atoi(context.Param("id"))
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	if !h.checkWalletMember(context, userID, walletID) {
 		return
 	}
 
-	transactionsRaw, err := store.GetWalletTransactions(walletID)
+	var filter models.TransactionFilter
+
+	if err := context.ShouldBindQuery(&filter); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	filter.Query = strings.TrimSpace(filter.Query)
+	filter.Tag = strings.ToLower(strings.TrimSpace(filter.Tag))
+	filter.Payee = strings.TrimSpace(filter.Payee)
+
+	ctx := context.Request.Context()
+
+	transactionsRaw, err := h.store.Transactions.GetByWallet(ctx, walletID, filter)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't fetch the data from database"})
+		respondError(context, err, "Can't fetch the data from database")
 		return
 	}
 
-	decimalPlaces, err := store.GetWalletDecimalPlaces(walletID)
+	decimalPlaces, err := h.store.Wallets.GetDecimalPlaces(ctx, walletID)
 
 	if err != nil {
+		respondError(context, err, "Can't get wallet currency metadata")
 		return
 	}
 
@@ -79,28 +148,106 @@ func GetWalletTransactions(context *gin.Context) {
 	context.JSON(http.StatusOK, gin.H{"status": "OK", "transactions": transactions})
 }
 
+// Return transaction of given type made from validated input, with amounts converted to minor units, and wallet currency decimal places. Otherwise respond with problem.
+func (h *Handler) buildTransaction(context *gin.Context, userID int, input models.TransactionInput, transactionType string) (models.Transaction, int, bool) {
+	if transactionType == "" {
+		p := problem.New(http.StatusUnprocessableEntity, problem.CodeValidationFailed, "Some fields are invalid")
+		p.Errors = []problem.FieldError{{Field: "type", Rule: "required"}}
+		problem.Write(context, p)
+		return models.Transaction{}, 0, false
+	}
+
+	decimalPlaces, err := h.store.Wallets.GetDecimalPlaces(context.Request.Context(), input.WalletID)
+
+	if err != nil {
+		respondError(context, err, "Can't get wallet currency metadata")
+		return models.Transaction{}, 0, false
+	}
+
+	if !validation.FitsPrecision(input.Amount, decimalPlaces) {
+		problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeAmountPrecision, fmt.Sprintf("Amount can't have more than %d decimal places in this wallet currency", decimalPlaces))
+		return models.Transaction{}, 0, false
+	}
+
+	formatedAmount := logic.FormatInputValue(input.Amount, decimalPlaces)
+
+	if formatedAmount > validation.MaxAmount {
+		problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeAmountTooLarge, "Amount is too large")
+		return models.Transaction{}, 0, false
+	}
+
+	splits := make([]models.TransactionSplit, len(input.Splits))
+	splitsSum := 0
+
+	for i, split := range input.Splits {
+		if !validation.FitsPrecision(split.Amount, decimalPlaces) {
+			problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeAmountPrecision, fmt.Sprintf("Split amount can't have more than %d decimal places in this wallet currency", decimalPlaces))
+			return models.Transaction{}, 0, false
+		}
+
+		splits[i] = models.TransactionSplit{Category: split.Category, Amount: logic.FormatInputValue(split.Amount, decimalPlaces)}
+		splitsSum += splits[i].Amount
+	}
+
+	if len(splits) > 0 && splitsSum != formatedAmount {
+		problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeSplitSumMismatch, fmt.Sprintf("Split amounts sum up to %s instead of %s", logic.FormatOutputValue(splitsSum, decimalPlaces), logic.FormatOutputValue(formatedAmount, decimalPlaces)))
+		return models.Transaction{}, 0, false
+	}
+
+	transaction := models.Transaction{
+		Amount:      formatedAmount,
+		IsDeposit:   transactionType == models.TransactionIncome,
+		Category:    input.Category,
+		Description: strings.TrimSpace(input.Description),
+		Payee:       strings.TrimSpace(input.Payee),
+		WalletID:    input.WalletID,
+		CreatorID:   userID,
+		Tags:        validation.NormalizeTags(input.Tags),
+		Splits:      splits,
+	}
+
+	// Timestamp columns have no time zone, so times are stored in server one like created_at
+	if input.OccurredAt != nil {
+		transaction.OccurredAt = input.OccurredAt.Local()
+	}
+
+	return transaction, decimalPlaces, true
+}
+
+// Return type of transaction as clients send it
+func transactionTypeOf(transaction models.Transaction) string {
+	if transaction.IsDeposit {
+		return models.TransactionIncome
+	}
+
+	return models.TransactionExpense
+}
+
 // Group checkups that are common for adding expense and income based on user input
-func validateTransactionInput(userID int, context *gin.Context) (models.TransactionInput, error) {
+func (h *Handler) validateTransactionInput(userID int, context *gin.Context) (models.TransactionInput, error) {
 
 	var input models.TransactionInput
 
-	if err := context.BindJSON(&input); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+	if err := bindWalletInput(context, &input, &input.WalletID); err != nil {
+		problem.InvalidInput(context, err)
 		return models.TransactionInput{}, err
 	}
 
-	if input.Amount <= 0 {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Amount must be positive"})
-		return models.TransactionInput{}, errors.New("amount must be positive")
-	}
+	ctx := context.Request.Context()
 
-	if !store.IsWalletExist(input.WalletID, context) {
+	if err := h.store.Wallets.EnsureActive(ctx, input.WalletID); err != nil {
+		respondError(context, err, "Can't fetch wallet data")
 		return models.TransactionInput{}, errors.New("seems wallet doesn't exist")
 	}
 
-	if !store.CheckUserPermissions(userID, input.WalletID, context) {
+	if err := h.store.WalletUsers.CheckAdmin(ctx, userID, input.WalletID); err != nil {
+		respondError(context, err, "Failed to fetch supplicant user data")
 		return models.TransactionInput{}, errors.New("user have no rights to add transactions")
 	}
 
+	if !h.checkWalletAccess(context, input.WalletID) {
+		return models.TransactionInput{}, errors.New("wallet requires second factor")
+	}
+
 	return input, nil
 }
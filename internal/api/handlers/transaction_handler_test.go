@@ -0,0 +1,56 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentExpensesDontOverdraw(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, alice := s.createUser(t, "alice", "correct horse battery")
+	walletID := s.createWallet(t, aliceID, "USD")
+	path := fmt.Sprintf("/api/v1/wallets/%d/transactions", walletID)
+
+	if response := s.do(http.MethodPost, path, alice, `{"type": "income", "amount": 100, "category": "Salary"}`); response.Code != http.StatusCreated {
+		t.Fatalf("income = %d, want %d, body %s", response.Code, http.StatusCreated, response.Body)
+	}
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 10)
+
+	for i := range statuses {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			statuses[i] = s.do(http.MethodPost, path, alice, `{"type": "expense", "amount": 30, "category": "Food"}`).Code
+		}()
+	}
+
+	wg.Wait()
+
+	created := 0
+
+	for _, status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("expense = %d, want %d or %d", status, http.StatusCreated, http.StatusConflict)
+		}
+	}
+
+	if created != 3 {
+		t.Errorf("%d expenses of 30 were added to balance of 100, want 3", created)
+	}
+
+	balance, err := s.store.Wallets.GetBalance(context.Background(), walletID)
+
+	if err != nil || balance != 1000 {
+		t.Errorf("balance = %d (%v), want 1000 cents", balance, err)
+	}
+}
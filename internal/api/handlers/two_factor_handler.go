@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/totp"
+)
+
+// Name authenticator apps show next to the code
+const TwoFactorIssuer = "All Wallets"
+
+// Start second factor enrollment. Response with otpauth URI to be shown as QR code and the secret itself for manual entry. Second factor isn't active until it's confirmed with a code.
+func (h *Handler) EnrollTwoFactor(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	ctx := context.Request.Context()
+
+	user, err := h.store.Users.GetByID(ctx, userID)
+
+	if err != nil {
+		respondError(context, err, "Can't get user data from database")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+
+	if err != nil {
+		respondError(context, err, "Can't generate second factor secret")
+		return
+	}
+
+	if err := h.store.TwoFactor.SavePending(ctx, userID, secret); err != nil {
+		respondError(context, err, "Can't save second factor secret")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"otpauth_uri": totp.URI(TwoFactorIssuer, user.Username, secret), "secret": secret})
+}
+
+// Activate second factor with the first code from authenticator app. Response with single-use recovery codes, which are shown only once, and new token, because all other sessions are revoked.
+func (h *Handler) ConfirmTwoFactor(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.TwoFactorCodeInput
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	twoFactor, err := h.store.TwoFactor.Get(ctx, userID)
+
+	if err != nil {
+		respondError(context, err, "Can't get second factor settings")
+		return
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, strings.TrimSpace(input.Code), time.Now())
+
+	if !ok {
+		problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeInvalidTwoFactor, "Invalid second factor code")
+		return
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := generateRecoveryCodes()
+
+	if err != nil {
+		respondError(context, err, "Can't generate recovery codes")
+		return
+	}
+
+	tokenVersion, err := h.store.TwoFactor.Enable(ctx, userID, step, recoveryCodeHashes)
+
+	if err != nil {
+		respondError(context, err, "Can't enable second factor")
+		return
+	}
+
+	token, err := h.jwt.Generate(userID, tokenVersion, true)
+
+	if err != nil {
+		respondError(context, err, "Token generation failed")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Second factor was enabled", "recovery_codes": recoveryCodes, "token": token})
+}
+
+// Turn second factor off. Require password and a code, either from authenticator app or recovery one. Response with new token, because all other sessions are revoked.
+func (h *Handler) DisableTwoFactor(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.DisableTwoFactorInput
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	user, err := h.store.Users.GetByID(ctx, userID)
+
+	if err != nil {
+		respondError(context, err, "Can't get user data from database")
+		return
+	}
+
+	if match, _ := h.passwords.Verify(strings.TrimSpace(input.Password), strings.TrimSpace(user.Password)); !match {
+		problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid credentials")
+		return
+	}
+
+	valid, err := h.verifyTwoFactorCode(context, userID, input.Code)
+
+	if err != nil {
+		respondError(context, err, "Can't check second factor code")
+		return
+	}
+
+	if !valid {
+		problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeInvalidTwoFactor, "Invalid second factor code")
+		return
+	}
+
+	tokenVersion, err := h.store.TwoFactor.Disable(ctx, userID)
+
+	if err != nil {
+		respondError(context, err, "Can't disable second factor")
+		return
+	}
+
+	token, err := h.jwt.Generate(userID, tokenVersion, false)
+
+	if err != nil {
+		respondError(context, err, "Token generation failed")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "message": "Second factor was disabled", "token": token})
+}
+
+// Replace all recovery codes with new ones. Require a code, either from authenticator app or recovery one.
+func (h *Handler) RegenerateRecoveryCodes(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.TwoFactorCodeInput
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	valid, err := h.verifyTwoFactorCode(context, userID, input.Code)
+
+	if err != nil {
+		respondError(context, err, "Can't check second factor code")
+		return
+	}
+
+	if !valid {
+		problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeInvalidTwoFactor, "Invalid second factor code")
+		return
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := generateRecoveryCodes()
+
+	if err != nil {
+		respondError(context, err, "Can't generate recovery codes")
+		return
+	}
+
+	if err := h.store.TwoFactor.ReplaceRecoveryCodes(context.Request.Context(), userID, recoveryCodeHashes); err != nil {
+		respondError(context, err, "Can't save recovery codes")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// Exchange challenge token from /login and second factor code for JWT token. Wrong codes count as failed logins, so they lock the account the same way.
+func (h *Handler) CompleteTwoFactorLogin(context *gin.Context) {
+	var input models.TwoFactorLoginInput
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	userID, tokenVersion, err := h.jwt.ParseChallenge(input.ChallengeToken)
+
+	if err != nil {
+		problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid or expired challenge token")
+		return
+	}
+
+	user, err := h.store.Users.GetByID(context.Request.Context(), userID)
+
+	if err != nil || user.TokenVersion != tokenVersion {
+		problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid or expired challenge token")
+		return
+	}
+
+	if !h.checkLoginAllowed(context, user.Username) {
+		return
+	}
+
+	if !h.checkTwoFactorCode(context, user, input.Code) {
+		return
+	}
+
+	h.resetLoginFailures(context, user.Username)
+
+	token, err := h.jwt.Generate(user.ID, user.TokenVersion, true)
+
+	if err != nil {
+		respondError(context, err, "Token generation failed")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Success", "token": token})
+}
+
+// Make second factor mandatory or optional for all members of the wallet. Can be performed only by wallet admin logged in with second factor.
+func (h *Handler) SetWalletRequireTwoFactor(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, err := strconv.Atoi(context.Param("id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Id parameter should be integer")
+		return
+	}
+
+	var input models.RequireTwoFactorInput
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if err := h.store.WalletUsers.CheckAdmin(ctx, userID, walletID); err != nil {
+		respondError(context, err, "Failed to fetch supplicant user data")
+		return
+	}
+
+	if !context.GetBool("twoFactor") {
+		problem.Respond(context, http.StatusForbidden, problem.CodeTwoFactorRequired, "Log in with second factor to change this setting")
+		return
+	}
+
+	if err := h.store.Wallets.SetRequireTwoFactor(ctx, walletID, *input.Required); err != nil {
+		respondError(context, err, "Can't update wallet settings")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "wallet_id": walletID, "require_2fa": *input.Required})
+}
+
+// Return true if caller may access the wallet: API key, if used, is not restricted to other wallets, and either wallet doesn't require second factor or caller logged in with it. Otherwise respond with problem.
+func (h *Handler) checkWalletAccess(context *gin.Context, walletID int) bool {
+	if key, ok := middleware.APIKey(context); ok && !key.AllowsWallet(walletID) {
+		problem.Respond(context, http.StatusForbidden, problem.CodeForbidden, "API key can't be used with this wallet")
+		return false
+	}
+
+	if context.GetBool("twoFactor") {
+		return true
+	}
+
+	required, err := h.store.Wallets.RequiresTwoFactor(context.Request.Context(), walletID)
+
+	if err != nil {
+		respondError(context, err, "Can't get wallet settings")
+		return false
+	}
+
+	if required {
+		problem.Respond(context, http.StatusForbidden, problem.CodeTwoFactorRequired, "This wallet requires second factor. Enable it and log in again")
+		return false
+	}
+
+	return true
+}
+
+// Return true if code is valid for the user. Otherwise count it as failed login and respond with problem.
+func (h *Handler) checkTwoFactorCode(context *gin.Context, user models.User, code string) bool {
+	if code == "" {
+		problem.Respond(context, http.StatusUnauthorized, problem.CodeTwoFactorRequired, "Second factor code required")
+		return false
+	}
+
+	valid, err := h.verifyTwoFactorCode(context, user.ID, code)
+
+	if err != nil {
+		respondError(context, err, "Can't check second factor code")
+		return false
+	}
+
+	if !valid {
+		h.countLoginFailure(context, user.Username)
+		problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidTwoFactor, "Invalid second factor code")
+		return false
+	}
+
+	return true
+}
+
+// Return true if code from authenticator app or unused recovery code is valid. Both are single-use.
+func (h *Handler) verifyTwoFactorCode(context *gin.Context, userID int, code string) (bool, error) {
+	ctx := context.Request.Context()
+	code = strings.TrimSpace(code)
+
+	if !totp.IsCode(code) {
+		return h.store.TwoFactor.UseRecoveryCode(ctx, userID, totp.HashRecoveryCode(code))
+	}
+
+	twoFactor, err := h.store.TwoFactor.Get(ctx, userID)
+
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+
+	if !ok || !twoFactor.IsEnabled {
+		return false, nil
+	}
+
+	return h.store.TwoFactor.UseStep(ctx, userID, step)
+}
+
+// Return recovery codes to show user and their hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes, err := totp.GenerateRecoveryCodes()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
@@ -1,38 +1,48 @@
 package handlers
 
 import (
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
 	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/password"
 	"github.com/khralenok/all-wallets-api/internal/store"
 )
 
 // Add new user in database or give http error in response. As input require json with username, password and base currency in JSON format
-func CreateUser(context *gin.Context) {
+func (h *Handler) CreateUser(context *gin.Context) {
 	var input models.SigninInputs
 
-	if err := context.BindJSON(&input); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
 	}
 
-	if err := store.CheckIfUsernameUnique(input.Username, context); err != nil {
+	if !h.validateCurrencyInput(input.BaseCurrency, context) {
 		return
 	}
 
-	passwordHash, err := middleware.HashPassword(strings.TrimSpace(input.Password))
-
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Password encryption failed"})
+	if !h.checkPasswordPolicy(context, strings.TrimSpace(input.Password), input.Username) {
 		return
 	}
 
-	err = store.AddNewUser(input.Username, passwordHash, input.BaseCurrency, context)
+	// Password is hashed before username is checked, so taken username isn't answered faster than free one
+	passwordHash, err := h.passwords.Hash(strings.TrimSpace(input.Password))
 
 	if err != nil {
+		respondError(context, err, "Password encryption failed")
+		return
+	}
+
+	if err := h.store.Users.Create(context.Request.Context(), input.Username, passwordHash, input.BaseCurrency); err != nil {
+		respondError(context, err, "Failed to insert user")
 		return
 	}
 
@@ -40,42 +50,177 @@ func CreateUser(context *gin.Context) {
 }
 
 // Response with JWT Token user need to use other API handlers. As input require username and password in JSON format
-func LoginUser(context *gin.Context) {
+func (h *Handler) LoginUser(context *gin.Context) {
 	var input models.LoginInputs
 
 	if err := context.ShouldBindJSON(&input); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+		problem.InvalidInput(context, err)
 		return
 	}
 
-	user, err := store.GetUserByUsername(input.Username, context)
+	if !h.checkLoginAllowed(context, input.Username) {
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	user, err := h.store.Users.GetByUsername(ctx, input.Username)
+
+	if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+		respondError(context, err, "Can't get user data from database")
+		return
+	}
+
+	if !h.checkCredentials(context, user, err == nil, input.Password) {
+		h.recordLoginFailure(context, input.Username)
+		return
+	}
+
+	twoFactorEnabled, err := h.store.TwoFactor.IsEnabled(ctx, user.ID)
 
 	if err != nil {
+		respondError(context, err, "Can't get second factor settings")
 		return
 	}
 
-	if !middleware.CheckPasswordHash(strings.TrimSpace(input.Password), strings.TrimSpace(user.Password)) {
-		context.JSON(http.StatusUnauthorized, gin.H{"error": "Status Unauthorized", "message": "Invalid credentials"})
+	// Real token is issued by CompleteTwoFactorLogin once code is provided. Failed logins are reset only then, so correct password doesn't give more attempts to guess the code.
+	if twoFactorEnabled {
+		challengeToken, err := h.jwt.GenerateChallenge(user.ID, user.TokenVersion)
+
+		if err != nil {
+			respondError(context, err, "Token generation failed")
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{"message": "Second factor required", "two_factor_required": true, "challenge_token": challengeToken})
 		return
 	}
 
-	token, err := middleware.GenerateJWT(user.ID)
+	h.resetLoginFailures(context, input.Username)
+
+	token, err := h.jwt.Generate(user.ID, user.TokenVersion, false)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Token generation failed"})
+		respondError(context, err, "Token generation failed")
 		return
 	}
 
 	context.JSON(http.StatusOK, gin.H{"message": "Success", "token": token})
 }
 
+// Return true if password matches found user. Password is checked against dummy hash if user wasn't found, so unknown username and wrong password take the same time and get the same response. Outdated hash is replaced with the current algorithm while password is known.
+func (h *Handler) checkCredentials(context *gin.Context, user models.User, found bool, password string) bool {
+	password = strings.TrimSpace(password)
+
+	if !found || user.Password == "" {
+		h.passwords.DummyVerify(password)
+		return false
+	}
+
+	match, needsRehash := h.passwords.Verify(password, strings.TrimSpace(user.Password))
+
+	if match && needsRehash {
+		h.rehashPassword(context, user, password)
+	}
+
+	return match
+}
+
+// Upgrade user password hash. Failure isn't shown to user, old hash keeps working.
+func (h *Handler) rehashPassword(context *gin.Context, user models.User, password string) {
+	ctx := context.Request.Context()
+
+	newHash, err := h.passwords.Hash(password)
+
+	if err == nil {
+		err = h.store.Users.RehashPassword(ctx, user.ID, user.Password, newHash)
+	}
+
+	if err != nil {
+		slog.WarnContext(ctx, "Can't upgrade password hash", "error", err, "user_id", user.ID)
+	}
+}
+
+// Return true if new password follows policy. Otherwise respond with all broken rules.
+func (h *Handler) checkPasswordPolicy(context *gin.Context, newPassword, username string) bool {
+	err := h.passwordPolicy.Check(newPassword, username)
+
+	var policyErr *password.PolicyError
+
+	if errors.As(err, &policyErr) {
+		p := problem.New(http.StatusUnprocessableEntity, problem.CodeWeakPassword, "Password doesn't meet requirements")
+		problem.Write(context, p.With("violations", policyErr.Violations))
+		return false
+	}
+
+	return true
+}
+
+// Return true if login attempt with this username may proceed. Otherwise respond with 429 telling when to retry. Per username limit holds even if attacker spreads attempts over many IPs.
+func (h *Handler) checkLoginAllowed(context *gin.Context, username string) bool {
+	ctx := context.Request.Context()
+
+	wait, err := h.limiter.Take(ctx, "login:username:"+username, h.cfg.RateLimit.LoginPerUsername)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Rate limiter failed", "error", err, "limit", "login")
+	} else if wait > 0 {
+		problem.TooManyRequests(context, problem.CodeRateLimited, "Too many login attempts, try again later", wait)
+		return false
+	}
+
+	lockedUntil, err := h.store.LoginFailures.LockedUntil(ctx, username)
+
+	if err != nil {
+		respondError(context, err, "Can't check account lockout")
+		return false
+	}
+
+	if wait := time.Until(lockedUntil); wait > 0 {
+		problem.TooManyRequests(context, problem.CodeAccountLocked, "Account is temporarily locked after too many failed logins", wait)
+		return false
+	}
+
+	return true
+}
+
+// Forget failed logins after successful one. Failure is only logged, counter expires anyway.
+func (h *Handler) resetLoginFailures(context *gin.Context, username string) {
+	ctx := context.Request.Context()
+
+	if err := h.store.LoginFailures.Reset(ctx, username); err != nil {
+		slog.WarnContext(ctx, "Can't reset failed logins", "error", err)
+	}
+}
+
+// Count failed login, which may lock username, and respond with invalid credentials.
+func (h *Handler) recordLoginFailure(context *gin.Context, username string) {
+	h.countLoginFailure(context, username)
+	problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid credentials")
+}
+
+// Count failed login, which may lock username. Failure to count is only logged, the login is refused anyway.
+func (h *Handler) countLoginFailure(context *gin.Context, username string) {
+	ctx := context.Request.Context()
+
+	lockedUntil, err := h.store.LoginFailures.RecordFailure(ctx, username, h.cfg.RateLimit.Lockout)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Can't record failed login", "error", err)
+	} else if !lockedUntil.IsZero() {
+		slog.WarnContext(ctx, "Account locked after failed logins", "locked_until", lockedUntil)
+	}
+}
+
 // Response with user data and brief data for all wallets which user participated in.
-func GetProfile(context *gin.Context) {
+func (h *Handler) GetProfile(context *gin.Context) {
 	userID := context.MustGet("userID").(int)
+	ctx := context.Request.Context()
 
-	user, err := store.GetUserById(userID, context)
+	user, err := h.store.Users.GetByID(ctx, userID)
 
 	if err != nil {
+		respondError(context, err, "Can't get user data from database")
 		return
 	}
 
@@ -87,9 +232,10 @@ func GetProfile(context *gin.Context) {
 
 	var rawBalance float64
 
-	userWallets, err := store.GetWalletsByUser(user, context)
+	userWallets, err := h.store.Wallets.GetByUser(ctx, user)
 
 	if err != nil {
+		respondError(context, err, "Can't get wallets list from database")
 		return
 	}
 
@@ -97,6 +243,7 @@ func GetProfile(context *gin.Context) {
 		nextBalance, err := strconv.ParseFloat(value.UserCurrencyBalance, 64)
 
 		if err != nil {
+			respondError(context, err, "Can't calculate user balance")
 			return
 		}
 
@@ -108,17 +255,220 @@ func GetProfile(context *gin.Context) {
 	context.JSON(http.StatusOK, gin.H{"user": userOutput, "wallets": userWallets})
 }
 
-// Mark the user as deleted and remove them from all wallets they participated in. Can be called only by user themself.
-func DeleteUser(context *gin.Context) {
+// Mark the user as deleted. Every wallet where user is the only admin must be either handed over to another wallet user or deleted, otherwise response lists such wallets. User can cancel deletion during grace period, after that their personal data is purged by the worker. Can be called only by user themself.
+func (h *Handler) DeleteUser(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	ctx := context.Request.Context()
+
+	var input models.DeleteUserInputs
+
+	if err := context.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	walletIDs, err := h.store.WalletUsers.GetSolelyAdministeredWallets(ctx, userID)
+
+	if err != nil {
+		respondError(context, err, "Can't fetch wallets administered by this user")
+		return
+	}
+
+	decisions := make(map[int]models.WalletHandover)
+
+	for _, handover := range input.Wallets {
+		decisions[handover.WalletID] = handover
+	}
+
+	var handovers []models.WalletHandover
+	var unresolved []int
+
+	for _, walletID := range walletIDs {
+		handover, ok := decisions[walletID]
+
+		if !ok {
+			unresolved = append(unresolved, walletID)
+			continue
+		}
+
+		handovers = append(handovers, handover)
+	}
+
+	if len(unresolved) > 0 {
+		problem.Write(context, problem.New(http.StatusConflict, problem.CodeUnresolvedWallets, "You are the only admin of these wallets. Hand them over to another wallet user or delete them").With("wallets_to_resolve", unresolved))
+		return
+	}
+
+	deletedAt, err := h.store.Users.ScheduleDeletion(ctx, userID, handovers)
+
+	if err != nil {
+		respondError(context, err, "Can't delete this user")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "message": "User was successfully deleted", "restorable_until": deletedAt.Add(store.DeletedUserGracePeriod)})
+}
+
+// Cancel user deletion during grace period. As input require username and password in JSON format, plus second factor code if user has it enabled. Response with new JWT token.
+func (h *Handler) RestoreUser(context *gin.Context) {
+	var input models.RestoreUserInputs
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if !h.checkLoginAllowed(context, input.Username) {
+		return
+	}
+
+	user, err := h.store.Users.GetDeletedByUsername(ctx, input.Username)
+
+	if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+		respondError(context, err, "Can't get user data from database")
+		return
+	}
+
+	if !h.checkCredentials(context, user, err == nil, input.Password) {
+		h.recordLoginFailure(context, input.Username)
+		return
+	}
+
+	if err := h.store.Users.CheckUsernameUnique(ctx, user.Username); err != nil {
+		respondError(context, err, "Can't get response from database")
+		return
+	}
+
+	twoFactorEnabled, err := h.store.TwoFactor.IsEnabled(ctx, user.ID)
+
+	if err != nil {
+		respondError(context, err, "Can't get second factor settings")
+		return
+	}
+
+	if twoFactorEnabled && !h.checkTwoFactorCode(context, user, input.TwoFactorCode) {
+		return
+	}
+
+	h.resetLoginFailures(context, input.Username)
+
+	tokenVersion, err := h.store.Users.Restore(ctx, user.ID)
+
+	if err != nil {
+		respondError(context, err, "Can't restore this user")
+		return
+	}
+
+	token, err := h.jwt.Generate(user.ID, tokenVersion, twoFactorEnabled)
+
+	if err != nil {
+		respondError(context, err, "Token generation failed")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "User was restored", "token": token})
+}
+
+// Replace user password. Require current password and revoke all other sessions, so response contains new token for the caller.
+func (h *Handler) ChangePassword(context *gin.Context) {
 	userID := context.MustGet("userID").(int)
 
-	if store.RemoveUserFromAllWallets(userID, context) != nil {
+	var input models.ChangePasswordInputs
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	user, err := h.store.Users.GetByID(ctx, userID)
+
+	if err != nil {
+		respondError(context, err, "Can't get user data from database")
+		return
+	}
+
+	if match, _ := h.passwords.Verify(strings.TrimSpace(input.CurrentPassword), strings.TrimSpace(user.Password)); !match {
+		problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid credentials")
+		return
+	}
+
+	if !h.checkPasswordPolicy(context, strings.TrimSpace(input.NewPassword), user.Username) {
+		return
+	}
+
+	passwordHash, err := h.passwords.Hash(strings.TrimSpace(input.NewPassword))
+
+	if err != nil {
+		respondError(context, err, "Password encryption failed")
+		return
+	}
+
+	tokenVersion, err := h.store.Users.UpdatePassword(ctx, userID, passwordHash)
+
+	if err != nil {
+		respondError(context, err, "Can't update user password")
+		return
+	}
+
+	token, err := h.jwt.Generate(userID, tokenVersion, context.GetBool("twoFactor"))
+
+	if err != nil {
+		respondError(context, err, "Token generation failed")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Password was changed", "token": token})
+}
+
+// Replace username with a new one which isn't taken by other active user.
+func (h *Handler) ChangeUsername(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.ChangeUsernameInputs
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if err := h.store.Users.CheckUsernameUnique(ctx, input.Username); err != nil {
+		respondError(context, err, "Can't get response from database")
+		return
+	}
+
+	if err := h.store.Users.UpdateUsername(ctx, userID, input.Username); err != nil {
+		respondError(context, err, "Can't update username")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "message": "Username was changed", "username": input.Username})
+}
+
+// Replace currency user balance is calculated in. Currency must be listed in currency metadata.
+func (h *Handler) ChangeBaseCurrency(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.ChangeBaseCurrencyInputs
+
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	if !h.validateCurrencyInput(input.BaseCurrency, context) {
 		return
 	}
 
-	if store.MarkUserAsDeleted(userID, context) != nil {
+	if err := h.store.Users.UpdateBaseCurrency(context.Request.Context(), userID, input.BaseCurrency); err != nil {
+		respondError(context, err, "Can't update base currency")
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"status": "Ok", "message": "User was successfully deleted"})
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "message": "Base currency was changed", "base_currency": input.BaseCurrency})
 }
@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/config"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+)
+
+func TestLoginDoesNotRevealWhetherUserExists(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "alice", "correct horse battery")
+
+	wrongPassword := s.do(http.MethodPost, "/api/v1/sessions", "", `{"username": "alice", "user_pwd": "wrong horse battery"}`)
+	unknownUser := s.do(http.MethodPost, "/api/v1/sessions", "", `{"username": "mallory", "user_pwd": "wrong horse battery"}`)
+
+	if wrongPassword.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d, body %s", wrongPassword.Code, http.StatusUnauthorized, wrongPassword.Body)
+	}
+
+	if unknownUser.Code != wrongPassword.Code {
+		t.Errorf("status of unknown user = %d, of wrong password = %d", unknownUser.Code, wrongPassword.Code)
+	}
+
+	if !reflect.DeepEqual(unknownUser.Header(), wrongPassword.Header()) {
+		t.Errorf("headers of unknown user = %v, of wrong password = %v", unknownUser.Header(), wrongPassword.Header())
+	}
+
+	if !bytes.Equal(unknownUser.Body.Bytes(), wrongPassword.Body.Bytes()) {
+		t.Errorf("body of unknown user = %s, of wrong password = %s", unknownUser.Body, wrongPassword.Body)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimit.Lockout = ratelimit.Lockout{Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour}
+
+	s := newTestServerWithConfig(t, cfg)
+	s.createUser(t, "alice", "correct horse battery")
+	s.createUser(t, "bob", "correct horse battery")
+
+	for i := 1; i <= 3; i++ {
+		response := s.doFrom("192.0.2.1", http.MethodPost, "/api/v1/sessions", "", `{"username": "alice", "user_pwd": "wrong horse battery"}`)
+		assertProblem(t, response, http.StatusUnauthorized, problem.CodeInvalidCredentials)
+	}
+
+	// Lock holds for correct password and other IPs too
+	locked := s.doFrom("198.51.100.7", http.MethodPost, "/api/v1/sessions", "", `{"username": "alice", "user_pwd": "correct horse battery"}`)
+	assertProblem(t, locked, http.StatusTooManyRequests, problem.CodeAccountLocked)
+
+	if retryAfter := locked.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Errorf("Retry-After = %q, want 60", retryAfter)
+	}
+
+	if response := s.doFrom("192.0.2.1", http.MethodPost, "/api/v1/sessions", "", `{"username": "bob", "user_pwd": "correct horse battery"}`); response.Code != http.StatusOK {
+		t.Errorf("login of other user = %d, want %d, body %s", response.Code, http.StatusOK, response.Body)
+	}
+}
+
+func TestLoginRateLimits(t *testing.T) {
+	const body = `{"username": "%s", "user_pwd": "correct horse battery"}`
+
+	tests := []struct {
+		name string
+		// Rate limit under test allows two logins, the other one doesn't interfere
+		configure func(cfg *config.Config)
+		// Two logins spending the limit and two more: the first must be refused, the second allowed
+		spend, refused, allowed [2]string
+	}{
+		{
+			name:      "per IP",
+			configure: func(cfg *config.Config) { cfg.RateLimit.LoginPerIP = ratelimit.Rate{Burst: 2, Period: time.Hour} },
+			spend:     [2]string{"192.0.2.1", "alice"},
+			refused:   [2]string{"192.0.2.1", "bob"},
+			allowed:   [2]string{"198.51.100.7", "bob"},
+		},
+		{
+			name:      "per username",
+			configure: func(cfg *config.Config) { cfg.RateLimit.LoginPerUsername = ratelimit.Rate{Burst: 2, Period: time.Hour} },
+			spend:     [2]string{"192.0.2.1", "alice"},
+			refused:   [2]string{"198.51.100.7", "alice"},
+			allowed:   [2]string{"198.51.100.7", "bob"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.RateLimit.LoginPerIP = ratelimit.Rate{Burst: 100, Period: time.Hour}
+			cfg.RateLimit.LoginPerUsername = ratelimit.Rate{Burst: 100, Period: time.Hour}
+			test.configure(&cfg)
+
+			s := newTestServerWithConfig(t, cfg)
+			s.createUser(t, "alice", "correct horse battery")
+			s.createUser(t, "bob", "correct horse battery")
+
+			login := func(from [2]string) *httptest.ResponseRecorder {
+				return s.doFrom(from[0], http.MethodPost, "/api/v1/sessions", "", fmt.Sprintf(body, from[1]))
+			}
+
+			for i := 0; i < 2; i++ {
+				if response := login(test.spend); response.Code != http.StatusOK {
+					t.Fatalf("login %d = %d, want %d, body %s", i+1, response.Code, http.StatusOK, response.Body)
+				}
+			}
+
+			refused := login(test.refused)
+			assertProblem(t, refused, http.StatusTooManyRequests, problem.CodeRateLimited)
+
+			if refused.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After header is missing")
+			}
+
+			if response := login(test.allowed); response.Code != http.StatusOK {
+				t.Errorf("login outside of limit = %d, want %d, body %s", response.Code, http.StatusOK, response.Body)
+			}
+		})
+	}
+}
@@ -5,31 +5,40 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
 	"github.com/khralenok/all-wallets-api/internal/logic"
 	"github.com/khralenok/all-wallets-api/internal/models"
 	"github.com/khralenok/all-wallets-api/internal/store"
 )
 
 // Create new wallet with provided name and currency and automatically create new wallet user with admin role based on user who call the function.
-func CreateWallet(context *gin.Context) {
+func (h *Handler) CreateWallet(context *gin.Context) {
 	userId := context.MustGet("userID").(int)
 
 	var input models.NewWalletRequest
 
-	if err := context.BindJSON(&input); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+	if err := context.ShouldBindJSON(&input); err != nil {
+		problem.InvalidInput(context, err)
 		return
 	}
 
-	newWallet, err := store.AddNewWallet(input, context)
+	if !h.validateCurrencyInput(input.Currency, context) {
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	newWallet, err := h.store.Wallets.Create(ctx, input)
 
 	if err != nil {
+		respondError(context, err, "Failed to insert new wallet data into the database")
 		return
 	}
 
-	newWalletUser, err := store.AddWalletUser(newWallet.ID, userId, "admin", context)
+	newWalletUser, err := h.store.WalletUsers.Add(ctx, newWallet.ID, userId, "admin")
 
 	if err != nil {
+		respondError(context, err, "Failed to insert new wallet user data into the database")
 		return
 	}
 
@@ -37,33 +46,39 @@ func CreateWallet(context *gin.Context) {
 }
 
 // Response with wallet data
-func GetWallet(context *gin.Context) {
-	_ = context.MustGet("userID").(int)
+func (h *Handler) GetWallet(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
 	walletID, err := strconv.Atoi(context.Param("id"))
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Id parameter should be integer"})
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Id parameter should be integer")
+		return
+	}
+
+	if !h.checkWalletMember(context, userID, walletID) {
 		return
 	}
 
-	// TO DO: Check if user have rights to see the wallet
+	ctx := context.Request.Context()
 
-	wallet, err := store.GetWalletByID(walletID)
+	wallet, err := h.store.Wallets.GetByID(ctx, walletID)
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Can't find such wallet"})
+		respondError(context, err, "Can't fetch wallet data")
 		return
 	}
 
-	latestTransactions, err := store.GetLatestTransactions(walletID)
+	latestTransactions, err := h.store.Transactions.GetLatest(ctx, walletID)
 
 	if err != nil {
+		respondError(context, err, "Can't get transactions data for this wallet")
 		return
 	}
 
-	decimalPlaces, err := store.GetWalletDecimalPlaces(walletID)
+	decimalPlaces, err := h.store.Wallets.GetDecimalPlaces(ctx, walletID)
 
 	if err != nil {
+		respondError(context, err, "Can't get wallet currency metadata")
 		return
 	}
 
@@ -71,30 +86,133 @@ func GetWallet(context *gin.Context) {
 
 	outputBalance := logic.FormatOutputValue(balance, decimalPlaces)
 
-	context.JSON(http.StatusOK, gin.H{"id": wallet.ID, "wallet_name": wallet.WalletName, "balance": outputBalance, "currency": wallet.Currency, "last_snapshot": wallet.LastSnapshot, "created_at": wallet.CreatedAt})
+	context.JSON(http.StatusOK, gin.H{"id": wallet.ID, "wallet_name": wallet.WalletName, "balance": outputBalance, "currency": wallet.Currency, "last_snapshot": wallet.LastSnapshot, "created_at": wallet.CreatedAt, "require_2fa": wallet.RequireTwoFactor})
 }
 
-// Delete the wallet and all it's users. Can be performed only by wallet user with admin role
-func DeleteWallet(context *gin.Context) {
+// Archive the wallet so it's hidden from all its users but can be restored during retention period. With "permanent=true" query parameter wallet is deleted right away together with all its transactions, recurrent payments and users. Can be performed only by wallet user with admin role
+func (h *Handler) DeleteWallet(context *gin.Context) {
 	userID := context.MustGet("userID").(int)
 	walletID, err := strconv.Atoi(context.Param("id"))
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
 		return
 	}
 
-	if !store.CheckUserPermissions(userID, walletID, context) {
+	permanent, err := strconv.ParseBool(context.DefaultQuery("permanent", "false"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Permanent parameter should be boolean")
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if err := h.store.WalletUsers.CheckAdmin(ctx, userID, walletID); err != nil {
+		respondError(context, err, "Failed to fetch supplicant user data")
 		return
 	}
 
-	if err := store.RemoveWalletUser(walletID, userID, context); err != nil {
+	if !h.checkWalletAccess(context, walletID) {
 		return
 	}
 
-	if err := store.RemoveWallet(walletID, context); err != nil {
+	if permanent {
+		if err := h.store.Wallets.HardDelete(ctx, walletID); err != nil {
+			respondError(context, err, "Can't delete this wallet")
+			return
+		}
+
+		context.Status(http.StatusNoContent)
 		return
 	}
 
-	context.JSON(http.StatusNoContent, gin.H{"status": "No content", "message": "Wallet User was successfully deleted"})
+	wallet, err := h.store.Wallets.Archive(ctx, walletID)
+
+	if err != nil {
+		respondError(context, err, "Can't archive this wallet")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "message": "Wallet was archived", "wallet_id": wallet.ID, "restorable_until": wallet.ArchivedAt.Add(store.ArchivedWalletRetention)})
+}
+
+// Make archived wallet visible to its users again. Can be performed only by wallet user with admin role
+func (h *Handler) RestoreWallet(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, err := strconv.Atoi(context.Param("id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	if err := h.store.WalletUsers.CheckAdmin(ctx, userID, walletID); err != nil {
+		respondError(context, err, "Failed to fetch supplicant user data")
+		return
+	}
+
+	if !h.checkWalletAccess(context, walletID) {
+		return
+	}
+
+	if err := h.store.Wallets.Restore(ctx, walletID); err != nil {
+		respondError(context, err, "Can't restore this wallet")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "Ok", "message": "Wallet was restored"})
+}
+
+// Return true if currency is listed in currency metadata, otherwise respond with problem.
+func (h *Handler) validateCurrencyInput(currency string, context *gin.Context) bool {
+	isAvailable, err := h.store.Currencies.IsAvailable(context.Request.Context(), currency)
+
+	if err != nil {
+		respondError(context, err, "Can't get currency metadata")
+		return false
+	}
+
+	if !isAvailable {
+		problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeUnsupportedCurrency, "This currency isn't supported")
+		return false
+	}
+
+	return true
+}
+
+// Return true if wallet is active and user is its member allowed to access it. Otherwise respond with problem.
+func (h *Handler) checkWalletMember(context *gin.Context, userID, walletID int) bool {
+	ctx := context.Request.Context()
+
+	if err := h.store.Wallets.EnsureActive(ctx, walletID); err != nil {
+		respondError(context, err, "Can't fetch wallet data")
+		return false
+	}
+
+	if err := h.store.WalletUsers.CheckMember(ctx, userID, walletID); err != nil {
+		respondError(context, err, "Failed to fetch supplicant user data")
+		return false
+	}
+
+	return h.checkWalletAccess(context, walletID)
+}
+
+// Return true if wallet is active and user is its admin allowed to access it. Otherwise respond with problem.
+func (h *Handler) checkWalletAdmin(context *gin.Context, userID, walletID int) bool {
+	ctx := context.Request.Context()
+
+	if err := h.store.Wallets.EnsureActive(ctx, walletID); err != nil {
+		respondError(context, err, "Can't fetch wallet data")
+		return false
+	}
+
+	if err := h.store.WalletUsers.CheckAdmin(ctx, userID, walletID); err != nil {
+		respondError(context, err, "Failed to fetch supplicant user data")
+		return false
+	}
+
+	return h.checkWalletAccess(context, walletID)
 }
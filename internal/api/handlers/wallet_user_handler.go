@@ -5,89 +5,97 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
 	"github.com/khralenok/all-wallets-api/internal/models"
-	"github.com/khralenok/all-wallets-api/internal/store"
 )
 
 // Create new wallet user with provided role, based on target wallet id and username of user who need to be added. Can be permormed only by wallet admin.
-func CreateWalletUser(context *gin.Context) {
+func (h *Handler) CreateWalletUser(context *gin.Context) {
 	userID := context.MustGet("userID").(int)
 
 	var input models.NewWalletUserRequest
 
-	if err := context.BindJSON(&input); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+	if err := bindWalletInput(context, &input, &input.WalletID); err != nil {
+		problem.InvalidInput(context, err)
 		return
 	}
 
-	if !store.CheckUserPermissions(userID, input.WalletID, context) {
+	if !h.checkWalletAdmin(context, userID, input.WalletID) {
 		return
 	}
 
-	newUserId := store.GetIdByUsername(input.Username, context)
+	ctx := context.Request.Context()
 
-	if newUserId == -1 {
-		return
-	}
+	newUserId, err := h.store.Users.GetIDByUsername(ctx, input.Username)
 
-	if !store.CheckWalletUserUnique(newUserId, input.WalletID, context) {
+	if err != nil {
+		respondError(context, err, "Can't fetch user data")
 		return
 	}
 
-	if !validateRoleInput(input.UserRole, context) {
+	if err := h.store.WalletUsers.CheckUnique(ctx, newUserId, input.WalletID); err != nil {
+		respondError(context, err, "Can't fetch wallet user data")
 		return
 	}
 
-	newWalletUser, err := store.AddWalletUser(input.WalletID, newUserId, input.UserRole, context)
+	newWalletUser, err := h.store.WalletUsers.Add(ctx, input.WalletID, newUserId, input.UserRole)
 
 	if err != nil {
+		respondError(context, err, "Failed to insert new wallet user data into the database")
 		return
 	}
 
+	h.notifyInvitation(context, userID, input.WalletID, newUserId, input.UserRole)
+
 	context.JSON(http.StatusCreated, gin.H{"new_wallet_user": newWalletUser})
 }
 
 // Remove user from list of wallet users, so it can gain access to wallet anymore. Wallet ID and Username must be provided via url query
-func DeleteWalletUser(context *gin.Context) {
+func (h *Handler) DeleteWalletUser(context *gin.Context) {
 	userID := context.MustGet("userID").(int)
-	walletID, err := strconv.Atoi(context.Param("wallet_id"))
+	walletID, err := strconv.Atoi(context.Param("id"))
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
 		return
 	}
 
 	username := context.Param("username")
 
 	if username == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "Invalid input format"})
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
 		return
 	}
 
-	if !store.CheckUserPermissions(userID, walletID, context) {
+	if !h.checkWalletAdmin(context, userID, walletID) {
 		return
 	}
 
-	userToDeleteID := store.GetIdByUsername(username, context)
+	ctx := context.Request.Context()
 
-	if userToDeleteID == -1 {
+	userToDeleteID, err := h.store.Users.GetIDByUsername(ctx, username)
+
+	if err != nil {
+		respondError(context, err, "Can't fetch user data")
 		return
 	}
 
-	if err := store.RemoveWalletUser(walletID, userToDeleteID, context); err != nil {
+	isLastAdmin, err := h.store.WalletUsers.IsLastAdmin(ctx, userToDeleteID, walletID)
+
+	if err != nil {
+		respondError(context, err, "Can't fetch wallet admins")
 		return
 	}
 
-	context.JSON(http.StatusNoContent, gin.H{"status": "No content", "message": "Wallet User was successfully deleted"})
-}
-
-func validateRoleInput(userRole string, context *gin.Context) bool {
-	validRoles := map[string]bool{"admin": true, "user": true, "spectator": true}
+	if isLastAdmin {
+		problem.Respond(context, http.StatusConflict, problem.CodeLastAdmin, "Wallet can't be left without admin. Assign another admin or delete the wallet")
+		return
+	}
 
-	if !validRoles[userRole] {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "User role value is unacceptable"})
-		return false
+	if err := h.store.WalletUsers.Remove(ctx, walletID, userToDeleteID); err != nil {
+		respondError(context, err, "Can't delete this user")
+		return
 	}
 
-	return true
+	context.Status(http.StatusNoContent)
 }
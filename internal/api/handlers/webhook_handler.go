@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/webhook"
+)
+
+// Subscribe URL to events of the wallet. Secret payloads are signed with is shown only once. Can be performed only by wallet admin.
+func (h *Handler) CreateWebhook(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+
+	var input models.NewWebhookInput
+
+	if err := bindWalletInput(context, &input, &input.WalletID); err != nil {
+		problem.InvalidInput(context, err)
+		return
+	}
+
+	if !h.checkWalletAdmin(context, userID, input.WalletID) {
+		return
+	}
+
+	secret := input.Secret
+
+	if secret == "" {
+		generated, err := webhook.GenerateSecret()
+
+		if err != nil {
+			respondError(context, err, "Can't generate webhook secret")
+			return
+		}
+
+		secret = generated
+	}
+
+	newWebhook := models.Webhook{WalletID: input.WalletID, CreatorID: userID, URL: input.URL, EventTypes: input.EventTypes}
+
+	created, err := h.store.Webhooks.Create(context.Request.Context(), newWebhook, secret)
+
+	if err != nil {
+		respondError(context, err, "Can't save webhook")
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"webhook": created, "secret": secret})
+}
+
+// Response with all webhooks of the wallet. Can be performed only by wallet admin.
+func (h *Handler) GetWebhooks(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, err := strconv.Atoi(context.Param("id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	if !h.checkWalletAdmin(context, userID, walletID) {
+		return
+	}
+
+	webhooks, err := h.store.Webhooks.GetByWallet(context.Request.Context(), walletID)
+
+	if err != nil {
+		respondError(context, err, "Can't get webhooks from database")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+// Delete webhook, deliveries that weren't sent yet are dropped. Can be performed only by wallet admin.
+func (h *Handler) DeleteWebhook(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, webhookID, ok := webhookParams(context)
+
+	if !ok {
+		return
+	}
+
+	if !h.checkWalletAdmin(context, userID, walletID) {
+		return
+	}
+
+	if err := h.store.Webhooks.Delete(context.Request.Context(), walletID, webhookID); err != nil {
+		respondError(context, err, "Can't delete webhook")
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// Response with latest deliveries of webhook, their status, attempts and last receiver answer. Can be performed only by wallet admin.
+func (h *Handler) GetWebhookDeliveries(context *gin.Context) {
+	userID := context.MustGet("userID").(int)
+	walletID, webhookID, ok := webhookParams(context)
+
+	if !ok {
+		return
+	}
+
+	if !h.checkWalletAdmin(context, userID, walletID) {
+		return
+	}
+
+	deliveries, err := h.store.Webhooks.GetDeliveries(context.Request.Context(), walletID, webhookID)
+
+	if err != nil {
+		respondError(context, err, "Can't get webhook deliveries from database")
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// Return wallet and webhook ids from path. Otherwise respond with problem.
+func webhookParams(context *gin.Context) (int, int, bool) {
+	walletID, err := strconv.Atoi(context.Param("id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return 0, 0, false
+	}
+
+	webhookID, err := strconv.Atoi(context.Param("webhook_id"))
+
+	if err != nil {
+		problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Invalid input format")
+		return 0, 0, false
+	}
+
+	return walletID, webhookID, true
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/apikey"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+const apiKeyScopeKey = "apiKeyScope"
+
+// Allow route to be called with API key having provided scope. Must be placed before auth middleware. Routes without it accept only JWT tokens.
+func AllowAPIKey(scope string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Set(apiKeyScopeKey, scope)
+		context.Next()
+	}
+}
+
+// Return API key request was authenticated with, if any
+func APIKey(context *gin.Context) (models.APIKey, bool) {
+	key, ok := context.Get("apiKey")
+
+	if !ok {
+		return models.APIKey{}, false
+	}
+
+	return key.(models.APIKey), true
+}
+
+// Authenticate request by API key and put its owner id and the key into context
+func authenticateAPIKey(context *gin.Context, apiKeys store.APIKeyRepository, key string) {
+	ctx := context.Request.Context()
+
+	keyID, ok := apikey.ID(key)
+
+	if !ok {
+		problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid API key")
+		return
+	}
+
+	apiKey, keyHash, err := apiKeys.GetActive(ctx, keyID)
+
+	if err != nil || subtle.ConstantTimeCompare([]byte(keyHash), []byte(apikey.Hash(key))) != 1 {
+		problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid API key")
+		return
+	}
+
+	scope := context.GetString(apiKeyScopeKey)
+
+	if scope == "" {
+		problem.Respond(context, http.StatusForbidden, problem.CodeAPIKeyNotAllowed, "This endpoint can't be used with API key")
+		return
+	}
+
+	if !apiKey.HasScope(scope) {
+		problem.Respond(context, http.StatusForbidden, problem.CodeInsufficientScope, "API key lacks "+scope+" scope")
+		return
+	}
+
+	if err := apiKeys.Touch(ctx, apiKey.ID); err != nil {
+		slog.WarnContext(ctx, "Can't update API key last use", "key_id", apiKey.ID, "error", err)
+	}
+
+	context.Set("userID", apiKey.UserID)
+	context.Set("twoFactor", apiKey.TwoFactor)
+	context.Set("apiKey", apiKey)
+	context.Next()
+}
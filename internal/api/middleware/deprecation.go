@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// When routes outside of /api/v1 were deprecated
+var LegacyRoutesDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// Mark response of legacy route as deprecated (RFC 9745) and link route replacing it. Successor params like :id are filled from the request, successor isn't linked if some of them are missing.
+func Deprecated(successor string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(LegacyRoutesDeprecatedAt.Unix(), 10)
+
+	return func(context *gin.Context) {
+		context.Header("Deprecation", deprecation)
+
+		links := []string{`</openapi.json>; rel="deprecation"`}
+
+		if path, ok := fillParams(context, successor); ok {
+			links = append(links, "<"+path+`>; rel="successor-version"`)
+		}
+
+		context.Header("Link", strings.Join(links, ", "))
+		context.Next()
+	}
+}
+
+func fillParams(context *gin.Context, route string) (string, bool) {
+	segments := strings.Split(route, "/")
+
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+
+		value := context.Param(segment[1:])
+
+		if value == "" {
+			return "", false
+		}
+
+		segments[i] = value
+	}
+
+	return strings.Join(segments, "/"), true
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"bytes"
+	stdcontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// Make route safe to retry. If request carries Idempotency-Key header, response is saved for the user and the key, and retries with the same key get the saved response instead of being served again. Must be placed after auth middleware.
+func Idempotency(keys store.IdempotencyRepository) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := context.GetHeader(IdempotencyKeyHeader)
+
+		if key == "" {
+			context.Next()
+			return
+		}
+
+		if !validIdempotencyKey(key) {
+			problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Idempotency-Key must be 1-255 printable ASCII characters")
+			return
+		}
+
+		body, err := io.ReadAll(context.Request.Body)
+
+		// Body over the limit set by earlier middleware is refused anyway. Key isn't claimed and handler reports it the way it reports other too large bodies.
+		var tooLarge *http.MaxBytesError
+
+		if errors.As(err, &tooLarge) {
+			context.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), failingReader{err}))
+			context.Next()
+			return
+		}
+
+		if err != nil {
+			problem.Respond(context, http.StatusBadRequest, problem.CodeInvalidInput, "Can't read request body")
+			return
+		}
+
+		context.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := context.Request.Context()
+		userID := context.MustGet("userID").(int)
+		requestHash := fingerprint(context.Request, body)
+
+		claimed, saved, err := keys.Begin(ctx, userID, key, requestHash)
+
+		if err != nil {
+			slog.ErrorContext(ctx, "Can't claim idempotency key", "error", err)
+			problem.Internal(context, "Can't check idempotency key")
+			return
+		}
+
+		if !claimed {
+			replay(context, saved, requestHash)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: context.Writer}
+		context.Writer = recorder
+		completed := false
+
+		// Key must be released or completed even if client disconnected meanwhile, otherwise its retries are refused as in progress until the key expires
+		keyCtx := stdcontext.WithoutCancel(ctx)
+
+		// Failed or panicked request didn't change anything worth remembering, so key is released for retry
+		defer func() {
+			if completed {
+				return
+			}
+
+			if err := keys.Release(keyCtx, userID, key); err != nil {
+				slog.ErrorContext(keyCtx, "Can't release idempotency key", "error", err)
+			}
+		}()
+
+		context.Next()
+
+		if !keepsResponse(recorder.Status()) {
+			return
+		}
+
+		// Response was already sent, so the key is never released from here on, even if saving fails. Otherwise retry could repeat the action.
+		completed = true
+
+		response := models.IdempotentResponse{
+			StatusCode:  recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+
+		if err := keys.Complete(keyCtx, userID, key, response); err != nil {
+			slog.ErrorContext(keyCtx, "Can't save idempotent response", "error", err)
+		}
+	}
+}
+
+// Return true if response must be replayed to retries. Malformed or invalid request gets the same answer anyway. Server errors and other client errors, e.g. missing funds or permissions, depend on state that may change, so their key is released and retry is served again.
+func keepsResponse(status int) bool {
+	return status <= http.StatusBadRequest || status == http.StatusUnprocessableEntity
+}
+
+// Respond to retried request with saved response
+func replay(context *gin.Context, saved models.IdempotentResponse, requestHash string) {
+	if saved.RequestHash != "" && saved.RequestHash != requestHash {
+		problem.Respond(context, http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused, "Idempotency-Key was already used with another request")
+		return
+	}
+
+	if saved.StatusCode == 0 {
+		problem.Respond(context, http.StatusConflict, problem.CodeRequestInProgress, "Request with this Idempotency-Key is still being processed, retry later")
+		return
+	}
+
+	context.Header(IdempotentReplayedHeader, "true")
+	context.Data(saved.StatusCode, saved.ContentType, saved.Body)
+	context.Abort()
+}
+
+// Return hash identifying request, so the key can't be reused for different one
+func fingerprint(request *http.Request, body []byte) string {
+	hash := sha256.New()
+
+	hash.Write([]byte(request.Method + " " + request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Copy everything written to response, so it can be saved
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	r.body.WriteString(data)
+
+	return r.ResponseWriter.WriteString(data)
+}
+
+// Reader returning the error body was cut with
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
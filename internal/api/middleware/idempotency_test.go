@@ -0,0 +1,130 @@
+package middleware_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/store/storetest"
+)
+
+// Serve idempotent route answering with provided statuses in turn and return function sending request to it with the key
+func newIdempotentRoute(t *testing.T, statuses ...int) (func(key, body string) *httptest.ResponseRecorder, *int) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	served := 0
+
+	router := gin.New()
+	router.POST("/wallets",
+		func(context *gin.Context) { context.Set("userID", 1) },
+		middleware.Idempotency(storetest.New().Idempotency),
+		func(context *gin.Context) {
+			status := statuses[min(served, len(statuses)-1)]
+			served++
+			context.JSON(status, gin.H{"attempt": served})
+		},
+	)
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/wallets", strings.NewReader(body))
+		request.Header.Set(middleware.IdempotencyKeyHeader, key)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	return send, &served
+}
+
+func TestIdempotencyKeepsOrReleasesKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    int
+		replayed bool
+	}{
+		{"created", http.StatusCreated, true},
+		{"malformed request", http.StatusBadRequest, true},
+		{"invalid request", http.StatusUnprocessableEntity, true},
+		{"forbidden", http.StatusForbidden, false},
+		{"not found", http.StatusNotFound, false},
+		{"conflict", http.StatusConflict, false},
+		{"server error", http.StatusInternalServerError, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			send, served := newIdempotentRoute(t, test.first, http.StatusCreated)
+
+			first := send("key-1", `{"wallet_name": "Family"}`)
+			retry := send("key-1", `{"wallet_name": "Family"}`)
+
+			if first.Code != test.first {
+				t.Fatalf("first status = %d, want %d", first.Code, test.first)
+			}
+
+			if test.replayed {
+				if retry.Code != test.first || retry.Header().Get(middleware.IdempotentReplayedHeader) != "true" || retry.Body.String() != first.Body.String() {
+					t.Errorf("retry = %d %s, want replayed %d %s", retry.Code, retry.Body, first.Code, first.Body)
+				}
+
+				if *served != 1 {
+					t.Errorf("handler served %d times, want 1", *served)
+				}
+
+				return
+			}
+
+			if retry.Code != http.StatusCreated || retry.Header().Get(middleware.IdempotentReplayedHeader) != "" {
+				t.Errorf("retry = %d replayed %q, want served again with %d", retry.Code, retry.Header().Get(middleware.IdempotentReplayedHeader), http.StatusCreated)
+			}
+
+			if *served != 2 {
+				t.Errorf("handler served %d times, want 2", *served)
+			}
+		})
+	}
+}
+
+func TestIdempotencyLeavesTooLargeBodyToHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var handlerErr error
+
+	router := gin.New()
+	router.POST("/attachments",
+		func(context *gin.Context) { context.Set("userID", 1) },
+		func(context *gin.Context) {
+			context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, 8)
+		},
+		middleware.Idempotency(storetest.New().Idempotency),
+		func(context *gin.Context) {
+			_, handlerErr = io.ReadAll(context.Request.Body)
+			context.Status(http.StatusRequestEntityTooLarge)
+		},
+	)
+
+	request := httptest.NewRequest(http.MethodPost, "/attachments", strings.NewReader("larger than eight bytes"))
+	request.Header.Set(middleware.IdempotencyKeyHeader, "key-1")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var tooLarge *http.MaxBytesError
+
+	if !errors.As(handlerErr, &tooLarge) {
+		t.Errorf("handler read error = %v, want *http.MaxBytesError", handlerErr)
+	}
+
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusRequestEntityTooLarge)
+	}
+}
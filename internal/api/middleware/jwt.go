@@ -1,58 +1,151 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/apikey"
+	"github.com/khralenok/all-wallets-api/internal/store"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+// How long user has to enter second factor code after password was accepted
+const ChallengeTTL = 5 * time.Minute
 
-func GenerateJWT(userID int) (string, error) {
+const challengePurpose = "2fa_challenge"
+
+var errInvalidToken = errors.New("invalid token")
+
+// JWT signs and verifies user tokens with configured secret.
+type JWT struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// Constructor function for JWT
+func NewJWT(secret string, ttl time.Duration) *JWT {
+	return &JWT{secret: []byte(secret), ttl: ttl}
+}
+
+// Sign token for user. Token is valid until expiration or until user token version is bumped, e.g. after password change. twoFactor tells if user passed second factor, wallets requiring it accept only such tokens.
+func (j *JWT) Generate(userID, tokenVersion int, twoFactor bool) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"user_id":       userID,
+		"token_version": tokenVersion,
+		"mfa":           twoFactor,
+		"exp":           time.Now().Add(j.ttl).Unix(),
 	})
-	return token.SignedString(jwtSecret)
+	return token.SignedString(j.secret)
+}
+
+// Sign short-lived token proving that password was accepted. It can only be exchanged for real token together with second factor code.
+func (j *JWT) GenerateChallenge(userID, tokenVersion int) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":       userID,
+		"token_version": tokenVersion,
+		"purpose":       challengePurpose,
+		"exp":           time.Now().Add(ChallengeTTL).Unix(),
+	})
+	return token.SignedString(j.secret)
+}
+
+// Return user id and token version from valid challenge token
+func (j *JWT) ParseChallenge(tokenString string) (int, int, error) {
+	claims, err := j.parse(tokenString)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if purpose, _ := claims["purpose"].(string); purpose != challengePurpose {
+		return 0, 0, errInvalidToken
+	}
+
+	return claimsUser(claims)
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+// Authenticate request by bearer token or API key and put user id into context. Tokens of deleted users or with outdated token version are rejected, as well as challenge tokens.
+func (j *JWT) AuthMiddleware(users store.UserRepository, apiKeys store.APIKeyRepository) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		authHeader := context.GetHeader("Authorization")
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
 		if tokenString == "" {
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
-			context.Abort()
+			problem.Respond(context, http.StatusUnauthorized, problem.CodeUnauthorized, "Authorization failed")
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-			return jwtSecret, nil
-		})
+		if apikey.IsKey(tokenString) {
+			authenticateAPIKey(context, apiKeys, tokenString)
+			return
+		}
+
+		claims, err := j.parse(tokenString)
 
-		if err != nil || !token.Valid {
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "token": token.Valid})
-			context.Abort()
+		if err != nil {
+			problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token")
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			context.Abort()
+		if _, isChallenge := claims["purpose"]; isChallenge {
+			problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token")
 			return
 		}
 
-		context.Set("userID", int(claims["user_id"].(float64)))
+		userID, tokenVersion, err := claimsUser(claims)
+
+		if err != nil {
+			problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token")
+			return
+		}
+
+		currentTokenVersion, err := users.GetTokenVersion(context.Request.Context(), userID)
+
+		if err != nil || currentTokenVersion != tokenVersion {
+			problem.Respond(context, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token")
+			return
+		}
+
+		twoFactor, _ := claims["mfa"].(bool)
+
+		context.Set("userID", userID)
+		context.Set("twoFactor", twoFactor)
 		context.Next()
 	}
 }
+
+func (j *JWT) parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return j.secret, nil
+	})
+
+	if err != nil || !token.Valid {
+		return nil, errInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errInvalidToken
+	}
+
+	return claims, nil
+}
+
+func claimsUser(claims jwt.MapClaims) (int, int, error) {
+	rawUserID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, 0, errInvalidToken
+	}
+
+	tokenVersion, _ := claims["token_version"].(float64)
+
+	return int(rawUserID), int(tokenVersion), nil
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/logging"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Incoming request ids are accepted only if they can't break log format
+var requestIDPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// Take request id from X-Request-ID header or generate new one. Request id is returned in response header and stored in request context, so it reaches store calls and every log record.
+func RequestID() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		requestID := context.GetHeader(RequestIDHeader)
+
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = logging.NewID()
+		}
+
+		context.Header(RequestIDHeader, requestID)
+		context.Request = context.Request.WithContext(logging.WithRequestID(context.Request.Context(), requestID))
+		context.Next()
+	}
+}
+
+// Write one structured record per request. Query string isn't logged, because it may contain secrets.
+func AccessLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+
+		context.Next()
+
+		status := context.Writer.Status()
+		level := slog.LevelInfo
+
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(context.Request.Context(), level, "request",
+			slog.String("method", context.Request.Method),
+			slog.String("route", context.FullPath()),
+			slog.String("path", context.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", context.ClientIP()),
+			slog.Int("response_size", context.Writer.Size()),
+		)
+	}
+}
+
+// Turn panic in handler into internal error response and log it with stack trace.
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logger.ErrorContext(context.Request.Context(), "panic recovered", "panic", recovered, "stack", string(debug.Stack()))
+				problem.Internal(context, "Unexpected server error")
+			}
+		}()
+
+		context.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/metrics"
+)
+
+// Record duration of every request. Route template is used as label instead of path, so ids don't blow up number of series.
+func Metrics() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		context.Next()
+
+		route := context.FullPath()
+
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HTTPRequestDuration.
+			WithLabelValues(context.Request.Method, route, strconv.Itoa(context.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/problem"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+)
+
+// Limit requests from one client IP. Name separates buckets of different endpoints. If limiter backend fails, request is let through, so database problems don't lock everybody out.
+func RateLimitByIP(limiter ratelimit.Limiter, name string, rate ratelimit.Rate) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		ctx := context.Request.Context()
+
+		wait, err := limiter.Take(ctx, name+":ip:"+context.ClientIP(), rate)
+
+		if err != nil {
+			slog.ErrorContext(ctx, "Rate limiter failed", "error", err, "limit", name)
+			context.Next()
+			return
+		}
+
+		if wait > 0 {
+			problem.TooManyRequests(context, problem.CodeRateLimited, "Too many requests, try again later", wait)
+			return
+		}
+
+		context.Next()
+	}
+}
@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Prefix of routes which must all be described in the document
+const VersionPrefix = "/api/v1"
+
+//go:embed openapi.json
+var Document []byte
+
+var pathParamPattern = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// Serve OpenAPI document
+func Serve(context *gin.Context) {
+	context.Data(http.StatusOK, "application/json", Document)
+}
+
+// Return error listing mismatches between the document and registered routes: every documented operation must be routed and every /api/v1 route must be documented.
+func CheckRoutes(routes gin.RoutesInfo) error {
+	documented, err := operations()
+
+	if err != nil {
+		return err
+	}
+
+	routed := make(map[string]bool, len(routes))
+
+	for _, route := range routes {
+		routed[route.Method+" "+route.Path] = true
+	}
+
+	var problems []string
+
+	for operation := range documented {
+		if !routed[operation] {
+			problems = append(problems, "documented but not routed: "+operation)
+		}
+	}
+
+	for operation := range routed {
+		_, path, _ := strings.Cut(operation, " ")
+
+		if strings.HasPrefix(path, VersionPrefix+"/") && !documented[operation] {
+			problems = append(problems, "routed but not documented: "+operation)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// Return documented operations as "METHOD /path/:param", the way gin reports routes
+func operations() (map[string]bool, error) {
+	var document struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	if err := json.Unmarshal(Document, &document); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	result := make(map[string]bool)
+
+	for path, item := range document.Paths {
+		ginPath := pathParamPattern.ReplaceAllString(path, ":$1")
+
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				result[strings.ToUpper(method)+" "+ginPath] = true
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,180 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Stable machine-readable error codes. Clients should rely on them rather than on detail messages, which may change.
+const (
+	CodeInvalidInput         = "invalid_input"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidToken         = "invalid_token"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeUserNotFound         = "user_not_found"
+	CodeWalletNotFound       = "wallet_not_found"
+	CodeUsernameTaken        = "username_taken"
+	CodeAlreadyWalletUser    = "already_wallet_user"
+	CodeLastAdmin            = "last_admin"
+	CodeUnresolvedWallets    = "unresolved_wallets"
+	CodeInvalidHandover      = "invalid_handover"
+	CodeUnsupportedCurrency  = "unsupported_currency"
+	CodeAmountPrecision      = "amount_precision"
+	CodeAmountTooLarge       = "amount_too_large"
+	CodeSplitSumMismatch     = "split_sum_mismatch"
+	CodeInsufficientFunds    = "insufficient_funds"
+	CodeAttachmentTooLarge   = "attachment_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeRateLimited          = "rate_limited"
+	CodeWeakPassword         = "weak_password"
+	CodeTwoFactorRequired    = "two_factor_required"
+	CodeInvalidTwoFactor     = "invalid_two_factor_code"
+	CodeTwoFactorEnabled     = "two_factor_enabled"
+	CodeAccountLocked        = "account_locked"
+	CodeAPIKeyNotAllowed     = "api_key_not_allowed"
+	CodeInsufficientScope    = "insufficient_scope"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeRequestInProgress    = "request_in_progress"
+	CodeInternal             = "internal_error"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem details object as described in RFC 7807 extended with stable error code, field errors and optional extension members.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Code       string
+	Errors     []FieldError
+	Extensions map[string]any
+}
+
+// Describe single invalid field of request body.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// Constructor function for problem with provided HTTP status and error code
+func New(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "urn:all-wallets:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Add extension member to problem object. Extension members can't override standard ones.
+func (p Problem) With(key string, value any) Problem {
+	extensions := make(map[string]any, len(p.Extensions)+1)
+
+	for k, v := range p.Extensions {
+		extensions[k] = v
+	}
+
+	extensions[key] = value
+	p.Extensions = extensions
+
+	return p
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	body := make(map[string]any, len(p.Extensions)+7)
+
+	for key, value := range p.Extensions {
+		body[key] = value
+	}
+
+	body["type"] = p.Type
+	body["title"] = p.Title
+	body["status"] = p.Status
+	body["code"] = p.Code
+
+	if p.Detail != "" {
+		body["detail"] = p.Detail
+	}
+
+	if p.Instance != "" {
+		body["instance"] = p.Instance
+	}
+
+	if len(p.Errors) > 0 {
+		body["errors"] = p.Errors
+	}
+
+	return json.Marshal(body)
+}
+
+// Write problem to response and abort the rest of handlers chain.
+func Write(context *gin.Context, p Problem) {
+	if p.Instance == "" {
+		p.Instance = context.Request.URL.Path
+	}
+
+	body, err := json.Marshal(p)
+
+	if err != nil {
+		context.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	context.Data(p.Status, ContentType, body)
+	context.Abort()
+}
+
+// Write problem with provided HTTP status and error code to response.
+func Respond(context *gin.Context, status int, code, detail string) {
+	Write(context, New(status, code, detail))
+}
+
+// Write internal error to response. Underlying error is never exposed to client.
+func Internal(context *gin.Context, detail string) {
+	Respond(context, http.StatusInternalServerError, CodeInternal, detail)
+}
+
+// Write 429 response telling client when to retry. Retry-After is rounded up to whole seconds.
+func TooManyRequests(context *gin.Context, code, detail string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+
+	context.Header("Retry-After", strconv.Itoa(seconds))
+	Write(context, New(http.StatusTooManyRequests, code, detail).With("retry_after", seconds))
+}
+
+// Write response for request which can't be bound. Validation errors are reported per field.
+func InvalidInput(context *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+
+	if !errors.As(err, &validationErrors) {
+		Respond(context, http.StatusBadRequest, CodeInvalidInput, "Invalid input format")
+		return
+	}
+
+	p := New(http.StatusUnprocessableEntity, CodeValidationFailed, "Some fields are invalid")
+
+	for _, fieldError := range validationErrors {
+		// Namespace starts with name of the input struct, which means nothing to client
+		_, field, _ := strings.Cut(fieldError.Namespace(), ".")
+
+		p.Errors = append(p.Errors, FieldError{Field: field, Rule: fieldError.Tag(), Param: fieldError.Param()})
+	}
+
+	Write(context, p)
+}
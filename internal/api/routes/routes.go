@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/handlers"
+	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/api/openapi"
+	"github.com/khralenok/all-wallets-api/internal/config"
+	"github.com/khralenok/all-wallets-api/internal/metrics"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Group of middlewares routes are built from
+type routeMiddlewares struct {
+	auth        gin.HandlerFunc
+	idempotent  gin.HandlerFunc
+	loginLimit  gin.HandlerFunc
+	signinLimit gin.HandlerFunc
+}
+
+// Register all API routes. Resource-oriented routes live under /api/v1 and are described in OpenAPI document, older verb-based routes are kept as deprecated aliases.
+func Register(router *gin.Engine, h *handlers.Handler, auth gin.HandlerFunc, idempotent gin.HandlerFunc, limiter ratelimit.Limiter, rates config.RateLimitConfig) {
+	m := routeMiddlewares{
+		auth:       auth,
+		idempotent: idempotent,
+		// Legacy and v1 routes share buckets, so aliases don't double the limits
+		loginLimit:  middleware.RateLimitByIP(limiter, "login", rates.LoginPerIP),
+		signinLimit: middleware.RateLimitByIP(limiter, "signin", rates.SigninPerIP),
+	}
+
+	//Probes
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+	router.GET("/openapi.json", openapi.Serve)
+
+	registerV1Routes(router.Group("/api/v1"), h, m)
+	registerLegacyRoutes(router, h, m)
+}
+
+func registerV1Routes(v1 *gin.RouterGroup, h *handlers.Handler, m routeMiddlewares) {
+	auth, idempotent := m.auth, m.idempotent
+
+	//Users and Sessions
+	v1.POST("/users", m.signinLimit, h.CreateUser)
+	v1.POST("/users/restore", m.loginLimit, h.RestoreUser)
+	v1.POST("/sessions", m.loginLimit, h.LoginUser)
+	v1.POST("/sessions/2fa", m.loginLimit, h.CompleteTwoFactorLogin)
+
+	//Current User
+	v1.GET("/me", auth, h.GetProfile)
+	v1.GET("/me/dashboard", auth, h.GetDashboard)
+	v1.DELETE("/me", auth, idempotent, h.DeleteUser)
+	v1.PUT("/me/password", auth, h.ChangePassword)
+	v1.PUT("/me/username", auth, idempotent, h.ChangeUsername)
+	v1.PUT("/me/base-currency", auth, idempotent, h.ChangeBaseCurrency)
+	v1.POST("/me/2fa", auth, h.EnrollTwoFactor)
+	v1.DELETE("/me/2fa", auth, h.DisableTwoFactor)
+	v1.POST("/me/2fa/confirm", auth, h.ConfirmTwoFactor)
+	v1.POST("/me/2fa/recovery-codes", auth, h.RegenerateRecoveryCodes)
+	v1.POST("/me/api-keys", auth, idempotent, h.CreateAPIKey)
+	v1.GET("/me/api-keys", auth, h.GetAPIKeys)
+	v1.DELETE("/me/api-keys/:id", auth, idempotent, h.RevokeAPIKey)
+	v1.GET("/me/notification-preferences", auth, h.GetNotificationPreferences)
+	v1.PUT("/me/notification-preferences", auth, idempotent, h.UpdateNotificationPreferences)
+
+	//Notifications
+	v1.GET("/notifications", auth, h.GetNotifications)
+	v1.POST("/notifications/read", auth, idempotent, h.MarkAllNotificationsRead)
+	v1.POST("/notifications/:id/read", auth, idempotent, h.MarkNotificationRead)
+
+	//Wallets
+	v1.POST("/wallets", auth, idempotent, h.CreateWallet)
+	v1.GET("/wallets/:id", middleware.AllowAPIKey(models.ScopeRead), auth, h.GetWallet)
+	v1.DELETE("/wallets/:id", auth, idempotent, h.DeleteWallet)
+	v1.POST("/wallets/:id/restore", auth, idempotent, h.RestoreWallet)
+	v1.PUT("/wallets/:id/require-2fa", auth, idempotent, h.SetWalletRequireTwoFactor)
+	v1.GET("/wallets/:id/events", middleware.AllowAPIKey(models.ScopeRead), auth, h.StreamWalletEvents)
+
+	//Wallet Members
+	v1.POST("/wallets/:id/members", auth, idempotent, h.CreateWalletUser)
+	v1.DELETE("/wallets/:id/members/:username", auth, idempotent, h.DeleteWalletUser)
+
+	//Webhooks
+	v1.POST("/wallets/:id/webhooks", auth, idempotent, h.CreateWebhook)
+	v1.GET("/wallets/:id/webhooks", auth, h.GetWebhooks)
+	v1.DELETE("/wallets/:id/webhooks/:webhook_id", auth, idempotent, h.DeleteWebhook)
+	v1.GET("/wallets/:id/webhooks/:webhook_id/deliveries", auth, h.GetWebhookDeliveries)
+
+	//Transactions
+	v1.GET("/wallets/:id/transactions", middleware.AllowAPIKey(models.ScopeRead), auth, h.GetWalletTransactions)
+	v1.POST("/wallets/:id/transactions", middleware.AllowAPIKey(models.ScopeTransactionsWrite), auth, idempotent, func(context *gin.Context) { h.CreateTransaction(context, "") })
+	v1.PUT("/wallets/:id/transactions/:transaction_id", middleware.AllowAPIKey(models.ScopeTransactionsWrite), auth, idempotent, h.UpdateTransaction)
+	v1.POST("/wallets/:id/transactions/:transaction_id/attachments", middleware.AllowAPIKey(models.ScopeTransactionsWrite), auth, h.LimitAttachmentSize, idempotent, h.UploadAttachment)
+	v1.GET("/wallets/:id/transactions/:transaction_id/attachments/:attachment_id", middleware.AllowAPIKey(models.ScopeRead), auth, h.DownloadAttachment)
+	v1.DELETE("/wallets/:id/transactions/:transaction_id/attachments/:attachment_id", auth, idempotent, h.DeleteAttachment)
+
+	//Reports
+	v1.GET("/wallets/:id/reports/categories", middleware.AllowAPIKey(models.ScopeRead), auth, h.GetCategoryReport)
+
+	//Search
+	v1.GET("/search", middleware.AllowAPIKey(models.ScopeRead), auth, h.Search)
+
+	//Currencies
+	v1.GET("/currencies", middleware.AllowAPIKey(models.ScopeRead), auth, h.GetCurrencies)
+	v1.GET("/exchange-rates", middleware.AllowAPIKey(models.ScopeRead), auth, h.GetExchangeRate)
+}
+
+func registerLegacyRoutes(router *gin.Engine, h *handlers.Handler, m routeMiddlewares) {
+	auth, idempotent := m.auth, m.idempotent
+	deprecated := middleware.Deprecated
+
+	//User Management
+	router.POST("/signin", deprecated("/api/v1/users"), m.signinLimit, h.CreateUser)
+	router.POST("/login", deprecated("/api/v1/sessions"), m.loginLimit, h.LoginUser)
+	router.GET("/profile", deprecated("/api/v1/me"), auth, h.GetProfile)
+	router.PUT("/delete-user", deprecated("/api/v1/me"), auth, idempotent, h.DeleteUser)
+	router.POST("/restore-user", deprecated("/api/v1/users/restore"), m.loginLimit, h.RestoreUser)
+	router.PUT("/change-password", deprecated("/api/v1/me/password"), auth, h.ChangePassword)
+	router.PUT("/change-username", deprecated("/api/v1/me/username"), auth, idempotent, h.ChangeUsername)
+	router.PUT("/change-base-currency", deprecated("/api/v1/me/base-currency"), auth, idempotent, h.ChangeBaseCurrency)
+
+	//Two-Factor Authentication
+	router.POST("/login/2fa", deprecated("/api/v1/sessions/2fa"), m.loginLimit, h.CompleteTwoFactorLogin)
+	router.POST("/2fa/enroll", deprecated("/api/v1/me/2fa"), auth, h.EnrollTwoFactor)
+	router.POST("/2fa/confirm", deprecated("/api/v1/me/2fa/confirm"), auth, h.ConfirmTwoFactor)
+	router.POST("/2fa/disable", deprecated("/api/v1/me/2fa"), auth, h.DisableTwoFactor)
+	router.POST("/2fa/recovery-codes", deprecated("/api/v1/me/2fa/recovery-codes"), auth, h.RegenerateRecoveryCodes)
+
+	//API Keys
+	router.POST("/api-keys", deprecated("/api/v1/me/api-keys"), auth, idempotent, h.CreateAPIKey)
+	router.GET("/api-keys", deprecated("/api/v1/me/api-keys"), auth, h.GetAPIKeys)
+	router.DELETE("/api-keys/:id", deprecated("/api/v1/me/api-keys/:id"), auth, idempotent, h.RevokeAPIKey)
+
+	//Wallets Management
+	router.POST("/new-wallet", deprecated("/api/v1/wallets"), auth, idempotent, h.CreateWallet)
+	router.GET("/wallet/:id", deprecated("/api/v1/wallets/:id"), middleware.AllowAPIKey(models.ScopeRead), auth, h.GetWallet)
+	router.DELETE("/delete-wallet/:id", deprecated("/api/v1/wallets/:id"), auth, idempotent, h.DeleteWallet)
+	router.PUT("/restore-wallet/:id", deprecated("/api/v1/wallets/:id/restore"), auth, idempotent, h.RestoreWallet)
+	router.PUT("/wallet/:id/require-2fa", deprecated("/api/v1/wallets/:id/require-2fa"), auth, idempotent, h.SetWalletRequireTwoFactor)
+
+	//Wallet Users Management
+	router.POST("/share-wallet", deprecated("/api/v1/wallets/:id/members"), auth, idempotent, h.CreateWalletUser)
+	router.DELETE("/remove-wallet-user/wallet/:id/username/:username/", deprecated("/api/v1/wallets/:id/members/:username"), auth, idempotent, h.DeleteWalletUser)
+
+	//Transactions Management
+	router.POST("/add-income", deprecated("/api/v1/wallets/:id/transactions"), middleware.AllowAPIKey(models.ScopeTransactionsWrite), auth, idempotent, func(context *gin.Context) { h.CreateTransaction(context, models.TransactionIncome) })
+	router.POST("/add-expense", deprecated("/api/v1/wallets/:id/transactions"), middleware.AllowAPIKey(models.ScopeTransactionsWrite), auth, idempotent, func(context *gin.Context) { h.CreateTransaction(context, models.TransactionExpense) })
+	router.GET("/transactions/:id", deprecated("/api/v1/wallets/:id/transactions"), middleware.AllowAPIKey(models.ScopeRead), auth, h.GetWalletTransactions)
+}
@@ -0,0 +1,29 @@
+package routes_test
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/handlers"
+	"github.com/khralenok/all-wallets-api/internal/api/openapi"
+	"github.com/khralenok/all-wallets-api/internal/api/routes"
+	"github.com/khralenok/all-wallets-api/internal/config"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+func TestRoutesMatchOpenAPIDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Default()
+	limiter := ratelimit.NewMemory()
+	h := handlers.New(&store.Store{}, nil, limiter, nil, nil, nil, nil, cfg)
+	noop := func(context *gin.Context) { context.Next() }
+
+	router := gin.New()
+	routes.Register(router, h, noop, noop, limiter, cfg.RateLimit)
+
+	if err := openapi.CheckRoutes(router.Routes()); err != nil {
+		t.Fatalf("OpenAPI document doesn't match routes: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Every key starts with it, so keys are easy to tell from JWT tokens and to find by secret scanners
+const Prefix = "aw_"
+
+const (
+	idBytes     = 6
+	secretBytes = 32
+)
+
+// Return new key in aw_<id>_<secret> format, its id and hash to store. Key itself is shown to user once and never stored.
+func Generate() (key, id, hash string, err error) {
+	rawID := make([]byte, idBytes)
+	rawSecret := make([]byte, secretBytes)
+
+	if _, err := rand.Read(rawID); err != nil {
+		return "", "", "", err
+	}
+
+	if _, err := rand.Read(rawSecret); err != nil {
+		return "", "", "", err
+	}
+
+	id = hex.EncodeToString(rawID)
+	key = Prefix + id + "_" + base64.RawURLEncoding.EncodeToString(rawSecret)
+
+	return key, id, Hash(key), nil
+}
+
+// Return true if token looks like API key rather than JWT
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// Return public id part of the key used to find it in database
+func ID(key string) (string, bool) {
+	id, _, found := strings.Cut(strings.TrimPrefix(key, Prefix), "_")
+
+	if !IsKey(key) || !found || len(id) != idBytes*2 {
+		return "", false
+	}
+
+	return id, true
+}
+
+// Return hash key is stored as. Keys are random, so fast hash is enough.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
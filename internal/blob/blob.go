@@ -0,0 +1,161 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Prefix of files Local writes before they are complete, they are never listed
+const tempPrefix = ".upload-"
+
+// BlobStore keeps file contents under slash separated keys, e.g. wallets/1/3f2a. Database keeps only keys, so files can be moved to object storage by replacing implementation.
+type BlobStore interface {
+	// Save content of r under key, replacing existing blob. Blob appears only once it's completely written.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Return reader of blob content. Return ErrNotFound if there is no such blob.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete blob. Deleting missing blob isn't an error.
+	Delete(ctx context.Context, key string) error
+	// Return keys of all blobs written before given time
+	ListBefore(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// Local keeps blobs as files in a directory on local filesystem. It's fine for single API instance or instances sharing a volume.
+type Local struct {
+	dir string
+}
+
+// Constructor function for local blob store. Directory is created if it doesn't exist.
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &Local{dir: dir}, nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := l.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), tempPrefix+"*")
+
+	if err != nil {
+		return err
+	}
+
+	// Removing renamed file fails harmlessly
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return file, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Local) ListBefore(ctx context.Context, before time.Time) ([]string, error) {
+	keys := []string{}
+
+	err := filepath.WalkDir(l.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return err
+		}
+
+		if !info.ModTime().Before(before) {
+			return nil
+		}
+
+		relative, err := filepath.Rel(l.dir, path)
+
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, filepath.ToSlash(relative))
+
+		return nil
+	})
+
+	return keys, err
+}
+
+// Return file path of key. Keys escaping the directory are refused.
+func (l *Local) path(key string) (string, error) {
+	name := filepath.FromSlash(key)
+
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+
+	return filepath.Join(l.dir, name), nil
+}
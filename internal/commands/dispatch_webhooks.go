@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/store"
+	"github.com/khralenok/all-wallets-api/internal/webhook"
+)
+
+// Worker function for sending due webhook deliveries. Deliveries are claimed in batches and sent concurrently until none is due, failed ones are retried by later runs with exponential backoff. Receivers may have addresses that aren't public only in allowed networks. Return number of delivered and failed attempts.
+func DispatchWebhooks(ctx context.Context, appStore *store.Store, allowedNetworks []string, dispatchWebhooksCmd *flag.FlagSet, args []string, batchSize, timeoutSeconds *int) (int, int, error) {
+	dispatchWebhooksCmd.Parse(args)
+
+	networks, err := webhook.ParseNetworks(allowedNetworks)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	timeout := time.Duration(*timeoutSeconds) * time.Second
+	sender := webhook.NewSender(timeout, networks)
+	// Claimed deliveries must not become due again while they are being sent
+	lease := timeout + time.Minute
+
+	var delivered, failed int
+
+	for {
+		deliveries, err := appStore.Webhooks.ClaimDue(ctx, *batchSize, lease)
+
+		if err != nil {
+			return delivered, failed, err
+		}
+
+		if len(deliveries) == 0 {
+			return delivered, failed, nil
+		}
+
+		statusCodes := make([]int, len(deliveries))
+		sendErrors := make([]error, len(deliveries))
+
+		var wg sync.WaitGroup
+
+		for i, delivery := range deliveries {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				statusCodes[i], sendErrors[i] = sender.Send(ctx, delivery)
+			}()
+		}
+
+		wg.Wait()
+
+		for i, delivery := range deliveries {
+			if sendErrors[i] == nil {
+				if err := appStore.Webhooks.MarkDelivered(ctx, delivery.ID, statusCodes[i]); err != nil {
+					return delivered, failed, err
+				}
+
+				delivered++
+				continue
+			}
+
+			var retryAt time.Time
+
+			if delivery.Attempts < webhook.MaxAttempts {
+				retryAt = time.Now().Add(webhook.Backoff(delivery.Attempts))
+			}
+
+			slog.WarnContext(ctx, "Webhook delivery failed", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "attempt", delivery.Attempts, "status_code", statusCodes[i], "error", sendErrors[i], "retry_at", retryAt)
+
+			if err := appStore.Webhooks.MarkFailed(ctx, delivery.ID, statusCodes[i], sendErrors[i].Error(), retryAt); err != nil {
+				return delivered, failed, err
+			}
+
+			failed++
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package commands_test
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/commands"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/store"
+	"github.com/khralenok/all-wallets-api/internal/webhook"
+)
+
+const testSecret = "whsec_test"
+
+type failedDelivery struct {
+	statusCode int
+	lastError  string
+	retryAt    time.Time
+}
+
+// Webhook repository handing out fixed deliveries once and recording their results
+type fakeWebhooks struct {
+	store.WebhookRepository
+	mu        sync.Mutex
+	due       []models.PendingDelivery
+	delivered map[int64]int
+	failed    map[int64]failedDelivery
+}
+
+func (f *fakeWebhooks) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.PendingDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	claimed := f.due[:min(limit, len(f.due))]
+	f.due = f.due[len(claimed):]
+
+	return claimed, nil
+}
+
+func (f *fakeWebhooks) MarkDelivered(ctx context.Context, deliveryID int64, statusCode int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.delivered[deliveryID] = statusCode
+
+	return nil
+}
+
+func (f *fakeWebhooks) MarkFailed(ctx context.Context, deliveryID int64, statusCode int, lastError string, retryAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failed[deliveryID] = failedDelivery{statusCode: statusCode, lastError: lastError, retryAt: retryAt}
+
+	return nil
+}
+
+func TestDispatchWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	signatureErrors := make(map[string]error)
+
+	// Receiver accepts deliveries on /ok and fails ones on /fail
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		err := webhook.Verify(testSecret, r.Header.Get(webhook.SignatureHeader), payload, time.Minute, time.Now())
+
+		mu.Lock()
+		signatureErrors[r.Header.Get(webhook.DeliveryHeader)] = err
+		mu.Unlock()
+
+		if r.URL.Path == "/fail" {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	delivery := func(id int64, path string, attempts int) models.PendingDelivery {
+		return models.PendingDelivery{ID: id, WebhookID: 1, URL: receiver.URL + path, Secret: testSecret, EventType: models.EventTransactionCreated, Payload: []byte(`{"id":` + strconv.FormatInt(id, 10) + `}`), Attempts: attempts}
+	}
+
+	webhooks := &fakeWebhooks{
+		due: []models.PendingDelivery{
+			delivery(1, "/ok", 1),
+			delivery(2, "/fail", 3),
+			delivery(3, "/fail", webhook.MaxAttempts),
+		},
+		delivered: make(map[int64]int),
+		failed:    make(map[int64]failedDelivery),
+	}
+
+	flags := flag.NewFlagSet("dispatch-webhooks", flag.ContinueOnError)
+	batchSize := flags.Int("batch", 20, "")
+	timeoutSeconds := flags.Int("timeout", 5, "")
+
+	before := time.Now()
+
+	delivered, failed, err := commands.DispatchWebhooks(context.Background(), &store.Store{Webhooks: webhooks}, []string{"127.0.0.1"}, flags, []string{"-batch", "2"}, batchSize, timeoutSeconds)
+
+	after := time.Now()
+
+	if err != nil {
+		t.Fatalf("DispatchWebhooks: %v", err)
+	}
+
+	if delivered != 1 || failed != 2 {
+		t.Errorf("delivered, failed = %d, %d, want 1, 2", delivered, failed)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		err, sent := signatureErrors[id]
+
+		if !sent {
+			t.Errorf("Delivery %s wasn't sent", id)
+		} else if err != nil {
+			t.Errorf("Signature of delivery %s: %v", id, err)
+		}
+	}
+
+	if statusCode, ok := webhooks.delivered[1]; !ok || statusCode != http.StatusNoContent {
+		t.Errorf("delivery 1 marked delivered with %d (%t), want %d", statusCode, ok, http.StatusNoContent)
+	}
+
+	retried, ok := webhooks.failed[2]
+
+	if !ok || retried.statusCode != http.StatusServiceUnavailable {
+		t.Fatalf("delivery 2 marked failed with %d (%t), want %d", retried.statusCode, ok, http.StatusServiceUnavailable)
+	}
+
+	// Delivery log is shown to wallet admins, receiver answer mustn't get there
+	if strings.Contains(retried.lastError, "try later") {
+		t.Errorf("delivery 2 last error = %q, want it without response body", retried.lastError)
+	}
+
+	backoff := webhook.Backoff(3)
+
+	if retried.retryAt.Before(before.Add(backoff)) || retried.retryAt.After(after.Add(backoff)) {
+		t.Errorf("delivery 2 retry at %v, want %v after it failed", retried.retryAt, backoff)
+	}
+
+	if givenUp := webhooks.failed[3]; !givenUp.retryAt.IsZero() {
+		t.Errorf("delivery 3 retry at %v, want none after %d attempts", givenUp.retryAt, webhook.MaxAttempts)
+	}
+}
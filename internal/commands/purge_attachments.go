@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/blob"
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Worker function for deleting attachment files no attachment refers to, e.g. files of deleted wallets or uploads that failed halfway. Files younger than minimal age are kept, their upload may still be in progress. Return number of deleted files.
+func PurgeOrphanedAttachments(ctx context.Context, appStore *store.Store, blobs blob.BlobStore, purgeAttachmentsCmd *flag.FlagSet, args []string, hours *int) (int, error) {
+	purgeAttachmentsCmd.Parse(args)
+
+	keys, err := blobs.ListBefore(ctx, time.Now().Add(-time.Duration(*hours)*time.Hour))
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	orphaned, err := appStore.Attachments.GetOrphanedKeys(ctx, keys)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i, key := range orphaned {
+		if err := blobs.Delete(ctx, key); err != nil {
+			return i, err
+		}
+	}
+
+	return len(orphaned), nil
+}
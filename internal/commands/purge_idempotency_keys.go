@@ -0,0 +1,15 @@
+package commands
+
+import (
+	"context"
+	"flag"
+
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Worker function for deleting idempotency keys with saved responses older than 24 hours. API server ignores such keys anyway, it only saves space.
+func PurgeIdempotencyKeys(ctx context.Context, appStore *store.Store, purgeIdempotencyKeysCmd *flag.FlagSet, args []string) (int, error) {
+	purgeIdempotencyKeysCmd.Parse(args)
+
+	return appStore.Idempotency.PurgeExpired(ctx)
+}
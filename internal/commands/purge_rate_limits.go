@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Worker function for deleting rate limit buckets nobody touched for a long time. Missing bucket is the same as full one, so it's safe.
+func PurgeRateLimits(ctx context.Context, appStore *store.Store, purgeRateLimitsCmd *flag.FlagSet, args []string, hours *int) (int, error) {
+	purgeRateLimitsCmd.Parse(args)
+
+	maxAge := time.Duration(*hours) * time.Hour
+
+	return appStore.RateLimits.PurgeStale(ctx, maxAge)
+}
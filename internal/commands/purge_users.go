@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Worker function for erasing personal data of users whose deletion grace period is over
+func PurgeDeletedUsers(ctx context.Context, appStore *store.Store, purgeUsersCmd *flag.FlagSet, args []string, days *int) (int, error) {
+	purgeUsersCmd.Parse(args)
+
+	gracePeriod := time.Duration(*days) * 24 * time.Hour
+
+	return appStore.Users.PurgeDeleted(ctx, gracePeriod)
+}
@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Worker function for deleting recorded wallet events. API instances load them as soon as they are notified, so old ones are never read again.
+func PurgeWalletEvents(ctx context.Context, appStore *store.Store, purgeWalletEventsCmd *flag.FlagSet, args []string, hours *int) (int, error) {
+	purgeWalletEventsCmd.Parse(args)
+
+	retention := time.Duration(*hours) * time.Hour
+
+	return appStore.Events.Purge(ctx, retention)
+}
@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Worker function for permanently removing wallets which stayed archived longer than retention period
+func PurgeArchivedWallets(ctx context.Context, appStore *store.Store, purgeWalletsCmd *flag.FlagSet, args []string, days *int) (int, error) {
+	purgeWalletsCmd.Parse(args)
+
+	retention := time.Duration(*days) * 24 * time.Hour
+
+	return appStore.Wallets.PurgeArchived(ctx, retention)
+}
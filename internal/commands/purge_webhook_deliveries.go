@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Worker function for deleting delivered and failed webhook deliveries. Pending ones are kept whatever their age.
+func PurgeWebhookDeliveries(ctx context.Context, appStore *store.Store, purgeWebhookDeliveriesCmd *flag.FlagSet, args []string, days *int) (int, error) {
+	purgeWebhookDeliveriesCmd.Parse(args)
+
+	retention := time.Duration(*days) * 24 * time.Hour
+
+	return appStore.Webhooks.PurgeFinished(ctx, retention)
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+
+	"github.com/khralenok/all-wallets-api/internal/notify"
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Worker function for emailing every user who asked for it one digest of unread notifications that weren't emailed yet. Users are processed in batches, failure to email one user is logged and retried by next run. Return number of sent and failed digests.
+func SendNotificationDigests(ctx context.Context, appStore *store.Store, notifier notify.Notifier, sendNotificationDigestsCmd *flag.FlagSet, args []string, batchSize *int) (int, int, error) {
+	sendNotificationDigestsCmd.Parse(args)
+
+	var sent, failed, lastUserID int
+
+	for {
+		digests, err := appStore.Notifications.GetPendingDigests(ctx, lastUserID, *batchSize)
+
+		if err != nil {
+			return sent, failed, err
+		}
+
+		if len(digests) == 0 {
+			return sent, failed, nil
+		}
+
+		for _, digest := range digests {
+			lastUserID = digest.UserID
+
+			if err := notifier.Send(ctx, notify.DigestMessage(digest)); err != nil {
+				slog.WarnContext(ctx, "Can't send notification digest", "user_id", digest.UserID, "error", err)
+				failed++
+				continue
+			}
+
+			lastID := digest.Notifications[len(digest.Notifications)-1].ID
+
+			if err := appStore.Notifications.MarkEmailed(ctx, digest.UserID, lastID); err != nil {
+				return sent, failed, err
+			}
+
+			sent++
+		}
+	}
+}
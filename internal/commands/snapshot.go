@@ -1,29 +1,17 @@
 package commands
 
 import (
+	"context"
 	"flag"
-	"os"
 
-	"github.com/khralenok/all-wallets-api/internal/logic"
 	"github.com/khralenok/all-wallets-api/internal/store"
 )
 
 // Worker function for updating wallet snapshot by sum up all new trunsactions to balance stored in wallet balance
-func UpdateWalletSnapshot(snapshotCmd *flag.FlagSet, snapshotWalletID *int) error {
-	snapshotCmd.Parse(os.Args[2:])
-	latestTransactions, err := store.GetLatestTransactions(*snapshotWalletID)
+func UpdateWalletSnapshot(ctx context.Context, appStore *store.Store, snapshotCmd *flag.FlagSet, args []string, snapshotWalletID *int) error {
+	snapshotCmd.Parse(args)
 
-	if err != nil {
-		return err
-	}
+	_, err := appStore.Wallets.TakeSnapshot(ctx, *snapshotWalletID)
 
-	sumOfLatestTransactions := logic.CalcSumOfTransactions(latestTransactions)
-
-	err = store.UpdateBalance(*snapshotWalletID, sumOfLatestTransactions)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return err
 }
@@ -1,20 +1,22 @@
 package commands
 
 import (
+	"context"
+
 	"github.com/khralenok/all-wallets-api/internal/logic"
 	"github.com/khralenok/all-wallets-api/internal/store"
 )
 
 // Worker function for updating data about current exchange rates in DB
-func UpdateExchangeRates() error {
+func UpdateExchangeRates(ctx context.Context, appStore *store.Store, appID string) error {
 
-	rates, err := logic.FetchExchangeRates()
+	rates, err := logic.FetchExchangeRates(appID)
 
 	if err != nil {
 		return err
 	}
 
-	availableCurrencies, err := store.GetAvailableCurrencies()
+	availableCurrencies, err := appStore.Currencies.GetAll(ctx)
 
 	if err != nil {
 		return err
@@ -22,7 +24,7 @@ func UpdateExchangeRates() error {
 
 	updatedRates := logic.CalcExchangeRates(rates, availableCurrencies)
 
-	err = store.AddUpdatedExchangeRates(updatedRates)
+	err = appStore.ExchangeRates.ReplaceAll(ctx, updatedRates)
 
 	if err != nil {
 		return err
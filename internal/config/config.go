@@ -0,0 +1,622 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/khralenok/all-wallets-api/internal/password"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+	"github.com/khralenok/all-wallets-api/internal/webhook"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const MinJWTSecretLength = 32
+
+var sslModes = map[string]bool{"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true}
+
+type Config struct {
+	Database      DatabaseConfig      `yaml:"database"`
+	Server        ServerConfig        `yaml:"server"`
+	JWT           JWTConfig           `yaml:"jwt"`
+	ExchangeRates ExchangeRatesConfig `yaml:"exchange_rates"`
+	Log           LogConfig           `yaml:"log"`
+	Metrics       MetricsConfig       `yaml:"metrics"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	Password      PasswordConfig      `yaml:"password"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Attachments   AttachmentsConfig   `yaml:"attachments"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks"`
+}
+
+type DatabaseConfig struct {
+	// Full connection URL. If set, other database fields are ignored.
+	URL      string `yaml:"url"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+type ServerConfig struct {
+	// Interface to listen on. Empty host means all interfaces.
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// How long server keeps accepting requests with failing readiness probe after SIGTERM, so load balancer stops routing to it first
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	// How long in-flight requests may take to finish after SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// Server isn't ready if exchange rates are older than that
+	MaxRatesAge time.Duration `yaml:"max_rates_age"`
+	// Proxies allowed to set X-Forwarded-For. Client address is taken from connection if empty.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type JWTConfig struct {
+	Secret string        `yaml:"secret"`
+	TTL    time.Duration `yaml:"ttl"`
+}
+
+type LogConfig struct {
+	// One of debug, info, warn or error
+	Level string `yaml:"level"`
+}
+
+type PasswordConfig struct {
+	MinLength int `yaml:"min_length"`
+	MaxBytes  int `yaml:"max_bytes"`
+	// Optional file with one breached password per line, checked on top of built-in list
+	BreachedList string `yaml:"breached_list"`
+	// Algorithm for new hashes, argon2id or bcrypt. Hashes made with other algorithm or parameters are replaced on next login.
+	Hash       string                `yaml:"hash"`
+	Argon2     password.Argon2Params `yaml:"argon2"`
+	BcryptCost int                   `yaml:"bcrypt_cost"`
+}
+
+type RateLimitConfig struct {
+	// Where token buckets are kept: memory for single instance or postgres to share them between instances
+	Backend          string            `yaml:"backend"`
+	LoginPerIP       ratelimit.Rate    `yaml:"login_per_ip"`
+	LoginPerUsername ratelimit.Rate    `yaml:"login_per_username"`
+	SigninPerIP      ratelimit.Rate    `yaml:"signin_per_ip"`
+	Lockout          ratelimit.Lockout `yaml:"lockout"`
+}
+
+type MetricsConfig struct {
+	// Pushgateway worker pushes job metrics to. Worker doesn't push if empty.
+	PushgatewayURL string `yaml:"pushgateway_url"`
+}
+
+type ExchangeRatesConfig struct {
+	AppID string `yaml:"app_id"`
+}
+
+type NotificationsConfig struct {
+	// How notification digests are emailed: log writes them to file for development, smtp sends them
+	Sender string `yaml:"sender"`
+	// File log sender appends to, stdout if empty
+	LogFile string     `yaml:"log_file"`
+	SMTP    SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// Server is used without authentication if empty
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+type AttachmentsConfig struct {
+	// Directory transaction attachments are kept in, shared by API server and worker
+	Dir string `yaml:"dir"`
+	// Largest accepted file in bytes
+	MaxSize int `yaml:"max_size"`
+}
+
+type WebhooksConfig struct {
+	// Private, loopback or link-local networks receivers may be in, e.g. LAN of home server. Other addresses that aren't public are refused.
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+// Return config with default values. Every field without default must be provided explicitly.
+func Default() Config {
+	return Config{
+		Database: DatabaseConfig{
+			Port:    5432,
+			SSLMode: "disable",
+		},
+		Server: ServerConfig{
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			MaxRatesAge:       48 * time.Hour,
+		},
+		JWT: JWTConfig{
+			TTL: 24 * time.Hour,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Password: PasswordConfig{
+			MinLength: 8,
+			MaxBytes:  256,
+			Hash:      password.Argon2id,
+			Argon2: password.Argon2Params{
+				MemoryKiB:   64 * 1024,
+				Iterations:  2,
+				Parallelism: 2,
+			},
+			BcryptCost: bcrypt.DefaultCost,
+		},
+		Notifications: NotificationsConfig{
+			Sender: "log",
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+		},
+		Attachments: AttachmentsConfig{
+			Dir:     "attachments",
+			MaxSize: 10 << 20,
+		},
+		RateLimit: RateLimitConfig{
+			Backend:          "memory",
+			LoginPerIP:       ratelimit.Rate{Burst: 20, Period: time.Minute},
+			LoginPerUsername: ratelimit.Rate{Burst: 5, Period: time.Minute},
+			SigninPerIP:      ratelimit.Rate{Burst: 5, Period: time.Hour},
+			Lockout: ratelimit.Lockout{
+				Threshold:  5,
+				BaseDelay:  30 * time.Second,
+				MaxDelay:   time.Hour,
+				ResetAfter: 24 * time.Hour,
+			},
+		},
+	}
+}
+
+// Load config once at startup. Caller must validate it with Validate or ValidateServer. Values are applied in order of precedence: defaults, YAML file, .env file, environment variables and finally command line flags. Both files are optional. YAML file path is taken from -config flag or CONFIG_FILE variable. Return arguments left after flags, e.g. worker command.
+func Load(args []string) (Config, []string, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "Path to YAML config file")
+	host := flags.String("host", "", "Interface API server listens on")
+	port := flags.Int("port", 0, "Port API server listens on")
+
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	// Existing environment variables are never overridden by .env file
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil, fmt.Errorf("can't read .env file: %w", err)
+	}
+
+	if err := loadEnv(&cfg); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *host != "" {
+		cfg.Server.Host = *host
+	}
+
+	if *port != 0 {
+		cfg.Server.Port = *port
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+// Validate settings shared by API server and worker. Return all problems at once, so they can be fixed in one go.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Database.URL != "" {
+		dsn, err := url.Parse(c.Database.URL)
+
+		if err != nil || (dsn.Scheme != "postgres" && dsn.Scheme != "postgresql") || dsn.Host == "" {
+			errs = append(errs, errors.New("DATABASE_URL must be postgres:// URL with host"))
+		}
+	} else {
+		if c.Database.Host == "" {
+			errs = append(errs, errors.New("POSTGRES_HOST is required"))
+		}
+
+		if c.Database.User == "" {
+			errs = append(errs, errors.New("POSTGRES_USER is required"))
+		}
+
+		if c.Database.Name == "" {
+			errs = append(errs, errors.New("POSTGRES_DB is required"))
+		}
+
+		if !isValidPort(c.Database.Port) {
+			errs = append(errs, fmt.Errorf("POSTGRES_PORT %d is out of range", c.Database.Port))
+		}
+
+		if !sslModes[c.Database.SSLMode] {
+			errs = append(errs, fmt.Errorf("POSTGRES_SSLMODE %q is unknown", c.Database.SSLMode))
+		}
+	}
+
+	if c.Metrics.PushgatewayURL != "" {
+		pushgateway, err := url.Parse(c.Metrics.PushgatewayURL)
+
+		if err != nil || (pushgateway.Scheme != "http" && pushgateway.Scheme != "https") || pushgateway.Host == "" {
+			errs = append(errs, errors.New("METRICS_PUSHGATEWAY_URL must be http(s):// URL with host"))
+		}
+	}
+
+	switch c.Notifications.Sender {
+	case "log":
+	case "smtp":
+		smtp := c.Notifications.SMTP
+
+		if smtp.Host == "" || smtp.From == "" {
+			errs = append(errs, errors.New("SMTP_HOST and SMTP_FROM are required with smtp notifications sender"))
+		}
+
+		if !isValidPort(smtp.Port) {
+			errs = append(errs, fmt.Errorf("SMTP_PORT %d is out of range", smtp.Port))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("NOTIFICATIONS_SENDER %q must be log or smtp", c.Notifications.Sender))
+	}
+
+	if c.Attachments.Dir == "" {
+		errs = append(errs, errors.New("ATTACHMENTS_DIR is required"))
+	}
+
+	if c.Attachments.MaxSize <= 0 {
+		errs = append(errs, errors.New("ATTACHMENTS_MAX_SIZE must be positive"))
+	}
+
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL %q is unknown", c.Log.Level))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate settings API server needs on top of shared ones.
+func (c Config) ValidateServer() error {
+	var errs []error
+
+	if len(c.JWT.Secret) < MinJWTSecretLength {
+		errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d bytes long", MinJWTSecretLength))
+	}
+
+	if c.JWT.TTL <= 0 {
+		errs = append(errs, errors.New("JWT_TTL must be positive"))
+	}
+
+	if !isValidPort(c.Server.Port) {
+		errs = append(errs, fmt.Errorf("PORT %d is out of range", c.Server.Port))
+	}
+
+	timeouts := map[string]time.Duration{
+		"SERVER_READ_HEADER_TIMEOUT": c.Server.ReadHeaderTimeout,
+		"SERVER_READ_TIMEOUT":        c.Server.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":       c.Server.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":        c.Server.IdleTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT":    c.Server.ShutdownTimeout,
+		"MAX_RATES_AGE":              c.Server.MaxRatesAge,
+	}
+
+	for name, timeout := range timeouts {
+		if timeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("SERVER_DRAIN_DELAY can't be negative"))
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES entry %q must be IP or CIDR", proxy))
+			}
+		}
+	}
+
+	if _, err := webhook.ParseNetworks(c.Webhooks.AllowedNetworks); err != nil {
+		errs = append(errs, fmt.Errorf("WEBHOOKS_ALLOWED_NETWORKS: %w", err))
+	}
+
+	pwd := c.Password
+
+	if pwd.MinLength <= 0 {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be positive"))
+	}
+
+	if pwd.MaxBytes < pwd.MinLength || pwd.MaxBytes > password.MaxBytes {
+		errs = append(errs, fmt.Errorf("PASSWORD_MAX_BYTES must be between PASSWORD_MIN_LENGTH and %d", password.MaxBytes))
+	}
+
+	switch pwd.Hash {
+	case password.Argon2id:
+		if pwd.Argon2.Iterations == 0 || pwd.Argon2.Parallelism == 0 || pwd.Argon2.MemoryKiB < 8*uint32(pwd.Argon2.Parallelism) {
+			errs = append(errs, errors.New("ARGON2_ITERATIONS and ARGON2_PARALLELISM must be positive and ARGON2_MEMORY_KIB at least 8 per thread"))
+		}
+	case password.Bcrypt:
+		if pwd.BcryptCost < bcrypt.MinCost || pwd.BcryptCost > bcrypt.MaxCost {
+			errs = append(errs, fmt.Errorf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+		}
+
+		if pwd.MaxBytes > 72 {
+			errs = append(errs, errors.New("PASSWORD_MAX_BYTES can't exceed 72 with bcrypt, it ignores the rest"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("PASSWORD_HASH %q must be argon2id or bcrypt", pwd.Hash))
+	}
+
+	if c.RateLimit.Backend != "memory" && c.RateLimit.Backend != "postgres" {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BACKEND %q must be memory or postgres", c.RateLimit.Backend))
+	}
+
+	rates := map[string]ratelimit.Rate{
+		"RATE_LIMIT_LOGIN_IP":       c.RateLimit.LoginPerIP,
+		"RATE_LIMIT_LOGIN_USERNAME": c.RateLimit.LoginPerUsername,
+		"RATE_LIMIT_SIGNIN_IP":      c.RateLimit.SigninPerIP,
+	}
+
+	for name, rate := range rates {
+		if rate.Burst <= 0 || rate.Period <= 0 {
+			errs = append(errs, fmt.Errorf("%s must have positive burst and period", name))
+		}
+	}
+
+	lockout := c.RateLimit.Lockout
+
+	if lockout.Threshold <= 0 {
+		errs = append(errs, errors.New("LOCKOUT_THRESHOLD must be positive"))
+	}
+
+	if lockout.BaseDelay <= 0 || lockout.MaxDelay < lockout.BaseDelay {
+		errs = append(errs, errors.New("LOCKOUT_BASE_DELAY must be positive and not greater than LOCKOUT_MAX_DELAY"))
+	}
+
+	if lockout.ResetAfter <= 0 {
+		errs = append(errs, errors.New("LOCKOUT_RESET_AFTER must be positive"))
+	}
+
+	return errors.Join(c.Validate(), errors.Join(errs...))
+}
+
+// Return connection string for database/sql. Password is quoted, so it may contain spaces and quotes.
+func (d DatabaseConfig) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSNValue(d.Host),
+		d.Port,
+		quoteDSNValue(d.User),
+		quoteDSNValue(d.Password),
+		quoteDSNValue(d.Name),
+		d.SSLMode,
+	)
+}
+
+// Return address API server listens on
+func (s ServerConfig) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("can't read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("can't parse config file: %w", err)
+	}
+
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	stringVars := map[string]*string{
+		"DATABASE_URL":            &cfg.Database.URL,
+		"POSTGRES_HOST":           &cfg.Database.Host,
+		"POSTGRES_USER":           &cfg.Database.User,
+		"POSTGRES_PASSWORD":       &cfg.Database.Password,
+		"POSTGRES_DB":             &cfg.Database.Name,
+		"POSTGRES_SSLMODE":        &cfg.Database.SSLMode,
+		"HOST":                    &cfg.Server.Host,
+		"LOG_LEVEL":               &cfg.Log.Level,
+		"JWT_SECRET":              &cfg.JWT.Secret,
+		"OXR_APP_ID":              &cfg.ExchangeRates.AppID,
+		"METRICS_PUSHGATEWAY_URL": &cfg.Metrics.PushgatewayURL,
+		"RATE_LIMIT_BACKEND":      &cfg.RateLimit.Backend,
+		"PASSWORD_BREACHED_LIST":  &cfg.Password.BreachedList,
+		"PASSWORD_HASH":           &cfg.Password.Hash,
+		"NOTIFICATIONS_SENDER":    &cfg.Notifications.Sender,
+		"NOTIFICATIONS_LOG_FILE":  &cfg.Notifications.LogFile,
+		"SMTP_HOST":               &cfg.Notifications.SMTP.Host,
+		"SMTP_USERNAME":           &cfg.Notifications.SMTP.Username,
+		"SMTP_PASSWORD":           &cfg.Notifications.SMTP.Password,
+		"SMTP_FROM":               &cfg.Notifications.SMTP.From,
+		"ATTACHMENTS_DIR":         &cfg.Attachments.Dir,
+	}
+
+	for name, target := range stringVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+
+	if value, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.Server.TrustedProxies = nil
+
+		for _, proxy := range strings.Split(value, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.Server.TrustedProxies = append(cfg.Server.TrustedProxies, proxy)
+			}
+		}
+	}
+
+	if value, ok := os.LookupEnv("WEBHOOKS_ALLOWED_NETWORKS"); ok {
+		cfg.Webhooks.AllowedNetworks = nil
+
+		for _, network := range strings.Split(value, ",") {
+			if network = strings.TrimSpace(network); network != "" {
+				cfg.Webhooks.AllowedNetworks = append(cfg.Webhooks.AllowedNetworks, network)
+			}
+		}
+	}
+
+	rateVars := map[string]*ratelimit.Rate{
+		"RATE_LIMIT_LOGIN_IP":       &cfg.RateLimit.LoginPerIP,
+		"RATE_LIMIT_LOGIN_USERNAME": &cfg.RateLimit.LoginPerUsername,
+		"RATE_LIMIT_SIGNIN_IP":      &cfg.RateLimit.SigninPerIP,
+	}
+
+	for name, target := range rateVars {
+		value, ok := os.LookupEnv(name)
+
+		if !ok {
+			continue
+		}
+
+		parsed, err := ratelimit.ParseRate(value)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		*target = parsed
+	}
+
+	intVars := map[string]*int{
+		"POSTGRES_PORT":        &cfg.Database.Port,
+		"PORT":                 &cfg.Server.Port,
+		"LOCKOUT_THRESHOLD":    &cfg.RateLimit.Lockout.Threshold,
+		"PASSWORD_MIN_LENGTH":  &cfg.Password.MinLength,
+		"PASSWORD_MAX_BYTES":   &cfg.Password.MaxBytes,
+		"BCRYPT_COST":          &cfg.Password.BcryptCost,
+		"SMTP_PORT":            &cfg.Notifications.SMTP.Port,
+		"ATTACHMENTS_MAX_SIZE": &cfg.Attachments.MaxSize,
+	}
+
+	for name, target := range intVars {
+		value, ok := os.LookupEnv(name)
+
+		if !ok {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(value)
+
+		if err != nil {
+			return fmt.Errorf("%s must be integer", name)
+		}
+
+		*target = parsed
+	}
+
+	uintVars := map[string]struct {
+		target  any
+		bitSize int
+	}{
+		"ARGON2_MEMORY_KIB":  {&cfg.Password.Argon2.MemoryKiB, 32},
+		"ARGON2_ITERATIONS":  {&cfg.Password.Argon2.Iterations, 32},
+		"ARGON2_PARALLELISM": {&cfg.Password.Argon2.Parallelism, 8},
+	}
+
+	for name, variable := range uintVars {
+		value, ok := os.LookupEnv(name)
+
+		if !ok {
+			continue
+		}
+
+		parsed, err := strconv.ParseUint(value, 10, variable.bitSize)
+
+		if err != nil {
+			return fmt.Errorf("%s must be non-negative integer of %d bits", name, variable.bitSize)
+		}
+
+		switch target := variable.target.(type) {
+		case *uint32:
+			*target = uint32(parsed)
+		case *uint8:
+			*target = uint8(parsed)
+		}
+	}
+
+	durationVars := map[string]*time.Duration{
+		"JWT_TTL":                    &cfg.JWT.TTL,
+		"SERVER_READ_HEADER_TIMEOUT": &cfg.Server.ReadHeaderTimeout,
+		"SERVER_READ_TIMEOUT":        &cfg.Server.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":       &cfg.Server.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":        &cfg.Server.IdleTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT":    &cfg.Server.ShutdownTimeout,
+		"SERVER_DRAIN_DELAY":         &cfg.Server.DrainDelay,
+		"MAX_RATES_AGE":              &cfg.Server.MaxRatesAge,
+		"LOCKOUT_BASE_DELAY":         &cfg.RateLimit.Lockout.BaseDelay,
+		"LOCKOUT_MAX_DELAY":          &cfg.RateLimit.Lockout.MaxDelay,
+		"LOCKOUT_RESET_AFTER":        &cfg.RateLimit.Lockout.ResetAfter,
+	}
+
+	for name, target := range durationVars {
+		value, ok := os.LookupEnv(name)
+
+		if !ok {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(value)
+
+		if err != nil {
+			return fmt.Errorf("%s must be duration, e.g. 30s", name)
+		}
+
+		*target = parsed
+	}
+
+	return nil
+}
+
+func isValidPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+
+	return "'" + value + "'"
+}
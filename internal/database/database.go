@@ -2,27 +2,17 @@ package database
 
 import (
 	"database/sql"
-	"fmt"
-	"os"
 
 	_ "github.com/lib/pq"
 )
 
 var DB *sql.DB
 
-func Connect() error {
+// Open connection pool with provided connection string and check database is reachable.
+func Connect(dsn string) error {
 	var err error
-	connectStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"),
-		os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PASSWORD"),
-		os.Getenv("POSTGRES_DB"),
-	)
 
-	fmt.Println(connectStr)
-
-	DB, err = sql.Open("postgres", connectStr)
+	DB, err = sql.Open("postgres", dsn)
 	if err != nil {
 		return err
 	}
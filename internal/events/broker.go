@@ -0,0 +1,197 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/lib/pq"
+)
+
+// How many events may wait for slow subscriber. Subscriber falling further behind is dropped, so it reconnects instead of blocking everybody.
+const subscriberBuffer = 32
+
+// How often connection is checked while there are no notifications
+const pingInterval = 90 * time.Second
+
+// How long loading of notified event may take
+const loadTimeout = 5 * time.Second
+
+// Loader returns wallet event notification refers to
+type Loader interface {
+	Get(ctx context.Context, eventID int64) (models.WalletEvent, error)
+}
+
+// Broker receives wallet events from Postgres LISTEN and fans them out to subscribers of this instance.
+type Broker struct {
+	listener *pq.Listener
+	channel  string
+	loader   Loader
+
+	mu          sync.Mutex
+	subscribers map[int]map[*subscription]struct{}
+	closed      bool
+}
+
+type subscription struct {
+	events chan models.WalletEvent
+}
+
+// Constructor function for broker listening to channel on database with provided DSN. Notifications carry models.WalletEventNotice, events themselves are taken from loader. Broker receives nothing until Run is called.
+func NewBroker(dsn, channel string, loader Loader) (*Broker, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Wallet events listener connection problem", "event", event, "error", err)
+		}
+	})
+
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &Broker{listener: listener, channel: channel, loader: loader, subscribers: make(map[int]map[*subscription]struct{})}, nil
+}
+
+// Forward notifications to subscribers until ctx is done or broker is closed
+func (b *Broker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// Nil notification means connection was re-established, events sent meanwhile are lost
+			if notification == nil {
+				slog.Info("Wallet events listener reconnected")
+				continue
+			}
+
+			var notice models.WalletEventNotice
+
+			if err := json.Unmarshal([]byte(notification.Extra), &notice); err != nil {
+				slog.Warn("Invalid wallet event notice", "error", err)
+				continue
+			}
+
+			// Most events are about wallets nobody streams on this instance, they aren't even loaded
+			if !b.hasSubscribers(notice.WalletID) {
+				continue
+			}
+
+			event, err := b.load(ctx, notice.ID)
+
+			if err != nil {
+				slog.Warn("Can't load wallet event", "event_id", notice.ID, "wallet_id", notice.WalletID, "error", err)
+				continue
+			}
+
+			b.dispatch(event)
+		case <-ticker.C:
+			if err := b.listener.Ping(); err != nil {
+				slog.Warn("Wallet events listener ping failed", "error", err)
+			}
+		}
+	}
+}
+
+// Subscribe to events of the wallet. Returned channel is closed when broker is closed, subscriber is too slow or unsubscribe is called.
+func (b *Broker) Subscribe(walletID int) (<-chan models.WalletEvent, func()) {
+	sub := &subscription{events: make(chan models.WalletEvent, subscriberBuffer)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+
+	if b.subscribers[walletID] == nil {
+		b.subscribers[walletID] = make(map[*subscription]struct{})
+	}
+
+	b.subscribers[walletID][sub] = struct{}{}
+
+	return sub.events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.remove(walletID, sub)
+	}
+}
+
+// Stop listening and close all subscriptions, so open streams end and clients reconnect to another instance
+func (b *Broker) Close() error {
+	b.mu.Lock()
+
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.closed = true
+
+	for walletID, subs := range b.subscribers {
+		for sub := range subs {
+			b.remove(walletID, sub)
+		}
+	}
+
+	b.mu.Unlock()
+
+	return b.listener.Close()
+}
+
+func (b *Broker) hasSubscribers(walletID int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers[walletID]) > 0
+}
+
+func (b *Broker) load(ctx context.Context, eventID int64) (models.WalletEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+	defer cancel()
+
+	return b.loader.Get(ctx, eventID)
+}
+
+func (b *Broker) dispatch(event models.WalletEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers[event.WalletID] {
+		select {
+		case sub.events <- event:
+		default:
+			slog.Warn("Wallet events subscriber is too slow, dropping it", "wallet_id", event.WalletID)
+			b.remove(event.WalletID, sub)
+		}
+	}
+}
+
+// Must be called with mu locked. Safe to call for already removed subscription.
+func (b *Broker) remove(walletID int, sub *subscription) {
+	subs := b.subscribers[walletID]
+
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	close(sub.events)
+
+	if len(subs) == 0 {
+		delete(b.subscribers, walletID)
+	}
+}
@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Attribute keys containing one of these substrings never reach the log
+var secretKeys = []string{"password", "pwd", "secret", "token", "authorization", "dsn", "api_key"}
+
+// Catch secrets embedded in free-form strings, e.g. "password=..." in connection strings or "Bearer ..." headers
+var secretValuePattern = regexp.MustCompile(`(?i)(password|secret|token)=('[^']*'|\S+)|(Bearer\s+)\S+`)
+
+type requestIDKey struct{}
+
+// Create JSON logger with provided level. Every record gets request id from context and secrets redacted.
+func New(w io.Writer, level string) (*slog.Logger, error) {
+	var slogLevel slog.Level
+
+	if err := slogLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       slogLevel,
+		ReplaceAttr: redact,
+	})
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// Return new random id for request or worker run
+func NewID() string {
+	buf := make([]byte, 16)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+// Return copy of ctx carrying request id, so every log record written with it can be correlated.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// Return request id stored in ctx or empty string.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+
+	return requestID
+}
+
+// Wrap handler to add request id from context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+
+	for _, secretKey := range secretKeys {
+		if strings.Contains(key, secretKey) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	if attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, RedactString(attr.Value.String()))
+	}
+
+	if err, ok := attr.Value.Any().(error); ok {
+		return slog.String(attr.Key, RedactString(err.Error()))
+	}
+
+	return attr
+}
+
+// Replace secrets embedded in string with placeholder
+func RedactString(value string) string {
+	return secretValuePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if strings.HasPrefix(strings.ToLower(match), "bearer") {
+			return "Bearer " + redacted
+		}
+
+		name, _, _ := strings.Cut(match, "=")
+
+		return name + "=" + redacted
+	})
+}
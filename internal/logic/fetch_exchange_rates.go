@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 )
 
 // Call OpenExchangeRates API to get exchange rates for USD. Return map with currencies and corresponding rates.
-func FetchExchangeRates() (map[string]float64, error) {
+func FetchExchangeRates(appID string) (map[string]float64, error) {
 	rates := make(map[string]float64)
 
-	url := fmt.Sprintf("https://openexchangerates.org/api/latest.json?app_id=%s", os.Getenv("OXR_APP_ID"))
+	if appID == "" {
+		return map[string]float64{}, errors.New("OpenExchangeRates app id isn't configured")
+	}
+
+	url := fmt.Sprintf("https://openexchangerates.org/api/latest.json?app_id=%s", appID)
 
 	data, err := getDataFromAPI(url)
 
@@ -24,7 +27,7 @@ func FetchExchangeRates() (map[string]float64, error) {
 	rawRates, ok := data["rates"].(map[string]any) // We need only rates
 
 	if !ok {
-		return map[string]float64{}, err
+		return map[string]float64{}, errors.New("API response has no rates")
 	}
 
 	for key, value := range rawRates {
@@ -25,18 +25,33 @@ func FormatTransactionOutput(rawTransactions []models.Transaction, decimalPlaces
 	var transactions []models.TransactionOutput
 
 	for _, rawTrx := range rawTransactions {
-		var newTrx models.TransactionOutput
-
-		newTrx.ID = rawTrx.ID
-		newTrx.IsDeposit = rawTrx.IsDeposit
-		newTrx.Category = rawTrx.Category
-		newTrx.CreatorID = rawTrx.CreatorID
-		newTrx.CreatedAt = rawTrx.CreatedAt
+		transactions = append(transactions, FormatTransaction(rawTrx, decimalPlaces))
+	}
 
-		newTrx.Amount = FormatOutputValue(rawTrx.Amount, decimalPlaces)
+	return transactions
+}
 
-		transactions = append(transactions, newTrx)
+// Return transaction with amount in human readable format
+func FormatTransaction(rawTrx models.Transaction, decimalPlaces int) models.TransactionOutput {
+	var newTrx models.TransactionOutput
+
+	newTrx.ID = rawTrx.ID
+	newTrx.IsDeposit = rawTrx.IsDeposit
+	newTrx.Category = rawTrx.Category
+	newTrx.Description = rawTrx.Description
+	newTrx.Payee = rawTrx.Payee
+	newTrx.Tags = rawTrx.Tags
+	newTrx.Attachments = rawTrx.Attachments
+	newTrx.CreatorID = rawTrx.CreatorID
+	newTrx.CreatedAt = rawTrx.CreatedAt
+	newTrx.OccurredAt = rawTrx.OccurredAt
+
+	newTrx.Amount = FormatOutputValue(rawTrx.Amount, decimalPlaces)
+	newTrx.Splits = make([]models.TransactionSplitOutput, len(rawTrx.Splits))
+
+	for i, split := range rawTrx.Splits {
+		newTrx.Splits[i] = models.TransactionSplitOutput{Category: split.Category, Amount: FormatOutputValue(split.Amount, decimalPlaces)}
 	}
 
-	return transactions
+	return newTrx
 }
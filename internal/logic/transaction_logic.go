@@ -1,6 +1,8 @@
 package logic
 
 import (
+	"time"
+
 	"github.com/khralenok/all-wallets-api/internal/models"
 )
 
@@ -20,3 +22,10 @@ func CalcSumOfTransactions(latestTransactions []models.Transaction) int {
 
 	return sum
 }
+
+// Return start of calendar month t belongs to in UTC, monthly income and expense are counted from it
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
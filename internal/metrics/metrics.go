@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const namespace = "all_wallets"
+
+// Registry holds application metrics. API server exposes it on /metrics, worker pushes it to Pushgateway.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "Number of HTTP requests being served.",
+	})
+
+	TransactionsCreated = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_created_total",
+		Help:      "Number of created transactions by type.",
+	}, []string{"type"})
+
+	BalanceCheckFailures = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "balance_check_failures_total",
+		Help:      "Number of expenses rejected because wallet balance wasn't enough.",
+	})
+
+	EventStreams = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_streams_open",
+		Help:      "Number of open wallet event streams.",
+	})
+
+	JobDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "duration_seconds",
+		Help:      "Duration of worker job runs by result.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"job", "result"})
+
+	JobLastSuccess = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of last successful worker job run.",
+	}, []string{"job"})
+)
+
+// Register collectors only API server needs: Go runtime, process, database pool stats and age of exchange rates. Rates age is calculated on every scrape, so it's correct across all instances.
+func RegisterServer(db *sql.DB, lastRatesFetch func(ctx context.Context) (time.Time, error)) {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "postgres"),
+	)
+
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "exchange_rates_age_seconds",
+		Help:      "Time since the oldest stored exchange rate was fetched. NaN if there are no rates or database is unreachable.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		fetchedAt, err := lastRatesFetch(ctx)
+
+		if err != nil {
+			return math.NaN()
+		}
+
+		return time.Since(fetchedAt).Seconds()
+	})
+}
+
+// Record duration and result of worker job run
+func ObserveJob(job string, duration time.Duration, err error) {
+	result := "success"
+
+	if err != nil {
+		result = "failure"
+	} else {
+		JobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+	}
+
+	JobDuration.WithLabelValues(job, result).Observe(duration.Seconds())
+}
+
+// Push metrics of worker run to Pushgateway, grouped by job, so runs of different jobs don't overwrite each other
+func Push(ctx context.Context, url string, job string) error {
+	return push.New(url, "all_wallets_worker").
+		Grouping("worker_job", job).
+		Gatherer(Registry).
+		PushContext(ctx)
+}
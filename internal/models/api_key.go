@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Actions API key may be allowed to perform
+const (
+	ScopeRead              = "read"
+	ScopeTransactionsWrite = "transactions:write"
+)
+
+type APIKey struct {
+	ID     int      `json:"id"`
+	UserID int      `json:"-"`
+	Name   string   `json:"name"`
+	KeyID  string   `json:"key_id"`
+	Scopes []string `json:"scopes"`
+	// Empty list means all wallets of the user
+	WalletIDs []int `json:"wallet_ids"`
+	// Key was created in session confirmed with second factor, so it may access wallets requiring it
+	TwoFactor  bool       `json:"two_factor"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Return true if key allows the action
+func (k APIKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return true if key may be used with the wallet
+func (k APIKey) AllowsWallet(walletID int) bool {
+	if len(k.WalletIDs) == 0 {
+		return true
+	}
+
+	for _, allowed := range k.WalletIDs {
+		if allowed == walletID {
+			return true
+		}
+	}
+
+	return false
+}
+
+type NewAPIKeyInput struct {
+	Name      string   `json:"name" binding:"required,max=64"`
+	Scopes    []string `json:"scopes" binding:"required,min=1,dive,oneof=read transactions:write"`
+	WalletIDs []int    `json:"wallet_ids" binding:"omitempty,dive,gt=0"`
+	// Key never expires if omitted
+	ExpiresInDays int `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
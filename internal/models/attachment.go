@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Receipt or other file attached to transaction
+type Attachment struct {
+	ID            int    `json:"id"`
+	TransactionID int    `json:"transaction_id"`
+	UploaderID    int    `json:"uploader_id"`
+	FileName      string `json:"file_name"`
+	// Detected from file content, client provided type isn't trusted
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Key of file in blob store
+	StorageKey string `json:"-"`
+}
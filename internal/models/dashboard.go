@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Wallet figures computed in database for dashboard. Amounts are in wallet currency minor units.
+type WalletSummary struct {
+	WalletID       int
+	WalletName     string
+	Currency       string
+	DecimalPlaces  int
+	UserRole       string
+	Balance        int
+	LastActivityAt *time.Time
+	MonthIncome    int
+	MonthExpense   int
+	MemberCount    int
+}
+
+type DashboardWallet struct {
+	WalletOutput
+	// Time of the latest transaction, null for wallet without transactions
+	LastActivityAt *time.Time `json:"last_activity_at"`
+	MonthIncome    string     `json:"month_income"`
+	MonthExpense   string     `json:"month_expense"`
+	MemberCount    int        `json:"member_count"`
+}
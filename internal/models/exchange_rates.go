@@ -9,6 +9,11 @@ type ExchangeRate struct {
 	FetchedAt    time.Time `json:"fetched_at"`
 }
 
+type ExchangeRateQuery struct {
+	From string `form:"from" binding:"required,currency"`
+	To   string `form:"to" binding:"required,currency"`
+}
+
 // Constructor function for exchange rate
 func NewExchangeRate(from, to string, rate float64) ExchangeRate {
 	return ExchangeRate{
@@ -0,0 +1,9 @@
+package models
+
+// Response saved for idempotency key. StatusCode is 0 while the first request is still being served.
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
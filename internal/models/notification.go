@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Kinds of notifications. Every kind can be muted in preferences.
+const (
+	NotificationInvitation             = "invitation"
+	NotificationLargeExpense           = "large_expense"
+	NotificationBudgetAlert            = "budget_alert"
+	NotificationRecurringPaymentFailed = "recurring_payment_failed"
+)
+
+type Notification struct {
+	ID       int64          `json:"id"`
+	Kind     string         `json:"kind"`
+	WalletID *int           `json:"wallet_id"`
+	Message  string         `json:"message"`
+	Data     map[string]any `json:"data"`
+	// Nil while notification is unread
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type NotificationPreferences struct {
+	Email string `json:"email" binding:"required_if=EmailDigest true,omitempty,email,max=254"`
+	// Unread notifications are emailed in digests
+	EmailDigest bool     `json:"email_digest"`
+	MutedKinds  []string `json:"muted_kinds" binding:"omitempty,dive,oneof=invitation large_expense budget_alert recurring_payment_failed"`
+	// Expenses in wallets of the user at least that large in user base currency are notified about. Nil turns it off.
+	LargeExpenseThreshold *float64 `json:"large_expense_threshold" binding:"omitempty,gt=0"`
+}
+
+// Unread notifications of the user which weren't emailed yet
+type NotificationDigest struct {
+	UserID        int
+	Email         string
+	Notifications []Notification
+}
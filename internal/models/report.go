@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Period of report, both dates are included. From defaults to start of current month, missing To doesn't limit period. Days start at midnight in server time zone, which transaction times are stored in.
+type ReportInput struct {
+	From *time.Time `form:"from" time_format:"2006-01-02" time_location:"Local"`
+	To   *time.Time `form:"to" time_format:"2006-01-02" time_location:"Local"`
+}
+
+// Income and expense of category in minor units. Split transactions are counted by their split lines.
+type CategoryTotal struct {
+	Category string
+	Income   int
+	Expense  int
+}
+
+type CategoryTotalOutput struct {
+	Category string `json:"category"`
+	Income   string `json:"income"`
+	Expense  string `json:"expense"`
+}
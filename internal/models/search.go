@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+type SearchInput struct {
+	// Words and operators, e.g. pharmacy amount>100 before:2026-05-01
+	Query string `form:"q" binding:"required,max=200"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// Operators amount can be compared with
+const (
+	AmountGreater        = ">"
+	AmountGreaterOrEqual = ">="
+	AmountLess           = "<"
+	AmountLessOrEqual    = "<="
+	AmountEqual          = "="
+)
+
+// Amount condition in wallet currency units, e.g. 100 dollars
+type AmountCondition struct {
+	Operator string
+	Value    float64
+}
+
+// Parsed search request. Zero fields don't narrow results.
+type SearchQuery struct {
+	// Postgres tsquery matched against description, payee, category and tags. Empty if request has only operators.
+	TSQuery string
+	Amounts []AmountCondition
+	// Transactions made before start of that day
+	Before *time.Time
+	// Transactions made on that day or later
+	After *time.Time
+	// Income or expense
+	Type  string
+	Limit int
+	// Restricts search to these wallets of the user, e.g. ones API key may access
+	WalletIDs []int
+	// Include wallets requiring second factor
+	TwoFactor bool
+}
+
+// Transaction found by search with wallet it belongs to
+type SearchHit struct {
+	Transaction   Transaction
+	WalletName    string
+	Currency      string
+	DecimalPlaces int
+	Rank          float64
+}
+
+type SearchResult struct {
+	Transaction TransactionOutput `json:"transaction"`
+	WalletID    int               `json:"wallet_id"`
+	WalletName  string            `json:"wallet_name"`
+	Currency    string            `json:"currency"`
+	// Relevance of transaction to words of query, higher is better. Zero if query has only operators.
+	Rank float64 `json:"rank"`
+}
@@ -5,26 +5,86 @@ import (
 )
 
 type Transaction struct {
-	ID        int       `json:"id"`
-	Amount    int       `json:"amount"`
-	IsDeposit bool      `json:"is_deposit"`
-	Category  string    `json:"category"`
-	WalletID  int       `json:"wallet_id"`
-	CreatorID int       `json:"creator_id"`
+	ID          int    `json:"id"`
+	Amount      int    `json:"amount"`
+	IsDeposit   bool   `json:"is_deposit"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	Payee       string `json:"payee"`
+	WalletID    int    `json:"wallet_id"`
+	CreatorID   int    `json:"creator_id"`
+	// When transaction was recorded
 	CreatedAt time.Time `json:"created_at"`
+	// When money moved, may be earlier than CreatedAt for backdated transactions
+	OccurredAt time.Time `json:"occurred_at"`
+	// Tag names sorted alphabetically
+	Tags []string `json:"tags"`
+	// Empty if transaction isn't split
+	Splits      []TransactionSplit `json:"splits"`
+	Attachments []Attachment       `json:"attachments"`
 }
 
+// Part of transaction amount belonging to category
+type TransactionSplit struct {
+	Category string `json:"category"`
+	Amount   int    `json:"amount"`
+}
+
+// Transaction types clients send
+const (
+	TransactionIncome  = "income"
+	TransactionExpense = "expense"
+)
+
 type TransactionInput struct {
-	Amount   float64 `json:"amount"`
-	Category string  `json:"category"`
-	WalletID int     `json:"wallet_id"`
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+	// Category of whole transaction, e.g. Supermarket for split receipt
+	Category string `json:"category" binding:"required,category"`
+	WalletID int    `json:"wallet_id" binding:"required,gt=0"`
+	// Optional free text, e.g. what exactly was bought
+	Description string `json:"description" binding:"max=500"`
+	// Optional merchant or person money was paid to or received from
+	Payee string `json:"payee" binding:"max=100"`
+	// Tags are case-insensitive, duplicates are ignored
+	Tags []string `json:"tags" binding:"max=20,dive,tag"`
+	// Breakdown of amount by categories, split amounts must sum up to amount exactly in wallet currency minor units
+	Splits []TransactionSplitInput `json:"splits" binding:"omitempty,min=2,max=50,dive"`
+	// Defaults to current time. Can't be more than validation.MaxOccurredAtAhead in the future
+	OccurredAt *time.Time `json:"occurred_at" binding:"omitempty,occurred_at"`
+	// Ignored by legacy /add-income and /add-expense routes, which define type themselves
+	Type string `json:"type" binding:"omitempty,oneof=income expense"`
+}
+
+type TransactionSplitInput struct {
+	Category string  `json:"category" binding:"required,category"`
+	Amount   float64 `json:"amount" binding:"required,gt=0"`
 }
 
 type TransactionOutput struct {
-	ID        int       `json:"id"`
-	Amount    string    `json:"amount"`
-	IsDeposit bool      `json:"is_deposit"`
-	Category  string    `json:"category"`
-	CreatorID int       `json:"creator_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          int                      `json:"id"`
+	Amount      string                   `json:"amount"`
+	IsDeposit   bool                     `json:"is_deposit"`
+	Category    string                   `json:"category"`
+	Description string                   `json:"description"`
+	Payee       string                   `json:"payee"`
+	Tags        []string                 `json:"tags"`
+	Splits      []TransactionSplitOutput `json:"splits"`
+	Attachments []Attachment             `json:"attachments"`
+	CreatorID   int                      `json:"creator_id"`
+	CreatedAt   time.Time                `json:"created_at"`
+	OccurredAt  time.Time                `json:"occurred_at"`
+}
+
+type TransactionSplitOutput struct {
+	Category string `json:"category"`
+	Amount   string `json:"amount"`
+}
+
+// Narrows list of wallet transactions. Empty fields don't filter.
+type TransactionFilter struct {
+	// Matched against description, payee, category and tags, case-insensitive
+	Query string `form:"q" binding:"max=100"`
+	Tag   string `form:"tag" binding:"omitempty,tag"`
+	// Whole payee, case-insensitive
+	Payee string `form:"payee" binding:"max=100"`
 }
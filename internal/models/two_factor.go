@@ -0,0 +1,23 @@
+package models
+
+type TwoFactor struct {
+	UserID    int
+	Secret    string
+	IsEnabled bool
+	// Zero if no code was used yet
+	LastUsedStep int64
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorLoginInput struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorInput struct {
+	Password string `json:"user_pwd" binding:"required,password"`
+	Code     string `json:"code" binding:"required"`
+}
@@ -10,6 +10,8 @@ type User struct {
 	CreatedAt    time.Time `json:"created_at"`
 	IsDeleted    bool      `json:"is_deleted"`
 	DeletedAt    time.Time `json:"deleted_at"`
+	TokenVersion int       `json:"token_version"`
+	PurgedAt     time.Time `json:"purged_at"`
 }
 
 type UserOutput struct {
@@ -20,12 +22,43 @@ type UserOutput struct {
 }
 
 type LoginInputs struct {
-	Username string `json:"username"`
-	Password string `json:"user_pwd"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"user_pwd" binding:"required,password"`
+}
+
+type RestoreUserInputs struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"user_pwd" binding:"required,password"`
+	// Required only if user has second factor enabled
+	TwoFactorCode string `json:"two_factor_code"`
 }
 
 type SigninInputs struct {
-	Username     string `json:"username"`
-	Password     string `json:"user_pwd"`
-	BaseCurrency string `json:"base_currency"`
+	Username     string `json:"username" binding:"required,username"`
+	Password     string `json:"user_pwd" binding:"required,password"`
+	BaseCurrency string `json:"base_currency" binding:"required,currency"`
+}
+
+type ChangePasswordInputs struct {
+	CurrentPassword string `json:"current_pwd" binding:"required,password"`
+	NewPassword     string `json:"new_pwd" binding:"required,password"`
+}
+
+type ChangeUsernameInputs struct {
+	Username string `json:"username" binding:"required,username"`
+}
+
+type ChangeBaseCurrencyInputs struct {
+	BaseCurrency string `json:"base_currency" binding:"required,currency"`
+}
+
+// Decision about wallet where deleting user is the only admin. Action is either "handover" to existing wallet user with provided username or "delete".
+type WalletHandover struct {
+	WalletID int    `json:"wallet_id" binding:"required,gt=0"`
+	Action   string `json:"action" binding:"required,oneof=handover delete"`
+	NewAdmin string `json:"new_admin" binding:"required_if=Action handover"`
+}
+
+type DeleteUserInputs struct {
+	Wallets []WalletHandover `json:"wallets" binding:"dive"`
 }
@@ -3,17 +3,24 @@ package models
 import "time"
 
 type Wallet struct {
-	ID           int       `json:"id"`
-	WalletName   string    `json:"wallet_name"`
-	Currency     string    `json:"currency"`
-	Balance      int       `json:"balance"`
-	LastSnapshot time.Time `json:"last_snapshot"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID               int       `json:"id"`
+	WalletName       string    `json:"wallet_name"`
+	Currency         string    `json:"currency"`
+	Balance          int       `json:"balance"`
+	LastSnapshot     time.Time `json:"last_snapshot"`
+	CreatedAt        time.Time `json:"created_at"`
+	IsArchived       bool      `json:"is_archived"`
+	ArchivedAt       time.Time `json:"archived_at"`
+	RequireTwoFactor bool      `json:"require_2fa"`
 }
 
 type NewWalletRequest struct {
-	WalletName string `json:"wallet_name"`
-	Currency   string `json:"currency"`
+	WalletName string `json:"wallet_name" binding:"required,wallet_name"`
+	Currency   string `json:"currency" binding:"required,currency"`
+}
+
+type RequireTwoFactorInput struct {
+	Required *bool `json:"required" binding:"required"`
 }
 
 type WalletOutput struct {
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Kinds of wallet events streamed to wallet members and sent to webhooks. Transactions can't be deleted, so there is no event for it.
+const (
+	EventTransactionCreated = "transaction.created"
+	EventTransactionUpdated = "transaction.updated"
+	EventBalanceUpdated     = "balance.updated"
+	EventMemberAdded        = "member.added"
+	EventMemberRemoved      = "member.removed"
+	// Streams of the wallet are closed after it
+	EventWalletArchived = "wallet.archived"
+	EventWalletRestored = "wallet.restored"
+)
+
+// Change of wallet pushed to all its members
+type WalletEvent struct {
+	Type     string         `json:"type"`
+	WalletID int            `json:"wallet_id"`
+	Data     map[string]any `json:"data"`
+	At       time.Time      `json:"at"`
+}
+
+// Reference to recorded wallet event sent to API instances with Postgres NOTIFY. Notification payload is limited to 8000 bytes, so full event is loaded by id.
+type WalletEventNotice struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	WalletID int    `json:"wallet_id"`
+}
@@ -10,9 +10,9 @@ type WalletUser struct {
 }
 
 type NewWalletUserRequest struct {
-	WalletID int    `json:"wallet_id"`
-	Username string `json:"username"`
-	UserRole string `json:"user_role"`
+	WalletID int    `json:"wallet_id" binding:"required,gt=0"`
+	Username string `json:"username" binding:"required"`
+	UserRole string `json:"user_role" binding:"required,oneof=admin user spectator"`
 }
 
 type RemoveWalletUserRequest struct {
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Statuses of webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// Delivery ran out of attempts
+	DeliveryFailed = "failed"
+)
+
+// Subscription of external URL to events of a wallet. Secret is never shown after webhook is created.
+type Webhook struct {
+	ID         int       `json:"id"`
+	WalletID   int       `json:"wallet_id"`
+	CreatorID  int       `json:"creator_id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type NewWebhookInput struct {
+	WalletID   int      `json:"-"`
+	URL        string   `json:"url" binding:"required,http_url,max=2048"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,dive,oneof=transaction.created transaction.updated balance.updated member.added member.removed wallet.archived wallet.restored"`
+	// Random secret is generated if omitted
+	Secret string `json:"secret" binding:"omitempty,min=16,max=128"`
+}
+
+// Entry of webhook delivery log
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int        `json:"webhook_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Delivery claimed by worker together with everything needed to send it. Attempts include the current one.
+type PendingDelivery struct {
+	ID        int64
+	WebhookID int
+	URL       string
+	Secret    string
+	EventType string
+	Payload   []byte
+	Attempts  int
+}
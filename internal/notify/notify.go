@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/config"
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Email sent to user
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users. Worker picks implementation with NOTIFICATIONS_SENDER.
+type Notifier interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Return notifier configured with cfg. Returned close function must be called once notifier isn't needed.
+func New(cfg config.NotificationsConfig) (Notifier, func() error, error) {
+	switch cfg.Sender {
+	case "smtp":
+		return NewSMTP(cfg.SMTP), func() error { return nil }, nil
+	case "log":
+		if cfg.LogFile == "" {
+			return NewLog(os.Stdout), func() error { return nil }, nil
+		}
+
+		file, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return NewLog(file), file.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown notifications sender %q", cfg.Sender)
+	}
+}
+
+// Log notifier writes messages instead of sending them, so digests can be checked during development.
+type Log struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Constructor function for log notifier writing to w
+func NewLog(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+func (l *Log) Send(ctx context.Context, message Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := fmt.Fprintf(l.w, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), message.To, message.Subject, message.Body)
+
+	return err
+}
+
+// Return email listing notifications of digest
+func DigestMessage(digest models.NotificationDigest) Message {
+	var body strings.Builder
+
+	for _, notification := range digest.Notifications {
+		fmt.Fprintf(&body, "%s  %s\n", notification.CreatedAt.UTC().Format("2006-01-02 15:04 UTC"), notification.Message)
+	}
+
+	body.WriteString("\nOpen AllWallets to see details. Notifications you read there are not emailed. Digests can be turned off in notification preferences.")
+
+	subject := "You have a new notification in AllWallets"
+
+	if len(digest.Notifications) > 1 {
+		subject = fmt.Sprintf("You have %d new notifications in AllWallets", len(digest.Notifications))
+	}
+
+	return Message{To: digest.Email, Subject: subject, Body: body.String()}
+}
@@ -0,0 +1,173 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Argon2Params are stored in every argon2id hash, so they can be raised later without breaking existing hashes.
+type Argon2Params struct {
+	MemoryKiB   uint32 `yaml:"memory_kib"`
+	Iterations  uint32 `yaml:"iterations"`
+	Parallelism uint8  `yaml:"parallelism"`
+}
+
+// Hasher creates hashes with configured algorithm and verifies hashes of any supported algorithm. Hash format tells which algorithm produced it: bcrypt hashes start with $2, argon2id ones are in PHC format $argon2id$v=19$m=...,t=...,p=...$salt$key.
+type Hasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+	dummyHash  string
+	// Limits concurrent hashing, so burst of logins can't take all memory argon2id needs
+	slots chan struct{}
+}
+
+// Constructor function for hasher. Dummy hash for unknown users is generated here with the same parameters as real hashes.
+func NewHasher(algorithm string, argon2Params Argon2Params, bcryptCost int) (*Hasher, error) {
+	h := &Hasher{
+		algorithm:  algorithm,
+		argon2:     argon2Params,
+		bcryptCost: bcryptCost,
+		slots:      make(chan struct{}, runtime.NumCPU()),
+	}
+
+	if algorithm != Argon2id && algorithm != Bcrypt {
+		return nil, fmt.Errorf("unknown password hash algorithm %q", algorithm)
+	}
+
+	dummyPassword := make([]byte, 32)
+	rand.Read(dummyPassword)
+
+	dummyHash, err := h.Hash(base64.RawStdEncoding.EncodeToString(dummyPassword))
+
+	if err != nil {
+		return nil, err
+	}
+
+	h.dummyHash = dummyHash
+
+	return h, nil
+}
+
+// Return hash of password made with configured algorithm
+func (h *Hasher) Hash(password string) (string, error) {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	if h.algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.argon2.Iterations, h.argon2.MemoryKiB, h.argon2.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id,
+		argon2.Version,
+		h.argon2.MemoryKiB,
+		h.argon2.Iterations,
+		h.argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Return true if password matches hash. needsRehash is true if hash was made with other algorithm or parameters than configured ones, so caller should replace it while it knows the password.
+func (h *Hasher) Verify(password, hash string) (match bool, needsRehash bool) {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return false, false
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+
+		return true, h.algorithm != Bcrypt || err != nil || cost != h.bcryptCost
+
+	case strings.HasPrefix(hash, "$"+Argon2id+"$"):
+		params, salt, key, err := parseArgon2(hash)
+
+		if err != nil {
+			return false, false
+		}
+
+		candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.MemoryKiB, params.Parallelism, uint32(len(key)))
+
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false
+		}
+
+		return true, h.algorithm != Argon2id || params != h.argon2
+
+	default:
+		return false, false
+	}
+}
+
+// Spend the same time as Verify without any real hash. Call it when user doesn't exist, so response time doesn't reveal that.
+func (h *Hasher) DummyVerify(password string) {
+	h.Verify(password, h.dummyHash)
+}
+
+func parseArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var params Argon2Params
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,142 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Minimal parameters keep tests fast
+var testArgon2 = Argon2Params{MemoryKiB: 64, Iterations: 1, Parallelism: 1}
+
+func newTestHasher(t *testing.T, algorithm string, argon2Params Argon2Params, bcryptCost int) *Hasher {
+	t.Helper()
+
+	h, err := NewHasher(algorithm, argon2Params, bcryptCost)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		prefix    string
+	}{
+		{Argon2id, "$argon2id$v=19$m=64,t=1,p=1$"},
+		{Bcrypt, "$2a$04$"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.algorithm, func(t *testing.T) {
+			h := newTestHasher(t, test.algorithm, testArgon2, bcrypt.MinCost)
+
+			hash, err := h.Hash("correct horse battery")
+
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			if !strings.HasPrefix(hash, test.prefix) {
+				t.Errorf("hash = %q, want prefix %q", hash, test.prefix)
+			}
+
+			if other, _ := h.Hash("correct horse battery"); other == hash {
+				t.Error("Hashes of the same password are equal, salt isn't random")
+			}
+
+			if match, needsRehash := h.Verify("correct horse battery", hash); !match || needsRehash {
+				t.Errorf("Verify(correct) = %t, %t, want true, false", match, needsRehash)
+			}
+
+			if match, _ := h.Verify("correct horse batterY", hash); match {
+				t.Error("Verify(wrong) = true")
+			}
+		})
+	}
+}
+
+func TestVerifyDetectsRehash(t *testing.T) {
+	stronger := Argon2Params{MemoryKiB: 128, Iterations: 2, Parallelism: 1}
+
+	tests := []struct {
+		name        string
+		hashedBy    *Hasher
+		verifiedBy  *Hasher
+		needsRehash bool
+	}{
+		{"same bcrypt cost", newTestHasher(t, Bcrypt, testArgon2, bcrypt.MinCost), newTestHasher(t, Bcrypt, testArgon2, bcrypt.MinCost), false},
+		{"bcrypt cost raised", newTestHasher(t, Bcrypt, testArgon2, bcrypt.MinCost), newTestHasher(t, Bcrypt, testArgon2, bcrypt.MinCost+1), true},
+		{"bcrypt to argon2id", newTestHasher(t, Bcrypt, testArgon2, bcrypt.MinCost), newTestHasher(t, Argon2id, testArgon2, bcrypt.MinCost), true},
+		{"same argon2id params", newTestHasher(t, Argon2id, testArgon2, bcrypt.MinCost), newTestHasher(t, Argon2id, testArgon2, bcrypt.MinCost), false},
+		{"argon2id params raised", newTestHasher(t, Argon2id, testArgon2, bcrypt.MinCost), newTestHasher(t, Argon2id, stronger, bcrypt.MinCost), true},
+		{"argon2id to bcrypt", newTestHasher(t, Argon2id, testArgon2, bcrypt.MinCost), newTestHasher(t, Bcrypt, testArgon2, bcrypt.MinCost), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash, err := test.hashedBy.Hash("correct horse battery")
+
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			match, needsRehash := test.verifiedBy.Verify("correct horse battery", hash)
+
+			if !match || needsRehash != test.needsRehash {
+				t.Errorf("Verify = %t, %t, want true, %t", match, needsRehash, test.needsRehash)
+			}
+
+			// Wrong password never asks for rehash, there is nothing to rehash with
+			if match, needsRehash := test.verifiedBy.Verify("wrong horse battery", hash); match || needsRehash {
+				t.Errorf("Verify(wrong) = %t, %t, want false, false", match, needsRehash)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	h := newTestHasher(t, Argon2id, testArgon2, bcrypt.MinCost)
+
+	hashes := []string{
+		"",
+		"correct horse battery",
+		"$2a$04$broken",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=sixty-four,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+	}
+
+	for _, hash := range hashes {
+		if match, needsRehash := h.Verify("correct horse battery", hash); match || needsRehash {
+			t.Errorf("Verify(%q) = %t, %t, want false, false", hash, match, needsRehash)
+		}
+	}
+}
+
+func TestBcryptRefusesPasswordsOver72Bytes(t *testing.T) {
+	h := newTestHasher(t, Bcrypt, testArgon2, bcrypt.MinCost)
+
+	if _, err := h.Hash(strings.Repeat("a", 72)); err != nil {
+		t.Fatalf("Hash of 72 bytes: %v", err)
+	}
+
+	// Policy and config cap passwords at 72 bytes with bcrypt, hashing longer one would silently drop the rest
+	if _, err := h.Hash(strings.Repeat("a", 73)); !errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		t.Errorf("Hash of 73 bytes: err = %v, want %v", err, bcrypt.ErrPasswordTooLong)
+	}
+}
+
+func TestNewHasherRejectsUnknownAlgorithm(t *testing.T) {
+	if _, err := NewHasher("md5", testArgon2, bcrypt.MinCost); err == nil {
+		t.Error("NewHasher(md5) succeeded")
+	}
+}
@@ -0,0 +1,112 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Hard limit for any password, checked before policy, so nobody can make server hash megabytes of data
+const MaxBytes = 1024
+
+// Rule codes reported in PolicyError
+const (
+	RuleTooShort         = "too_short"
+	RuleTooLong          = "too_long"
+	RuleBreached         = "breached"
+	RuleContainsUsername = "contains_username"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PolicyError lists every rule new password breaks
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password breaks policy rules: " + strings.Join(e.Violations, ", ")
+}
+
+// Policy decides which new passwords are accepted. It doesn't apply to existing passwords, so stricter policy doesn't lock anybody out.
+type Policy struct {
+	// Length is counted in characters
+	MinLength int
+	// bcrypt ignores everything after 72 bytes, so limit is counted in bytes
+	MaxBytes int
+	breached map[string]struct{}
+}
+
+// Constructor function for policy. Built-in list of common passwords is always used, breachedListPath adds passwords from file with one password per line. Comparison ignores case.
+func NewPolicy(minLength, maxBytes int, breachedListPath string) (*Policy, error) {
+	p := &Policy{MinLength: minLength, MaxBytes: maxBytes, breached: make(map[string]struct{})}
+
+	p.addBreached(bufio.NewScanner(strings.NewReader(commonPasswords)))
+
+	if breachedListPath == "" {
+		return p, nil
+	}
+
+	file, err := os.Open(breachedListPath)
+
+	if err != nil {
+		return nil, fmt.Errorf("can't open breached passwords list: %w", err)
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	if err := p.addBreached(scanner); err != nil {
+		return nil, fmt.Errorf("can't read breached passwords list: %w", err)
+	}
+
+	return p, nil
+}
+
+// Return nil if password may be used by user with provided username, otherwise *PolicyError with all broken rules.
+func (p *Policy) Check(password, username string) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, RuleTooShort)
+	}
+
+	if len(password) > p.MaxBytes {
+		violations = append(violations, RuleTooLong)
+	}
+
+	lowered := strings.ToLower(password)
+
+	if _, ok := p.breached[lowered]; ok {
+		violations = append(violations, RuleBreached)
+	}
+
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		violations = append(violations, RuleContainsUsername)
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func (p *Policy) addBreached(scanner *bufio.Scanner) error {
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+
+	return scanner.Err()
+}
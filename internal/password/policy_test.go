@@ -0,0 +1,108 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := NewPolicy(8, 72, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		username   string
+		violations []string
+	}{
+		{"good", "correct horse battery", "alice", nil},
+		{"too short", "c0rrect", "alice", []string{RuleTooShort}},
+		{"length counted in characters", "ąęółśżźć", "alice", nil},
+		{"exactly max bytes", strings.Repeat("a", 72), "alice", nil},
+		{"too long", strings.Repeat("a", 73), "alice", []string{RuleTooLong}},
+		{"too long in bytes, not characters", strings.Repeat("ą", 37), "alice", []string{RuleTooLong}},
+		{"common", "password123", "alice", []string{RuleBreached}},
+		{"common in other case", "PassWord123", "alice", []string{RuleBreached}},
+		{"common and short", "123456", "alice", []string{RuleTooShort, RuleBreached}},
+		{"contains username", "alice-in-wonderland", "Alice", []string{RuleContainsUsername}},
+		{"no username to compare", "alice-in-wonderland", "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.Check(test.password, test.username)
+
+			if test.violations == nil {
+				if err != nil {
+					t.Errorf("Check = %v, want nil", err)
+				}
+
+				return
+			}
+
+			var policyErr *PolicyError
+
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Check = %v, want *PolicyError", err)
+			}
+
+			if !reflect.DeepEqual(policyErr.Violations, test.violations) {
+				t.Errorf("violations = %v, want %v", policyErr.Violations, test.violations)
+			}
+		})
+	}
+}
+
+func TestPolicyHardLimit(t *testing.T) {
+	policy, err := NewPolicy(8, MaxBytes, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := policy.Check(strings.Repeat("a", MaxBytes), ""); err != nil {
+		t.Errorf("Check of %d bytes = %v, want nil", MaxBytes, err)
+	}
+
+	var policyErr *PolicyError
+
+	if err := policy.Check(strings.Repeat("a", MaxBytes+1), ""); !errors.As(err, &policyErr) || !reflect.DeepEqual(policyErr.Violations, []string{RuleTooLong}) {
+		t.Errorf("Check of %d bytes = %v, want %s", MaxBytes+1, err, RuleTooLong)
+	}
+}
+
+func TestPolicyBreachedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+
+	if err := os.WriteFile(path, []byte("# leaked in 2026\nHunter2Hunter2\n\n  tr0ub4dor&3  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := NewPolicy(8, 72, path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// File adds to built-in list instead of replacing it
+	for _, breached := range []string{"hunter2hunter2", "Tr0ub4dor&3", "password123"} {
+		if err := policy.Check(breached, ""); err == nil {
+			t.Errorf("Check(%q) = nil, want %s", breached, RuleBreached)
+		}
+	}
+
+	if err := policy.Check("# leaked in 2026", ""); err != nil {
+		t.Errorf("Check of comment line = %v, want nil", err)
+	}
+
+	if _, err := NewPolicy(8, 72, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("NewPolicy with missing list succeeded")
+	}
+}
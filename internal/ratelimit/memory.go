@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Buckets are scanned for cleanup once per that number of takes
+const cleanupEvery = 1000
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	rate      Rate
+}
+
+// Memory keeps buckets in process memory. It fits single instance deploys, every instance has own buckets.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+	now     func() time.Time
+}
+
+// Constructor function for in-memory limiter
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, rate Rate) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	m.takes++
+
+	if m.takes%cleanupEvery == 0 {
+		m.cleanup(now)
+	}
+
+	b, ok := m.buckets[key]
+
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), updatedAt: now}
+		m.buckets[key] = b
+	}
+
+	b.rate = rate
+
+	var wait time.Duration
+
+	b.tokens, wait = Refill(b.tokens, now.Sub(b.updatedAt), rate)
+	b.updatedAt = now
+
+	return wait, nil
+}
+
+// Drop buckets which are full again, they are the same as missing ones
+func (m *Memory) cleanup(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.updatedAt) >= b.rate.Period {
+			delete(m.buckets, key)
+		}
+	}
+}
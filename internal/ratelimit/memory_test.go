@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// One token per second, up to 4 at once
+var testRate = Rate{Burst: 4, Period: 4 * time.Second}
+
+func TestRefill(t *testing.T) {
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		wantTokens float64
+		wantWait   time.Duration
+	}{
+		{"full bucket", 4, 0, 3, 0},
+		{"empty bucket", 0, 0, 0, time.Second},
+		{"half token refilled", 0, 500 * time.Millisecond, 0.5, 500 * time.Millisecond},
+		{"whole token refilled", 0, time.Second, 0, 0},
+		{"refill capped at burst", 3, time.Hour, 3, 0},
+		{"clock moved back", 0, -time.Minute, 0, time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, wait := Refill(test.tokens, test.elapsed, testRate)
+
+			if tokens != test.wantTokens || wait != test.wantWait {
+				t.Errorf("Refill(%v, %v) = %v, %v, want %v, %v", test.tokens, test.elapsed, tokens, wait, test.wantTokens, test.wantWait)
+			}
+		})
+	}
+}
+
+func TestMemoryBurstAndRefill(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	limiter := NewMemory()
+	limiter.now = func() time.Time { return now }
+
+	// Every step advances clock and takes one token
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    time.Duration
+	}{
+		{"burst 1", 0, 0},
+		{"burst 2", 0, 0},
+		{"burst 3", 0, 0},
+		{"burst 4", 0, 0},
+		{"burst exhausted", 0, time.Second},
+		{"half token refilled", 500 * time.Millisecond, 500 * time.Millisecond},
+		{"whole token refilled", 500 * time.Millisecond, 0},
+		{"refilled token taken", 0, time.Second},
+		{"idle for long", time.Hour, 0},
+		{"refill capped at burst 2", 0, 0},
+		{"refill capped at burst 3", 0, 0},
+		{"refill capped at burst 4", 0, 0},
+		{"refill capped at burst exhausted", 0, time.Second},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+
+		wait, err := limiter.Take(context.Background(), "login:ip:192.0.2.1", testRate)
+
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		if wait != step.want {
+			t.Errorf("%s: wait = %v, want %v", step.name, wait, step.want)
+		}
+	}
+}
+
+func TestMemoryKeysHaveOwnBuckets(t *testing.T) {
+	limiter := NewMemory()
+	ctx := context.Background()
+	rate := Rate{Burst: 1, Period: time.Hour}
+
+	if wait, _ := limiter.Take(ctx, "login:ip:192.0.2.1", rate); wait != 0 {
+		t.Fatalf("first take waits %v", wait)
+	}
+
+	if wait, _ := limiter.Take(ctx, "login:ip:192.0.2.1", rate); wait == 0 {
+		t.Error("second take from the same key is allowed")
+	}
+
+	if wait, _ := limiter.Take(ctx, "login:ip:192.0.2.2", rate); wait != 0 {
+		t.Errorf("take from another key waits %v", wait)
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate allows Burst requests at once, refilled evenly over Period. E.g. 5/1m allows 5 requests in a row and then one every 12 seconds.
+type Rate struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Limiter takes one token from bucket identified by key. Zero duration means request is allowed, otherwise it's how long caller must wait.
+type Limiter interface {
+	Take(ctx context.Context, key string, rate Rate) (time.Duration, error)
+}
+
+// Parse rate in "burst/period" format, e.g. "5/1m"
+func ParseRate(value string) (Rate, error) {
+	burst, period, found := strings.Cut(value, "/")
+
+	if !found {
+		return Rate{}, fmt.Errorf("rate %q must be in burst/period format, e.g. 5/1m", value)
+	}
+
+	var rate Rate
+	var err error
+
+	if rate.Burst, err = strconv.Atoi(burst); err != nil || rate.Burst <= 0 {
+		return Rate{}, fmt.Errorf("rate %q must have positive burst", value)
+	}
+
+	if rate.Period, err = time.ParseDuration(period); err != nil || rate.Period <= 0 {
+		return Rate{}, fmt.Errorf("rate %q must have positive period", value)
+	}
+
+	return rate, nil
+}
+
+// Let rate be read from YAML config
+func (r *Rate) UnmarshalText(text []byte) error {
+	rate, err := ParseRate(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	*r = rate
+
+	return nil
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Burst, r.Period)
+}
+
+// Return tokens added per second
+func (r Rate) perSecond() float64 {
+	return float64(r.Burst) / r.Period.Seconds()
+}
+
+// Refill bucket holding tokens for elapsed time and try to take one token. Return tokens left and how long to wait if bucket is empty. Both backends share it, so they behave the same.
+func Refill(tokens float64, elapsed time.Duration, rate Rate) (float64, time.Duration) {
+	tokens = math.Min(float64(rate.Burst), tokens+math.Max(elapsed.Seconds(), 0)*rate.perSecond())
+
+	if tokens >= 1 {
+		return tokens - 1, 0
+	}
+
+	wait := (1 - tokens) / rate.perSecond()
+
+	return tokens, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// Lockout locks account after Threshold failed logins in a row. Lock lasts BaseDelay and doubles with each next failure up to MaxDelay. Failures older than ResetAfter are forgotten.
+type Lockout struct {
+	Threshold  int           `yaml:"threshold"`
+	BaseDelay  time.Duration `yaml:"base_delay"`
+	MaxDelay   time.Duration `yaml:"max_delay"`
+	ResetAfter time.Duration `yaml:"reset_after"`
+}
+
+// Return how long account stays locked after given number of failures in a row
+func (l Lockout) Delay(failures int) time.Duration {
+	if failures < l.Threshold {
+		return 0
+	}
+
+	delay := l.BaseDelay
+
+	for i := l.Threshold; i < failures && delay < l.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, l.MaxDelay)
+}
@@ -0,0 +1,148 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// Amount operators in order they are tried, longer ones first so >= isn't read as >
+var amountOperators = []struct {
+	prefix   string
+	operator string
+}{
+	{">=", models.AmountGreaterOrEqual},
+	{"<=", models.AmountLessOrEqual},
+	{">", models.AmountGreater},
+	{"<", models.AmountLess},
+	{"=", models.AmountEqual},
+	{":", models.AmountEqual},
+}
+
+// Parse search request. Terms are separated by spaces:
+//   - amount>100, amount>=100, amount<100, amount<=100, amount=100 or amount:100 compare amount in wallet currency units
+//   - before:2026-05-01 and after:2026-04-01 limit dates, before excludes the day, after includes it
+//   - type:income or type:expense
+//   - -word excludes transactions containing word
+//   - any other word must be contained in description, payee, categories of transaction and its splits or tags, words match by prefix, so pharm finds pharmacy
+//
+// Return error describing the first malformed operator.
+func Parse(input string) (models.SearchQuery, error) {
+	var query models.SearchQuery
+	var lexemes []string
+
+	for _, term := range strings.Fields(input) {
+		name, value, isOperator := strings.Cut(term, ":")
+		name = strings.ToLower(name)
+		amountCondition, isAmount := strings.CutPrefix(strings.ToLower(term), "amount")
+		isAmount = isAmount && amountCondition != "" && strings.ContainsRune("<>=:", rune(amountCondition[0]))
+
+		switch {
+		case isAmount:
+			condition, err := parseAmount(amountCondition)
+
+			if err != nil {
+				return models.SearchQuery{}, err
+			}
+
+			query.Amounts = append(query.Amounts, condition)
+		case isOperator && name == "before":
+			date, err := parseDate(name, value)
+
+			if err != nil {
+				return models.SearchQuery{}, err
+			}
+
+			query.Before = &date
+		case isOperator && name == "after":
+			date, err := parseDate(name, value)
+
+			if err != nil {
+				return models.SearchQuery{}, err
+			}
+
+			query.After = &date
+		case isOperator && name == "type":
+			value = strings.ToLower(value)
+
+			if value != models.TransactionIncome && value != models.TransactionExpense {
+				return models.SearchQuery{}, fmt.Errorf("type must be %s or %s", models.TransactionIncome, models.TransactionExpense)
+			}
+
+			query.Type = value
+		default:
+			if lexeme := wordLexeme(term); lexeme != "" {
+				lexemes = append(lexemes, lexeme)
+			}
+		}
+	}
+
+	query.TSQuery = strings.Join(lexemes, " & ")
+
+	return query, nil
+}
+
+// Parse amount condition following "amount", e.g. >=100
+func parseAmount(condition string) (models.AmountCondition, error) {
+	for _, candidate := range amountOperators {
+		value, ok := strings.CutPrefix(condition, candidate.prefix)
+
+		if !ok {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(value, 64)
+
+		if err != nil || amount < 0 || math.IsInf(amount, 0) || math.IsNaN(amount) {
+			return models.AmountCondition{}, fmt.Errorf("amount must be compared with non-negative number, e.g. amount>100")
+		}
+
+		return models.AmountCondition{Operator: candidate.operator, Value: amount}, nil
+	}
+
+	return models.AmountCondition{}, fmt.Errorf("amount must be compared with >, >=, <, <= or =, e.g. amount>100")
+}
+
+// Return start of the day in server time zone, which transaction times are stored in
+func parseDate(name, value string) (time.Time, error) {
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be date like %s:2026-05-01", name, name)
+	}
+
+	return date, nil
+}
+
+// Return tsquery matching word by prefix, negated if word starts with "-". Punctuation splits word into parts that all must match, the way Postgres splits indexed text. Return empty string if word has no letters or digits.
+func wordLexeme(word string) string {
+	negated := strings.HasPrefix(word, "-")
+	word = strings.TrimPrefix(word, "-")
+
+	parts := strings.FieldsFunc(strings.ToLower(word), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	for i, part := range parts {
+		parts[i] = part + ":*"
+	}
+
+	lexeme := strings.Join(parts, " & ")
+
+	if !negated {
+		return lexeme
+	}
+
+	return "!(" + lexeme + ")"
+}
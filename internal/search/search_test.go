@@ -0,0 +1,113 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+func date(year int, month time.Month, day int) *time.Time {
+	value := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+
+	return &value
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  models.SearchQuery
+	}{
+		{"empty", "   ", models.SearchQuery{}},
+		{"words match by prefix", "Pharm  lunch", models.SearchQuery{TSQuery: "pharm:* & lunch:*"}},
+		{"negated word", "cafe -Green", models.SearchQuery{TSQuery: "cafe:* & !(green:*)"}},
+		{"punctuation splits word", "e-mail green_cafe", models.SearchQuery{TSQuery: "e:* & mail:* & green:* & cafe:*"}},
+		{"negated word with punctuation", "-o'neil", models.SearchQuery{TSQuery: "!(o:* & neil:*)"}},
+		{"word without letters or digits", "-- !!! -", models.SearchQuery{}},
+		{"letters of other alphabets", "Кафе café", models.SearchQuery{TSQuery: "кафе:* & café:*"}},
+		{"tsquery syntax is split away", "a|b&(c)", models.SearchQuery{TSQuery: "a:* & b:* & c:*"}},
+		{"amount greater or equal", "amount>=100", models.SearchQuery{Amounts: []models.AmountCondition{{Operator: models.AmountGreaterOrEqual, Value: 100}}}},
+		{"amount operators", "amount>1 amount<=2.5 amount<3 AMOUNT=4 amount:5", models.SearchQuery{Amounts: []models.AmountCondition{
+			{Operator: models.AmountGreater, Value: 1},
+			{Operator: models.AmountLessOrEqual, Value: 2.5},
+			{Operator: models.AmountLess, Value: 3},
+			{Operator: models.AmountEqual, Value: 4},
+			{Operator: models.AmountEqual, Value: 5},
+		}}},
+		{"amount word isn't operator", "amount amounts", models.SearchQuery{TSQuery: "amount:* & amounts:*"}},
+		{"negated amount is word", "-amount>5", models.SearchQuery{TSQuery: "!(amount:* & 5:*)"}},
+		{"dates", "before:2026-05-01 After:2026-04-01", models.SearchQuery{Before: date(2026, time.May, 1), After: date(2026, time.April, 1)}},
+		{"type", "type:Income", models.SearchQuery{Type: models.TransactionIncome}},
+		{"unknown operator is word", "note:lunch", models.SearchQuery{TSQuery: "note:* & lunch:*"}},
+		{"operators and words", "type:expense coffee amount<5 -decaf before:2026-05-01", models.SearchQuery{
+			TSQuery: "coffee:* & !(decaf:*)",
+			Amounts: []models.AmountCondition{{Operator: models.AmountLess, Value: 5}},
+			Before:  date(2026, time.May, 1),
+			Type:    models.TransactionExpense,
+		}},
+		{"last repeated date wins", "before:2026-05-01 before:2026-06-01", models.SearchQuery{Before: date(2026, time.June, 1)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, err := Parse(test.input)
+
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", test.input, err)
+			}
+
+			if !reflect.DeepEqual(query, test.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", test.input, query, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsMalformedOperators(t *testing.T) {
+	inputs := []string{
+		"amount>",
+		"amount>=abc",
+		"amount<-5",
+		"amount=Inf",
+		"amount:NaN",
+		"amount=>5",
+		"before:",
+		"before:yesterday",
+		"after:2026-13-01",
+		"after:01.05.2026",
+		"type:",
+		"type:transfer",
+		"lunch type:transfer",
+	}
+
+	for _, input := range inputs {
+		if query, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %+v, want error", input, query)
+		}
+	}
+}
+
+func TestWordLexeme(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"Pharm", "pharm:*"},
+		{"-pharm", "!(pharm:*)"},
+		{"--pharm", "!(pharm:*)"},
+		{"2026", "2026:*"},
+		{"e-mail", "e:* & mail:*"},
+		{"-e-mail", "!(e:* & mail:*)"},
+		{"'quoted'", "quoted:*"},
+		{"!&|():*", ""},
+		{"-", ""},
+	}
+
+	for _, test := range tests {
+		if got := wordLexeme(test.word); got != test.want {
+			t.Errorf("wordLexeme(%q) = %q, want %q", test.word, got, test.want)
+		}
+	}
+}
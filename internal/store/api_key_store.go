@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/lib/pq"
+)
+
+// last_used_at is updated at most once per that interval, so every request doesn't write to database
+const apiKeyTouchInterval = time.Minute
+
+const apiKeyColumns = "id, user_id, key_name, key_id, scopes, wallet_ids, two_factor, expires_at, last_used_at, created_at"
+
+type apiKeyStore struct {
+	db *sql.DB
+}
+
+// Insert new API key. Only hash of the key is stored.
+func (s *apiKeyStore) Create(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error) {
+	query := "INSERT INTO api_keys (user_id, key_name, key_id, key_hash, scopes, wallet_ids, two_factor, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING " + apiKeyColumns
+
+	row := s.db.QueryRowContext(ctx, query, key.UserID, key.Name, key.KeyID, keyHash, pq.Array(key.Scopes), pq.Array(intsToInt64s(key.WalletIDs)), key.TwoFactor, key.ExpiresAt)
+
+	return scanAPIKey(row)
+}
+
+// Return all not revoked keys of user, expired ones included
+func (s *apiKeyStore) GetByUser(ctx context.Context, userID int) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at"
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Revoke key of user. Return ErrAPIKeyNotFound if user has no such active key.
+func (s *apiKeyStore) Revoke(ctx context.Context, userID, keyID int) error {
+	query := "UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+
+	result, err := s.db.ExecContext(ctx, query, keyID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// Return usable key with provided public id and its hash, so caller can compare it with presented key. Revoked and expired keys, as well as keys of deleted users, are never returned.
+func (s *apiKeyStore) GetActive(ctx context.Context, keyID string) (models.APIKey, string, error) {
+	var keyHash string
+
+	query := "SELECT k.key_hash, " + prefixColumns("k", apiKeyColumns) + " FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.key_id = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW()) AND u.is_deleted = FALSE"
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, query, keyID), &keyHash)
+
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	return key, keyHash, nil
+}
+
+// Remember that key was used just now
+func (s *apiKeyStore) Touch(ctx context.Context, keyID int) error {
+	query := "UPDATE api_keys SET last_used_at = NOW() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)"
+
+	_, err := s.db.ExecContext(ctx, query, keyID, time.Now().Add(-apiKeyTouchInterval))
+
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Scan row with apiKeyColumns. Extra destinations go before them.
+func scanAPIKey(row scanner, extra ...any) (models.APIKey, error) {
+	var key models.APIKey
+	var walletIDs pq.Int64Array
+	var expiresAt, lastUsedAt sql.NullTime
+
+	dest := append(extra, &key.ID, &key.UserID, &key.Name, &key.KeyID, pq.Array(&key.Scopes), &walletIDs, &key.TwoFactor, &expiresAt, &lastUsedAt, &key.CreatedAt)
+
+	err := row.Scan(dest...)
+
+	if err == sql.ErrNoRows {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	} else if err != nil {
+		return models.APIKey{}, err
+	}
+
+	key.WalletIDs = make([]int, len(walletIDs))
+
+	for i, walletID := range walletIDs {
+		key.WalletIDs[i] = int(walletID)
+	}
+
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return key, nil
+}
+
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ", ")
+
+	for i, column := range parts {
+		parts[i] = alias + "." + column
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func intsToInt64s(values []int) []int64 {
+	converted := make([]int64, len(values))
+
+	for i, value := range values {
+		converted[i] = int64(value)
+	}
+
+	return converted
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/lib/pq"
+)
+
+const attachmentColumns = "id, transaction_id, uploader_id, file_name, content_type, size_bytes, storage_key, created_at"
+
+type attachmentStore struct {
+	db *sql.DB
+}
+
+// Insert attachment of transaction from the wallet. Return ErrTransactionNotFound if wallet has no such transaction.
+func (s *attachmentStore) Create(ctx context.Context, walletID int, attachment models.Attachment) (models.Attachment, error) {
+	query := `INSERT INTO transaction_attachments (transaction_id, uploader_id, file_name, content_type, size_bytes, storage_key)
+		SELECT t.id, $3::INT, $4::TEXT, $5::TEXT, $6::BIGINT, $7::TEXT FROM transactions t WHERE t.id = $1 AND t.wallet_id = $2
+		RETURNING ` + attachmentColumns
+
+	row := s.db.QueryRowContext(ctx, query, attachment.TransactionID, walletID, attachment.UploaderID, attachment.FileName, attachment.ContentType, attachment.Size, attachment.StorageKey)
+
+	created, err := scanAttachment(row)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Attachment{}, ErrTransactionNotFound
+	}
+
+	return created, err
+}
+
+// Return attachment of transaction from the wallet. Return ErrAttachmentNotFound if there is no such attachment.
+func (s *attachmentStore) Get(ctx context.Context, walletID, transactionID, attachmentID int) (models.Attachment, error) {
+	query := `SELECT a.id, a.transaction_id, a.uploader_id, a.file_name, a.content_type, a.size_bytes, a.storage_key, a.created_at
+		FROM transaction_attachments a JOIN transactions t ON t.id = a.transaction_id
+		WHERE a.id = $1 AND a.transaction_id = $2 AND t.wallet_id = $3`
+
+	attachment, err := scanAttachment(s.db.QueryRowContext(ctx, query, attachmentID, transactionID, walletID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Attachment{}, ErrAttachmentNotFound
+	}
+
+	return attachment, err
+}
+
+// Delete attachment of transaction from the wallet and return it, so its file can be deleted too. Return ErrAttachmentNotFound if there is no such attachment.
+func (s *attachmentStore) Delete(ctx context.Context, walletID, transactionID, attachmentID int) (models.Attachment, error) {
+	query := `DELETE FROM transaction_attachments a USING transactions t
+		WHERE a.id = $1 AND a.transaction_id = $2 AND t.id = a.transaction_id AND t.wallet_id = $3
+		RETURNING a.id, a.transaction_id, a.uploader_id, a.file_name, a.content_type, a.size_bytes, a.storage_key, a.created_at`
+
+	attachment, err := scanAttachment(s.db.QueryRowContext(ctx, query, attachmentID, transactionID, walletID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Attachment{}, ErrAttachmentNotFound
+	}
+
+	return attachment, err
+}
+
+// Return those of storage keys no attachment refers to
+func (s *attachmentStore) GetOrphanedKeys(ctx context.Context, keys []string) ([]string, error) {
+	orphaned := []string{}
+
+	query := "SELECT k FROM UNNEST($1::TEXT[]) k WHERE NOT EXISTS (SELECT 1 FROM transaction_attachments WHERE storage_key = k)"
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(keys))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+
+		orphaned = append(orphaned, key)
+	}
+
+	return orphaned, rows.Err()
+}
+
+// Return attachments selected by query with attachmentColumns
+func queryAttachments(ctx context.Context, db *sql.DB, query string, args ...any) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+func scanAttachment(row scanner) (models.Attachment, error) {
+	var attachment models.Attachment
+
+	err := row.Scan(&attachment.ID, &attachment.TransactionID, &attachment.UploaderID, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt)
+
+	return attachment, err
+}
@@ -1,21 +1,29 @@
 package store
 
 import (
-	"github.com/khralenok/all-wallets-api/internal/database"
+	"context"
+	"database/sql"
+
 	"github.com/khralenok/all-wallets-api/internal/models"
 )
 
-func GetAvailableCurrencies() ([]models.CurrencyMetadata, error) {
+type currencyStore struct {
+	db *sql.DB
+}
+
+func (s *currencyStore) GetAll(ctx context.Context) ([]models.CurrencyMetadata, error) {
 	var availableCurrencies []models.CurrencyMetadata
 
 	query := "SELECT * FROM currency_metadata"
 
-	rows, err := database.DB.Query(query)
+	rows, err := s.db.QueryContext(ctx, query)
 
 	if err != nil {
 		return []models.CurrencyMetadata{}, err
 	}
 
+	defer rows.Close()
+
 	for rows.Next() {
 		var nextCurrency models.CurrencyMetadata
 		err := rows.Scan(&nextCurrency.Code, &nextCurrency.Name, &nextCurrency.Type, &nextCurrency.DecimalPlaces, &nextCurrency.Symbol)
@@ -27,16 +35,35 @@ func GetAvailableCurrencies() ([]models.CurrencyMetadata, error) {
 		availableCurrencies = append(availableCurrencies, nextCurrency)
 	}
 
-	return availableCurrencies, nil
+	return availableCurrencies, rows.Err()
+}
+
+// Return decimal places for specific currency. Don't use it for definining wallet specific decimal places — instead use specialized function WalletRepository.GetDecimalPlaces.
+func (s *currencyStore) GetDecimalPlaces(ctx context.Context, currency string) (int, error) {
+	return currencyDecimalPlaces(ctx, s.db, currency)
+}
+
+// Return true if there is currency with such code in currency metadata.
+func (s *currencyStore) IsAvailable(ctx context.Context, currency string) (bool, error) {
+	var exists bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM currency_metadata WHERE code = $1)"
+
+	err := s.db.QueryRowContext(ctx, query, currency).Scan(&exists)
+
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
 }
 
-// Return decimal places for specific currency. Don't use it for definining wallet specific decimal places — instead use specialized function GetWalletDecimalPlaces.
-func GetCurrencyDecimalPlaces(currency string) (int, error) {
+func currencyDecimalPlaces(ctx context.Context, db *sql.DB, currency string) (int, error) {
 	var decimalPlaces int
 
 	query := "SELECT decimal_places FROM currency_metadata WHERE code = $1"
 
-	err := database.DB.QueryRow(query, currency).Scan(&decimalPlaces)
+	err := db.QueryRowContext(ctx, query, currency).Scan(&decimalPlaces)
 
 	if err != nil {
 		return -1, err
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of domain errors. Every error returned by store either wraps one of them or is unexpected database failure.
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrForbidden = errors.New("forbidden")
+	ErrConflict  = errors.New("conflict")
+)
+
+var (
+	ErrUserNotFound         = fmt.Errorf("user %w", ErrNotFound)
+	ErrWalletNotFound       = fmt.Errorf("wallet %w", ErrNotFound)
+	ErrNotWalletUser        = fmt.Errorf("not a wallet user: %w", ErrForbidden)
+	ErrNotWalletAdmin       = fmt.Errorf("not a wallet admin: %w", ErrForbidden)
+	ErrUsernameTaken        = fmt.Errorf("username taken: %w", ErrConflict)
+	ErrAlreadyWalletUser    = fmt.Errorf("already a wallet user: %w", ErrConflict)
+	ErrInvalidHandover      = fmt.Errorf("invalid wallet handover: %w", ErrConflict)
+	ErrTwoFactorEnabled     = fmt.Errorf("two factor already enabled: %w", ErrConflict)
+	ErrTwoFactorNotFound    = fmt.Errorf("two factor enrollment %w", ErrNotFound)
+	ErrAPIKeyNotFound       = fmt.Errorf("api key %w", ErrNotFound)
+	ErrWebhookNotFound      = fmt.Errorf("webhook %w", ErrNotFound)
+	ErrNotificationNotFound = fmt.Errorf("notification %w", ErrNotFound)
+	ErrTransactionNotFound  = fmt.Errorf("transaction %w", ErrNotFound)
+	ErrAttachmentNotFound   = fmt.Errorf("attachment %w", ErrNotFound)
+	ErrInsufficientFunds    = fmt.Errorf("insufficient funds: %w", ErrConflict)
+	ErrEventNotFound        = fmt.Errorf("wallet event %w", ErrNotFound)
+)
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Postgres channel wallet events are sent to. Every API instance listens to it and forwards events to its own streams.
+const WalletEventsChannel = "wallet_events"
+
+type eventStore struct {
+	db *sql.DB
+}
+
+// Record wallet event that isn't part of any change, e.g. derived balance update
+func (s *eventStore) Publish(ctx context.Context, event models.WalletEvent) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return recordEvent(ctx, tx, event)
+	})
+}
+
+// Return recorded wallet event by id of its notice
+func (s *eventStore) Get(ctx context.Context, eventID int64) (models.WalletEvent, error) {
+	var payload string
+	var event models.WalletEvent
+
+	err := s.db.QueryRowContext(ctx, "SELECT payload FROM wallet_events WHERE id = $1", eventID).Scan(&payload)
+
+	if err == sql.ErrNoRows {
+		return models.WalletEvent{}, ErrEventNotFound
+	} else if err != nil {
+		return models.WalletEvent{}, err
+	}
+
+	err = json.Unmarshal([]byte(payload), &event)
+
+	return event, err
+}
+
+// Delete recorded events older than retention. API instances load events right after they are notified, so they are kept only for a while.
+func (s *eventStore) Purge(ctx context.Context, retention time.Duration) (int, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM wallet_events WHERE created_at < $1", time.Now().Add(-retention))
+
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+
+	return int(purged), err
+}
+
+// Record wallet event in the DB transaction of the change it is about: queue delivery to every webhook of the wallet subscribed to it and notify API instances. Both take effect only if the change is committed. Notification carries only reference to the event, whatever its size.
+func recordEvent(ctx context.Context, tx *sql.Tx, event models.WalletEvent) error {
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO webhook_deliveries (webhook_id, event_type, payload) SELECT id, $2, $3 FROM webhooks WHERE wallet_id = $1 AND $2 = ANY(event_types)"
+
+	if _, err := tx.ExecContext(ctx, query, event.WalletID, event.Type, string(payload)); err != nil {
+		return err
+	}
+
+	notice := models.WalletEventNotice{Type: event.Type, WalletID: event.WalletID}
+
+	query = "INSERT INTO wallet_events (wallet_id, payload) VALUES ($1, $2) RETURNING id"
+
+	if err := tx.QueryRowContext(ctx, query, event.WalletID, string(payload)).Scan(&notice.ID); err != nil {
+		return err
+	}
+
+	noticePayload, err := json.Marshal(notice)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", WalletEventsChannel, string(noticePayload))
+
+	return err
+}
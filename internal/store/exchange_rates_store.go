@@ -1,19 +1,22 @@
 package store
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/khralenok/all-wallets-api/internal/database"
 	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/lib/pq"
 )
 
-func AddUpdatedExchangeRates(exchangeRates []models.ExchangeRate) error {
-	if err := removePreviousRates(); err != nil {
-		return err
-	}
+type exchangeRateStore struct {
+	db *sql.DB
+}
 
+// Replace all stored exchange rates with provided ones in one DB transaction, so readers never see empty table.
+func (s *exchangeRateStore) ReplaceAll(ctx context.Context, exchangeRates []models.ExchangeRate) error {
 	query := "INSERT INTO exchange_rates(from_currency, to_currency, rate, fetched_at) VALUES "
 	args := []any{}
 	valueStrings := []string{}
@@ -28,39 +31,107 @@ func AddUpdatedExchangeRates(exchangeRates []models.ExchangeRate) error {
 
 	query += strings.Join(valueStrings, ",")
 
-	_, err := database.DB.Exec(query, args...)
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// Clean up exchange_rates table before it's update
+		if _, err := tx.ExecContext(ctx, "DELETE FROM exchange_rates"); err != nil {
+			return err
+		}
+
+		if len(exchangeRates) == 0 {
+			return nil
+		}
+
+		_, err := tx.ExecContext(ctx, query, args...)
 
-	if err != nil {
 		return err
+	})
+}
+
+// Return rate for specified currencies pair
+func (s *exchangeRateStore) GetRate(ctx context.Context, from, to string) (float64, error) {
+	return exchangeRate(ctx, s.db, from, to)
+}
+
+// Return stored exchange rate together with time it was fetched. Return ErrNotFound if there is no such pair.
+func (s *exchangeRateStore) Get(ctx context.Context, from, to string) (models.ExchangeRate, error) {
+	exchangeRate := models.ExchangeRate{FromCurrency: from, ToCurrency: to}
+
+	query := "SELECT rate, fetched_at FROM exchange_rates WHERE from_currency = $1 AND to_currency = $2"
+
+	err := s.db.QueryRowContext(ctx, query, from, to).Scan(&exchangeRate.Rate, &exchangeRate.FetchedAt)
+
+	if err == sql.ErrNoRows {
+		return models.ExchangeRate{}, fmt.Errorf("exchange rate %s/%s %w", from, to, ErrNotFound)
+	} else if err != nil {
+		return models.ExchangeRate{}, err
 	}
 
-	return nil
+	return exchangeRate, nil
 }
 
-// Return rate for specified currencies pair
-func GetRate(from, to string, context *gin.Context) (float64, error) {
+// Return time of the oldest stored rate, so stale rates are noticed even if only part of them was updated. Return ErrNotFound if there are no rates yet.
+func (s *exchangeRateStore) GetLastFetchTime(ctx context.Context) (time.Time, error) {
+	var fetchedAt sql.NullTime
+
+	query := "SELECT MIN(fetched_at) FROM exchange_rates"
+
+	err := s.db.QueryRowContext(ctx, query).Scan(&fetchedAt)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !fetchedAt.Valid {
+		return time.Time{}, fmt.Errorf("exchange rates %w", ErrNotFound)
+	}
+
+	return fetchedAt.Time, nil
+}
+
+func exchangeRate(ctx context.Context, db *sql.DB, from, to string) (float64, error) {
 	var rate float64
 
 	query := "SELECT rate FROM exchange_rates WHERE from_currency = $1 AND to_currency = $2"
 
-	err := database.DB.QueryRow(query, from, to).Scan(&rate)
+	err := db.QueryRowContext(ctx, query, from, to).Scan(&rate)
 
-	if err != nil {
+	if err == sql.ErrNoRows {
+		return 0.0, fmt.Errorf("exchange rate %s/%s %w", from, to, ErrNotFound)
+	} else if err != nil {
 		return 0.0, err
 	}
 
 	return rate, nil
 }
 
-// Clean up exchange_rates table before it's update
-func removePreviousRates() error {
-	query := "DELETE FROM exchange_rates"
+// Return rates from each of provided currencies to target one in one query. Currencies without stored rate are missing from result.
+func exchangeRatesTo(ctx context.Context, db *sql.DB, from []string, to string) (map[string]float64, error) {
+	rates := make(map[string]float64, len(from))
 
-	_, err := database.DB.Exec(query)
+	if len(from) == 0 {
+		return rates, nil
+	}
+
+	query := "SELECT from_currency, rate FROM exchange_rates WHERE from_currency = ANY($1) AND to_currency = $2"
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(from), to)
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var currency string
+		var rate float64
+
+		if err := rows.Scan(&currency, &rate); err != nil {
+			return nil, err
+		}
+
+		rates[currency] = rate
 	}
 
-	return nil
+	return rates, rows.Err()
 }
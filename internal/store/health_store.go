@@ -0,0 +1,15 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type healthStore struct {
+	db *sql.DB
+}
+
+// Return nil if database is reachable
+func (s *healthStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// How long response is kept for idempotency key. Retry after that is served as a new request.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+type idempotencyStore struct {
+	db *sql.DB
+}
+
+// Claim idempotency key of user for request with provided hash. Return true if key is new or expired, so caller has to serve the request. Otherwise return what was saved for the key.
+func (s *idempotencyStore) Begin(ctx context.Context, userID int, key, requestHash string) (bool, models.IdempotentResponse, error) {
+	var response models.IdempotentResponse
+	var claimed bool
+
+	query := `INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL, response_body = NULL, created_at = NOW()
+		WHERE idempotency_keys.created_at < $4
+		RETURNING TRUE`
+
+	err := s.db.QueryRowContext(ctx, query, userID, key, requestHash, time.Now().Add(-IdempotencyKeyTTL)).Scan(&claimed)
+
+	if err == nil {
+		return claimed, response, nil
+	} else if err != sql.ErrNoRows {
+		return false, response, err
+	}
+
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+
+	query = "SELECT request_hash, status_code, content_type, response_body FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2"
+
+	err = s.db.QueryRowContext(ctx, query, userID, key).Scan(&response.RequestHash, &statusCode, &contentType, &response.Body)
+
+	// Key was released by failed request in between, the caller may retry
+	if err == sql.ErrNoRows {
+		return false, response, nil
+	} else if err != nil {
+		return false, response, err
+	}
+
+	response.StatusCode = int(statusCode.Int64)
+	response.ContentType = contentType.String
+
+	return false, response, nil
+}
+
+// Save response for claimed idempotency key
+func (s *idempotencyStore) Complete(ctx context.Context, userID int, key string, response models.IdempotentResponse) error {
+	query := "UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3 WHERE user_id = $4 AND idempotency_key = $5"
+
+	_, err := s.db.ExecContext(ctx, query, response.StatusCode, response.ContentType, response.Body, userID, key)
+
+	return err
+}
+
+// Forget claimed idempotency key, so the request can be retried with it
+func (s *idempotencyStore) Release(ctx context.Context, userID int, key string) error {
+	query := "DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND status_code IS NULL"
+
+	_, err := s.db.ExecContext(ctx, query, userID, key)
+
+	return err
+}
+
+// Delete idempotency keys older than IdempotencyKeyTTL. Return number of deleted keys.
+func (s *idempotencyStore) PurgeExpired(ctx context.Context) (int, error) {
+	query := "DELETE FROM idempotency_keys WHERE created_at < $1"
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().Add(-IdempotencyKeyTTL))
+
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	return int(deleted), err
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+)
+
+type loginFailureStore struct {
+	db *sql.DB
+}
+
+// Return time until which logins with this username are refused. Zero time means username isn't locked.
+func (s *loginFailureStore) LockedUntil(ctx context.Context, username string) (time.Time, error) {
+	var lockedUntil sql.NullTime
+
+	query := "SELECT locked_until FROM login_failures WHERE username = $1 AND locked_until > NOW()"
+
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&lockedUntil)
+
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	return lockedUntil.Time, nil
+}
+
+// Count failed login and lock username if lockout policy says so. Return time until which username is locked, zero time if it isn't.
+func (s *loginFailureStore) RecordFailure(ctx context.Context, username string, lockout ratelimit.Lockout) (time.Time, error) {
+	var lockedUntil time.Time
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var failures int
+
+		// Failures are counted from scratch if previous one was long ago
+		upsertQuery := `INSERT INTO login_failures (username, failures, last_failure_at) VALUES ($1, 1, NOW())
+			ON CONFLICT (username) DO UPDATE SET
+				failures = CASE WHEN login_failures.last_failure_at < $2 THEN 1 ELSE login_failures.failures + 1 END,
+				last_failure_at = NOW()
+			RETURNING failures`
+
+		if err := tx.QueryRowContext(ctx, upsertQuery, username, time.Now().Add(-lockout.ResetAfter)).Scan(&failures); err != nil {
+			return err
+		}
+
+		delay := lockout.Delay(failures)
+
+		if delay == 0 {
+			return nil
+		}
+
+		lockQuery := "UPDATE login_failures SET locked_until = NOW() + $1 * INTERVAL '1 second' WHERE username = $2 RETURNING locked_until"
+
+		return tx.QueryRowContext(ctx, lockQuery, delay.Seconds(), username).Scan(&lockedUntil)
+	})
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return lockedUntil, nil
+}
+
+// Forget failed logins after successful one
+func (s *loginFailureStore) Reset(ctx context.Context, username string) error {
+	query := "DELETE FROM login_failures WHERE username = $1"
+
+	_, err := s.db.ExecContext(ctx, query, username)
+
+	return err
+}
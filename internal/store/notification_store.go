@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/lib/pq"
+)
+
+// Only latest notifications are listed, older ones are still counted as unread
+const notificationsLimit = 50
+
+// Digest lists at most that many notifications, the rest is mentioned only by count of unread ones in app
+const digestLimit = 50
+
+const notificationColumns = "id, kind, wallet_id, message, data, read_at, created_at"
+
+type notificationStore struct {
+	db *sql.DB
+}
+
+// Notify user unless user muted this kind of notifications. Zero wallet id means notification isn't about any wallet.
+func (s *notificationStore) Create(ctx context.Context, userID int, kind string, walletID int, message string, data map[string]any) error {
+	encoded, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO notifications (user_id, kind, wallet_id, message, data)
+		SELECT $1::INT, $2::TEXT, NULLIF($3::INT, 0), $4::TEXT, $5::JSONB
+		WHERE NOT EXISTS (SELECT 1 FROM notification_preferences WHERE user_id = $1 AND $2 = ANY(muted_kinds))`
+
+	_, err = s.db.ExecContext(ctx, query, userID, kind, walletID, message, string(encoded))
+
+	return err
+}
+
+// Notify wallet users other than its creator about expense reaching their large expense threshold. Expense is converted to base currency of every user, users whose currency has no exchange rate aren't notified. Return number of notified users.
+func (s *notificationStore) NotifyLargeExpense(ctx context.Context, transaction models.Transaction, message string, data map[string]any) (int, error) {
+	encoded, err := json.Marshal(data)
+
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO notifications (user_id, kind, wallet_id, message, data)
+		SELECT wu.user_id, $3::TEXT, w.id, $4::TEXT, $5::JSONB
+		FROM wallet_users wu
+		JOIN wallets w ON w.id = wu.wallet_id
+		JOIN currency_metadata cm ON cm.code = w.currency
+		JOIN users u ON u.id = wu.user_id
+		JOIN notification_preferences p ON p.user_id = wu.user_id
+		LEFT JOIN exchange_rates r ON r.from_currency = w.currency AND r.to_currency = u.base_currency
+		WHERE wu.wallet_id = $1 AND wu.user_id <> $2 AND u.is_deleted = FALSE
+			AND p.large_expense_threshold IS NOT NULL AND NOT ($3 = ANY(p.muted_kinds))
+			AND $6::NUMERIC / POWER(10::NUMERIC, cm.decimal_places) * (CASE WHEN w.currency = u.base_currency THEN 1 ELSE r.rate END) >= p.large_expense_threshold`
+
+	result, err := s.db.ExecContext(ctx, query, transaction.WalletID, transaction.CreatorID, models.NotificationLargeExpense, message, string(encoded), transaction.Amount)
+
+	if err != nil {
+		return 0, err
+	}
+
+	notified, err := result.RowsAffected()
+
+	return int(notified), err
+}
+
+// Return latest notifications of the user, newest first, and number of unread ones
+func (s *notificationStore) GetByUser(ctx context.Context, userID int, unreadOnly bool) ([]models.Notification, int, error) {
+	var unread int
+
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL", userID).Scan(&unread); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + notificationColumns + " FROM notifications WHERE user_id = $1 AND ($2 = FALSE OR read_at IS NULL) ORDER BY id DESC LIMIT $3"
+
+	notifications, err := queryNotifications(ctx, s.db, query, userID, unreadOnly, notificationsLimit)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, unread, nil
+}
+
+// Mark notification of the user as read. Return ErrNotificationNotFound if user has no such notification.
+func (s *notificationStore) MarkRead(ctx context.Context, userID int, notificationID int64) error {
+	query := "UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP) WHERE id = $1 AND user_id = $2"
+
+	result, err := s.db.ExecContext(ctx, query, notificationID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotificationNotFound
+	}
+
+	return nil
+}
+
+// Mark all notifications of the user as read. Return number of notifications that were unread.
+func (s *notificationStore) MarkAllRead(ctx context.Context, userID int) (int, error) {
+	result, err := s.db.ExecContext(ctx, "UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL", userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	marked, err := result.RowsAffected()
+
+	return int(marked), err
+}
+
+// Return notification preferences of the user, defaults if user never saved them
+func (s *notificationStore) GetPreferences(ctx context.Context, userID int) (models.NotificationPreferences, error) {
+	var preferences models.NotificationPreferences
+	var email sql.NullString
+	var threshold sql.NullFloat64
+
+	query := "SELECT email, email_digest, muted_kinds, large_expense_threshold FROM notification_preferences WHERE user_id = $1"
+
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&email, &preferences.EmailDigest, pq.Array(&preferences.MutedKinds), &threshold)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotificationPreferences{MutedKinds: []string{}}, nil
+	} else if err != nil {
+		return models.NotificationPreferences{}, err
+	}
+
+	preferences.Email = email.String
+
+	if threshold.Valid {
+		preferences.LargeExpenseThreshold = &threshold.Float64
+	}
+
+	return preferences, nil
+}
+
+// Replace notification preferences of the user
+func (s *notificationStore) SavePreferences(ctx context.Context, userID int, preferences models.NotificationPreferences) error {
+	mutedKinds := preferences.MutedKinds
+
+	if mutedKinds == nil {
+		mutedKinds = []string{}
+	}
+
+	query := `INSERT INTO notification_preferences (user_id, email, email_digest, muted_kinds, large_expense_threshold) VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET email = EXCLUDED.email, email_digest = EXCLUDED.email_digest, muted_kinds = EXCLUDED.muted_kinds, large_expense_threshold = EXCLUDED.large_expense_threshold, updated_at = CURRENT_TIMESTAMP`
+
+	_, err := s.db.ExecContext(ctx, query, userID, preferences.Email, preferences.EmailDigest, pq.Array(mutedKinds), preferences.LargeExpenseThreshold)
+
+	return err
+}
+
+// Return digests of up to limit users with id greater than afterUserID who want them and have unread notifications that weren't emailed yet. Users are ordered by id, so all digests can be paged through.
+func (s *notificationStore) GetPendingDigests(ctx context.Context, afterUserID, limit int) ([]models.NotificationDigest, error) {
+	digests := []models.NotificationDigest{}
+
+	query := `SELECT p.user_id, p.email FROM notification_preferences p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.user_id > $1 AND p.email_digest = TRUE AND p.email IS NOT NULL AND u.is_deleted = FALSE
+			AND EXISTS (SELECT 1 FROM notifications n WHERE n.user_id = p.user_id AND n.read_at IS NULL AND n.emailed_at IS NULL)
+		ORDER BY p.user_id LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, query, afterUserID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var digest models.NotificationDigest
+
+		if err := rows.Scan(&digest.UserID, &digest.Email); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		digests = append(digests, digest)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = "SELECT " + notificationColumns + " FROM notifications WHERE user_id = $1 AND read_at IS NULL AND emailed_at IS NULL ORDER BY id LIMIT $2"
+
+	for i := range digests {
+		digests[i].Notifications, err = queryNotifications(ctx, s.db, query, digests[i].UserID, digestLimit)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return digests, nil
+}
+
+// Remember that notifications of the user up to lastID were emailed, so next digest doesn't repeat them
+func (s *notificationStore) MarkEmailed(ctx context.Context, userID int, lastID int64) error {
+	query := "UPDATE notifications SET emailed_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND id <= $2 AND emailed_at IS NULL"
+
+	_, err := s.db.ExecContext(ctx, query, userID, lastID)
+
+	return err
+}
+
+// Return notifications selected by query with notificationColumns
+func queryNotifications(ctx context.Context, db *sql.DB, query string, args ...any) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var notification models.Notification
+		var walletID sql.NullInt64
+		var data []byte
+		var readAt sql.NullTime
+
+		if err := rows.Scan(&notification.ID, &notification.Kind, &walletID, &notification.Message, &data, &readAt, &notification.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &notification.Data); err != nil {
+			return nil, err
+		}
+
+		if walletID.Valid {
+			id := int(walletID.Int64)
+			notification.WalletID = &id
+		}
+
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
+}
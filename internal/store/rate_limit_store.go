@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+)
+
+type rateLimitStore struct {
+	db *sql.DB
+}
+
+// Take one token from bucket shared by all API instances. Bucket row is locked for the time of calculation, so concurrent requests can't take the same token. Database clock is used, so instances with skewed clocks agree.
+func (s *rateLimitStore) Take(ctx context.Context, key string, rate ratelimit.Rate) (time.Duration, error) {
+	var wait time.Duration
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		insertQuery := "INSERT INTO rate_limit_buckets (bucket_key, tokens, updated_at) VALUES ($1, $2, NOW()) ON CONFLICT (bucket_key) DO NOTHING"
+
+		if _, err := tx.ExecContext(ctx, insertQuery, key, rate.Burst); err != nil {
+			return err
+		}
+
+		var tokens, elapsedSeconds float64
+
+		selectQuery := "SELECT tokens, EXTRACT(EPOCH FROM NOW() - updated_at) FROM rate_limit_buckets WHERE bucket_key = $1 FOR UPDATE"
+
+		if err := tx.QueryRowContext(ctx, selectQuery, key).Scan(&tokens, &elapsedSeconds); err != nil {
+			return err
+		}
+
+		tokens, wait = ratelimit.Refill(tokens, time.Duration(elapsedSeconds*float64(time.Second)), rate)
+
+		updateQuery := "UPDATE rate_limit_buckets SET tokens = $1, updated_at = NOW() WHERE bucket_key = $2"
+
+		_, err := tx.ExecContext(ctx, updateQuery, tokens, key)
+
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return wait, nil
+}
+
+// Delete buckets untouched for longer than maxAge. Return number of deleted buckets.
+func (s *rateLimitStore) PurgeStale(ctx context.Context, maxAge time.Duration) (int, error) {
+	query := "DELETE FROM rate_limit_buckets WHERE updated_at < $1"
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().Add(-maxAge))
+
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	return int(deleted), err
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, username, passwordHash, baseCurrency string) error
+	GetByID(ctx context.Context, userID int) (models.User, error)
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	GetIDByUsername(ctx context.Context, username string) (int, error)
+	CheckUsernameUnique(ctx context.Context, username string) error
+	UpdatePassword(ctx context.Context, userID int, passwordHash string) (int, error)
+	RehashPassword(ctx context.Context, userID int, oldHash, newHash string) error
+	UpdateUsername(ctx context.Context, userID int, username string) error
+	UpdateBaseCurrency(ctx context.Context, userID int, baseCurrency string) error
+	GetTokenVersion(ctx context.Context, userID int) (int, error)
+	ScheduleDeletion(ctx context.Context, userID int, handovers []models.WalletHandover) (time.Time, error)
+	GetDeletedByUsername(ctx context.Context, username string) (models.User, error)
+	Restore(ctx context.Context, userID int) (int, error)
+	PurgeDeleted(ctx context.Context, gracePeriod time.Duration) (int, error)
+}
+
+type WalletRepository interface {
+	Create(ctx context.Context, input models.NewWalletRequest) (models.Wallet, error)
+	Archive(ctx context.Context, walletID int) (models.Wallet, error)
+	Restore(ctx context.Context, walletID int) error
+	HardDelete(ctx context.Context, walletID int) error
+	PurgeArchived(ctx context.Context, retention time.Duration) (int, error)
+	EnsureActive(ctx context.Context, walletID int) error
+	GetByID(ctx context.Context, walletID int) (models.Wallet, error)
+	GetByUser(ctx context.Context, user models.User) ([]models.WalletOutput, error)
+	GetDashboard(ctx context.Context, user models.User, monthStart time.Time) ([]models.DashboardWallet, error)
+	GetDecimalPlaces(ctx context.Context, walletID int) (int, error)
+	TakeSnapshot(ctx context.Context, walletID int) (int, error)
+	GetBalance(ctx context.Context, walletID int) (int, error)
+	RequiresTwoFactor(ctx context.Context, walletID int) (bool, error)
+	SetRequireTwoFactor(ctx context.Context, walletID int, required bool) error
+}
+
+type WalletUserRepository interface {
+	Add(ctx context.Context, walletID, userID int, userRole string) (models.WalletUser, error)
+	CheckUnique(ctx context.Context, userID, walletID int) error
+	CheckMember(ctx context.Context, userID, walletID int) error
+	CheckAdmin(ctx context.Context, userID, walletID int) error
+	IsLastAdmin(ctx context.Context, userID, walletID int) (bool, error)
+	Remove(ctx context.Context, walletID, userID int) error
+	GetSolelyAdministeredWallets(ctx context.Context, userID int) ([]int, error)
+}
+
+type TransactionRepository interface {
+	Add(ctx context.Context, transaction models.Transaction) (models.Transaction, error)
+	Update(ctx context.Context, transaction models.Transaction) (models.Transaction, error)
+	GetLatest(ctx context.Context, walletID int) ([]models.Transaction, error)
+	GetByWallet(ctx context.Context, walletID int, filter models.TransactionFilter) ([]models.Transaction, error)
+	Search(ctx context.Context, userID int, query models.SearchQuery) ([]models.SearchHit, error)
+	GetCategoryTotals(ctx context.Context, walletID int, from time.Time, until *time.Time) ([]models.CategoryTotal, error)
+}
+
+type AttachmentRepository interface {
+	Create(ctx context.Context, walletID int, attachment models.Attachment) (models.Attachment, error)
+	Get(ctx context.Context, walletID, transactionID, attachmentID int) (models.Attachment, error)
+	Delete(ctx context.Context, walletID, transactionID, attachmentID int) (models.Attachment, error)
+	GetOrphanedKeys(ctx context.Context, keys []string) ([]string, error)
+}
+
+type ExchangeRateRepository interface {
+	ReplaceAll(ctx context.Context, exchangeRates []models.ExchangeRate) error
+	GetRate(ctx context.Context, from, to string) (float64, error)
+	Get(ctx context.Context, from, to string) (models.ExchangeRate, error)
+	GetLastFetchTime(ctx context.Context) (time.Time, error)
+}
+
+type TwoFactorRepository interface {
+	SavePending(ctx context.Context, userID int, secret string) error
+	Get(ctx context.Context, userID int) (models.TwoFactor, error)
+	IsEnabled(ctx context.Context, userID int) (bool, error)
+	Enable(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (int, error)
+	UseStep(ctx context.Context, userID int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error
+	Disable(ctx context.Context, userID int) (int, error)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error)
+	GetByUser(ctx context.Context, userID int) ([]models.APIKey, error)
+	Revoke(ctx context.Context, userID, keyID int) error
+	GetActive(ctx context.Context, keyID string) (models.APIKey, string, error)
+	Touch(ctx context.Context, keyID int) error
+}
+
+type IdempotencyRepository interface {
+	Begin(ctx context.Context, userID int, key, requestHash string) (bool, models.IdempotentResponse, error)
+	Complete(ctx context.Context, userID int, key string, response models.IdempotentResponse) error
+	Release(ctx context.Context, userID int, key string) error
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+type EventRepository interface {
+	Publish(ctx context.Context, event models.WalletEvent) error
+	Get(ctx context.Context, eventID int64) (models.WalletEvent, error)
+	Purge(ctx context.Context, retention time.Duration) (int, error)
+}
+
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook models.Webhook, secret string) (models.Webhook, error)
+	GetByWallet(ctx context.Context, walletID int) ([]models.Webhook, error)
+	Delete(ctx context.Context, walletID, webhookID int) error
+	GetDeliveries(ctx context.Context, walletID, webhookID int) ([]models.WebhookDelivery, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.PendingDelivery, error)
+	MarkDelivered(ctx context.Context, deliveryID int64, statusCode int) error
+	MarkFailed(ctx context.Context, deliveryID int64, statusCode int, lastError string, retryAt time.Time) error
+	PurgeFinished(ctx context.Context, retention time.Duration) (int, error)
+}
+
+type NotificationRepository interface {
+	Create(ctx context.Context, userID int, kind string, walletID int, message string, data map[string]any) error
+	NotifyLargeExpense(ctx context.Context, transaction models.Transaction, message string, data map[string]any) (int, error)
+	GetByUser(ctx context.Context, userID int, unreadOnly bool) ([]models.Notification, int, error)
+	MarkRead(ctx context.Context, userID int, notificationID int64) error
+	MarkAllRead(ctx context.Context, userID int) (int, error)
+	GetPreferences(ctx context.Context, userID int) (models.NotificationPreferences, error)
+	SavePreferences(ctx context.Context, userID int, preferences models.NotificationPreferences) error
+	GetPendingDigests(ctx context.Context, afterUserID, limit int) ([]models.NotificationDigest, error)
+	MarkEmailed(ctx context.Context, userID int, lastID int64) error
+}
+
+type RateLimitRepository interface {
+	Take(ctx context.Context, key string, rate ratelimit.Rate) (time.Duration, error)
+	PurgeStale(ctx context.Context, maxAge time.Duration) (int, error)
+}
+
+type LoginFailureRepository interface {
+	LockedUntil(ctx context.Context, username string) (time.Time, error)
+	RecordFailure(ctx context.Context, username string, lockout ratelimit.Lockout) (time.Time, error)
+	Reset(ctx context.Context, username string) error
+}
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+}
+
+type CurrencyRepository interface {
+	GetAll(ctx context.Context) ([]models.CurrencyMetadata, error)
+	GetDecimalPlaces(ctx context.Context, currency string) (int, error)
+	IsAvailable(ctx context.Context, currency string) (bool, error)
+}
+
+// Group of all repositories. Handlers and worker commands depend on it, so any repository can be replaced with a mock.
+type Store struct {
+	Users         UserRepository
+	Wallets       WalletRepository
+	WalletUsers   WalletUserRepository
+	Transactions  TransactionRepository
+	Attachments   AttachmentRepository
+	ExchangeRates ExchangeRateRepository
+	Currencies    CurrencyRepository
+	Health        HealthRepository
+	RateLimits    RateLimitRepository
+	LoginFailures LoginFailureRepository
+	TwoFactor     TwoFactorRepository
+	APIKeys       APIKeyRepository
+	Idempotency   IdempotencyRepository
+	Events        EventRepository
+	Webhooks      WebhookRepository
+	Notifications NotificationRepository
+}
+
+// Constructor function for store backed by Postgres database
+func New(db *sql.DB) *Store {
+	return &Store{
+		Users:         &userStore{db: db},
+		Wallets:       &walletStore{db: db},
+		WalletUsers:   &walletUserStore{db: db},
+		Transactions:  &transactionStore{db: db},
+		Attachments:   &attachmentStore{db: db},
+		ExchangeRates: &exchangeRateStore{db: db},
+		Currencies:    &currencyStore{db: db},
+		Health:        &healthStore{db: db},
+		RateLimits:    &rateLimitStore{db: db},
+		LoginFailures: &loginFailureStore{db: db},
+		TwoFactor:     &twoFactorStore{db: db},
+		APIKeys:       &apiKeyStore{db: db},
+		Idempotency:   &idempotencyStore{db: db},
+		Events:        &eventStore{db: db},
+		Webhooks:      &webhookStore{db: db},
+		Notifications: &notificationStore{db: db},
+	}
+}
+
+// Run fn in DB transaction. Transaction is committed if fn returns nil and rolled back otherwise.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
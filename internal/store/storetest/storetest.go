@@ -0,0 +1,517 @@
+// Package storetest is in-memory store for tests of handlers and API clients. It keeps users, wallets, wallet users, transactions, login failures and idempotency keys the way Postgres store does, so requests can go through real routes without database.
+//
+// Repositories embed their interface, so methods tests don't need panic if called. Attachments, exchange rates, API keys, webhooks and other repositories tests don't need are nil.
+package storetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+	"github.com/khralenok/all-wallets-api/internal/store"
+)
+
+// Currencies available in test store with their decimal places
+var Currencies = map[string]int{"USD": 2, "EUR": 2, "JPY": 0}
+
+// Data shared by all repositories of one store
+type memory struct {
+	mu              sync.Mutex
+	users           []models.User
+	wallets         []models.Wallet
+	walletUsers     []models.WalletUser
+	transactions    []models.Transaction
+	loginFailures   map[string]loginFailure
+	idempotencyKeys map[idempotencyKey]models.IdempotentResponse
+}
+
+type loginFailure struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+type idempotencyKey struct {
+	userID int
+	key    string
+}
+
+// Constructor function for empty in-memory store
+func New() *store.Store {
+	m := &memory{loginFailures: make(map[string]loginFailure), idempotencyKeys: make(map[idempotencyKey]models.IdempotentResponse)}
+
+	return &store.Store{
+		Users:         &userStore{m: m},
+		Wallets:       &walletStore{m: m},
+		WalletUsers:   &walletUserStore{m: m},
+		Transactions:  &transactionStore{m: m},
+		Currencies:    &currencyStore{},
+		LoginFailures: &loginFailureStore{m: m},
+		TwoFactor:     &twoFactorStore{},
+		Idempotency:   &idempotencyStore{m: m},
+		Events:        &eventStore{},
+		Notifications: &notificationStore{},
+	}
+}
+
+type userStore struct {
+	store.UserRepository
+	m *memory
+}
+
+func (s *userStore) Create(ctx context.Context, username, passwordHash, baseCurrency string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.user(username); ok {
+		return store.ErrUsernameTaken
+	}
+
+	user := models.User{ID: len(s.m.users) + 1, Username: username, Password: passwordHash, BaseCurrency: baseCurrency, CreatedAt: time.Now()}
+	s.m.users = append(s.m.users, user)
+
+	return nil
+}
+
+func (s *userStore) GetByID(ctx context.Context, userID int) (models.User, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, user := range s.m.users {
+		if user.ID == userID && !user.IsDeleted {
+			return user, nil
+		}
+	}
+
+	return models.User{}, store.ErrUserNotFound
+}
+
+func (s *userStore) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if user, ok := s.m.user(username); ok {
+		return user, nil
+	}
+
+	return models.User{}, store.ErrUserNotFound
+}
+
+func (s *userStore) GetIDByUsername(ctx context.Context, username string) (int, error) {
+	user, err := s.GetByUsername(ctx, username)
+
+	return user.ID, err
+}
+
+func (s *userStore) CheckUsernameUnique(ctx context.Context, username string) error {
+	if _, err := s.GetByUsername(ctx, username); err == nil {
+		return store.ErrUsernameTaken
+	}
+
+	return nil
+}
+
+func (s *userStore) RehashPassword(ctx context.Context, userID int, oldHash, newHash string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for i, user := range s.m.users {
+		if user.ID == userID && user.Password == oldHash {
+			s.m.users[i].Password = newHash
+		}
+	}
+
+	return nil
+}
+
+func (s *userStore) GetTokenVersion(ctx context.Context, userID int) (int, error) {
+	user, err := s.GetByID(ctx, userID)
+
+	return user.TokenVersion, err
+}
+
+type walletStore struct {
+	store.WalletRepository
+	m *memory
+}
+
+func (s *walletStore) Create(ctx context.Context, input models.NewWalletRequest) (models.Wallet, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+	wallet := models.Wallet{ID: len(s.m.wallets) + 1, WalletName: input.WalletName, Currency: input.Currency, LastSnapshot: now, CreatedAt: now}
+	s.m.wallets = append(s.m.wallets, wallet)
+
+	return wallet, nil
+}
+
+func (s *walletStore) EnsureActive(ctx context.Context, walletID int) error {
+	_, err := s.GetByID(ctx, walletID)
+
+	return err
+}
+
+func (s *walletStore) GetByID(ctx context.Context, walletID int) (models.Wallet, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, wallet := range s.m.wallets {
+		if wallet.ID == walletID && !wallet.IsArchived {
+			return wallet, nil
+		}
+	}
+
+	return models.Wallet{}, store.ErrWalletNotFound
+}
+
+func (s *walletStore) GetDecimalPlaces(ctx context.Context, walletID int) (int, error) {
+	wallet, err := s.GetByID(ctx, walletID)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return Currencies[wallet.Currency], nil
+}
+
+func (s *walletStore) GetBalance(ctx context.Context, walletID int) (int, error) {
+	if _, err := s.GetByID(ctx, walletID); err != nil {
+		return 0, err
+	}
+
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.m.balance(walletID), nil
+}
+
+func (s *walletStore) RequiresTwoFactor(ctx context.Context, walletID int) (bool, error) {
+	wallet, err := s.GetByID(ctx, walletID)
+
+	return wallet.RequireTwoFactor, err
+}
+
+type walletUserStore struct {
+	store.WalletUserRepository
+	m *memory
+}
+
+func (s *walletUserStore) Add(ctx context.Context, walletID, userID int, userRole string) (models.WalletUser, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	walletUser := models.WalletUser{WalletID: walletID, UserID: userID, UserRole: userRole, CreatedAt: time.Now()}
+	s.m.walletUsers = append(s.m.walletUsers, walletUser)
+
+	return walletUser, nil
+}
+
+func (s *walletUserStore) CheckUnique(ctx context.Context, userID, walletID int) error {
+	if _, err := s.role(userID, walletID); err == nil {
+		return store.ErrAlreadyWalletUser
+	}
+
+	return nil
+}
+
+func (s *walletUserStore) CheckMember(ctx context.Context, userID, walletID int) error {
+	_, err := s.role(userID, walletID)
+
+	return err
+}
+
+func (s *walletUserStore) CheckAdmin(ctx context.Context, userID, walletID int) error {
+	role, err := s.role(userID, walletID)
+
+	if err != nil {
+		return err
+	}
+
+	if role != "admin" {
+		return store.ErrNotWalletAdmin
+	}
+
+	return nil
+}
+
+func (s *walletUserStore) IsLastAdmin(ctx context.Context, userID, walletID int) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	isAdmin := false
+
+	for _, walletUser := range s.m.walletUsers {
+		if walletUser.WalletID != walletID || walletUser.UserRole != "admin" {
+			continue
+		}
+
+		if walletUser.UserID == userID {
+			isAdmin = true
+		} else if !s.m.isDeleted(walletUser.UserID) {
+			return false, nil
+		}
+	}
+
+	return isAdmin, nil
+}
+
+func (s *walletUserStore) Remove(ctx context.Context, walletID, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for i, walletUser := range s.m.walletUsers {
+		if walletUser.WalletID == walletID && walletUser.UserID == userID {
+			s.m.walletUsers = append(s.m.walletUsers[:i], s.m.walletUsers[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *walletUserStore) role(userID, walletID int) (string, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, walletUser := range s.m.walletUsers {
+		if walletUser.WalletID == walletID && walletUser.UserID == userID {
+			return walletUser.UserRole, nil
+		}
+	}
+
+	return "", store.ErrNotWalletUser
+}
+
+type transactionStore struct {
+	store.TransactionRepository
+	m *memory
+}
+
+func (s *transactionStore) Add(ctx context.Context, transaction models.Transaction) (models.Transaction, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if !transaction.IsDeposit && transaction.Amount > s.m.balance(transaction.WalletID) {
+		return models.Transaction{}, store.ErrInsufficientFunds
+	}
+
+	transaction.ID = len(s.m.transactions) + 1
+	transaction.CreatedAt = time.Now()
+
+	if transaction.OccurredAt.IsZero() {
+		transaction.OccurredAt = transaction.CreatedAt
+	}
+
+	s.m.transactions = append(s.m.transactions, transaction)
+
+	return transaction, nil
+}
+
+func (s *transactionStore) GetLatest(ctx context.Context, walletID int) ([]models.Transaction, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.m.walletTransactions(walletID), nil
+}
+
+// Filter is ignored, all wallet transactions are returned
+func (s *transactionStore) GetByWallet(ctx context.Context, walletID int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	return s.GetLatest(ctx, walletID)
+}
+
+type currencyStore struct {
+	store.CurrencyRepository
+}
+
+func (s *currencyStore) GetDecimalPlaces(ctx context.Context, currency string) (int, error) {
+	decimalPlaces, ok := Currencies[currency]
+
+	if !ok {
+		return -1, store.ErrNotFound
+	}
+
+	return decimalPlaces, nil
+}
+
+func (s *currencyStore) IsAvailable(ctx context.Context, currency string) (bool, error) {
+	_, ok := Currencies[currency]
+
+	return ok, nil
+}
+
+type loginFailureStore struct {
+	store.LoginFailureRepository
+	m *memory
+}
+
+func (s *loginFailureStore) LockedUntil(ctx context.Context, username string) (time.Time, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.m.loginFailures[username].lockedUntil, nil
+}
+
+func (s *loginFailureStore) RecordFailure(ctx context.Context, username string, lockout ratelimit.Lockout) (time.Time, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+	failure := s.m.loginFailures[username]
+
+	if now.Sub(failure.lastFailureAt) > lockout.ResetAfter {
+		failure.failures = 0
+	}
+
+	failure.failures++
+	failure.lastFailureAt = now
+
+	if delay := lockout.Delay(failure.failures); delay > 0 {
+		failure.lockedUntil = now.Add(delay)
+	}
+
+	s.m.loginFailures[username] = failure
+
+	if failure.lockedUntil.After(now) {
+		return failure.lockedUntil, nil
+	}
+
+	return time.Time{}, nil
+}
+
+func (s *loginFailureStore) Reset(ctx context.Context, username string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	delete(s.m.loginFailures, username)
+
+	return nil
+}
+
+// Second factor is never enabled
+type twoFactorStore struct {
+	store.TwoFactorRepository
+}
+
+func (s *twoFactorStore) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	return false, nil
+}
+
+// Keys never expire
+type idempotencyStore struct {
+	store.IdempotencyRepository
+	m *memory
+}
+
+func (s *idempotencyStore) Begin(ctx context.Context, userID int, key, requestHash string) (bool, models.IdempotentResponse, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	id := idempotencyKey{userID: userID, key: key}
+
+	if saved, ok := s.m.idempotencyKeys[id]; ok {
+		return false, saved, nil
+	}
+
+	s.m.idempotencyKeys[id] = models.IdempotentResponse{RequestHash: requestHash}
+
+	return true, models.IdempotentResponse{}, nil
+}
+
+func (s *idempotencyStore) Complete(ctx context.Context, userID int, key string, response models.IdempotentResponse) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	id := idempotencyKey{userID: userID, key: key}
+	response.RequestHash = s.m.idempotencyKeys[id].RequestHash
+	s.m.idempotencyKeys[id] = response
+
+	return nil
+}
+
+func (s *idempotencyStore) Release(ctx context.Context, userID int, key string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	id := idempotencyKey{userID: userID, key: key}
+
+	if s.m.idempotencyKeys[id].StatusCode == 0 {
+		delete(s.m.idempotencyKeys, id)
+	}
+
+	return nil
+}
+
+// Events aren't delivered anywhere
+type eventStore struct {
+	store.EventRepository
+}
+
+func (s *eventStore) Publish(ctx context.Context, event models.WalletEvent) error {
+	return nil
+}
+
+// Notifications aren't kept
+type notificationStore struct {
+	store.NotificationRepository
+}
+
+func (s *notificationStore) Create(ctx context.Context, userID int, kind string, walletID int, message string, data map[string]any) error {
+	return nil
+}
+
+func (s *notificationStore) NotifyLargeExpense(ctx context.Context, transaction models.Transaction, message string, data map[string]any) (int, error) {
+	return 0, nil
+}
+
+// Return active user with such username. Caller must hold the lock.
+func (m *memory) user(username string) (models.User, bool) {
+	for _, user := range m.users {
+		if user.Username == username && !user.IsDeleted {
+			return user, true
+		}
+	}
+
+	return models.User{}, false
+}
+
+// Return true if there is no active user with such id. Caller must hold the lock.
+func (m *memory) isDeleted(userID int) bool {
+	for _, user := range m.users {
+		if user.ID == userID {
+			return user.IsDeleted
+		}
+	}
+
+	return true
+}
+
+// Return transactions of the wallet in order they were added. Caller must hold the lock.
+func (m *memory) walletTransactions(walletID int) []models.Transaction {
+	transactions := []models.Transaction{}
+
+	for _, transaction := range m.transactions {
+		if transaction.WalletID == walletID {
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	return transactions
+}
+
+// Snapshot is never taken, so balance is sum of all wallet transactions. Caller must hold the lock.
+func (m *memory) balance(walletID int) int {
+	balance := 0
+
+	for _, transaction := range m.walletTransactions(walletID) {
+		if transaction.IsDeposit {
+			balance += transaction.Amount
+		} else {
+			balance -= transaction.Amount
+		}
+	}
+
+	return balance
+}
@@ -1,78 +1,402 @@
 package store
 
 import (
-	"net/http"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/khralenok/all-wallets-api/internal/database"
+	"github.com/khralenok/all-wallets-api/internal/logic"
 	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/lib/pq"
 )
 
-// Add new transaction to DB. Return transaction object
-func AddTransaction(amount, walletID int, isDeposit bool, category string, context *gin.Context) (models.Transaction, error) {
-	userID := context.MustGet("userID").(int)
+// Operators models.AmountCondition may have. They are put into SQL as is, so anything else is refused.
+var amountOperators = map[string]bool{
+	models.AmountGreater:        true,
+	models.AmountGreaterOrEqual: true,
+	models.AmountLess:           true,
+	models.AmountLessOrEqual:    true,
+	models.AmountEqual:          true,
+}
+
+type transactionStore struct {
+	db *sql.DB
+}
+
+// Columns of transactions table in order scanTransaction expects
+const transactionColumns = "id, amount, is_deposit, category, description, payee, wallet_id, creator_id, created_at, occurred_at"
+
+// Add new transaction with its tags and splits to DB and record transaction.created event. Tags must be normalized. Zero OccurredAt means transaction happened now. Return transaction object or ErrInsufficientFunds if expense is larger than wallet balance.
+func (s *transactionStore) Add(ctx context.Context, transaction models.Transaction) (models.Transaction, error) {
 	var newTransaction models.Transaction
 
-	query := "INSERT INTO transactions (amount, is_deposit, category, wallet_id, creator_id, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *"
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var decimalPlaces int
+
+		// Shared lock keeps snapshot from being taken until transaction is committed, so it's never stamped before snapshot time but left out of it. Expense takes exclusive lock instead, so concurrent expenses are checked against balance one by one and can't overdraw it together.
+		lock := "FOR SHARE OF w"
+
+		if !transaction.IsDeposit {
+			lock = "FOR UPDATE OF w"
+		}
+
+		query := "SELECT cm.decimal_places FROM wallets w JOIN currency_metadata cm ON cm.code = w.currency WHERE w.id = $1 " + lock
+
+		err := tx.QueryRowContext(ctx, query, transaction.WalletID).Scan(&decimalPlaces)
+
+		if err == sql.ErrNoRows {
+			return ErrWalletNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if !transaction.IsDeposit {
+			var balance int
+
+			if err := tx.QueryRowContext(ctx, currentBalanceQuery, transaction.WalletID).Scan(&balance); err != nil {
+				return err
+			}
+
+			if transaction.Amount > balance {
+				return ErrInsufficientFunds
+			}
+		}
+
+		createdAt := time.Now()
+		occurredAt := transaction.OccurredAt
+
+		if occurredAt.IsZero() {
+			occurredAt = createdAt
+		}
+
+		query = "INSERT INTO transactions (amount, is_deposit, category, description, payee, wallet_id, creator_id, created_at, occurred_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING " + transactionColumns
+
+		row := tx.QueryRowContext(ctx, query, transaction.Amount, transaction.IsDeposit, transaction.Category, transaction.Description, transaction.Payee, transaction.WalletID, transaction.CreatorID, createdAt, occurredAt)
+
+		newTransaction, err = scanTransaction(row)
+
+		if err != nil {
+			return err
+		}
+
+		if err := saveTags(ctx, tx, newTransaction.WalletID, newTransaction.ID, transaction.Tags); err != nil {
+			return err
+		}
+
+		if err := saveSplits(ctx, tx, newTransaction.ID, transaction.Splits); err != nil {
+			return err
+		}
+
+		if err := refreshSearchVector(ctx, tx, newTransaction.ID); err != nil {
+			return err
+		}
+
+		newTransaction.Tags = append([]string{}, transaction.Tags...)
+		newTransaction.Splits = append([]models.TransactionSplit{}, transaction.Splits...)
+		newTransaction.Attachments = []models.Attachment{}
 
-	err := database.DB.QueryRow(query, amount, isDeposit, category, walletID, userID, time.Now()).Scan(&newTransaction.ID, &newTransaction.Amount, &newTransaction.IsDeposit, &newTransaction.Category, &newTransaction.WalletID, &newTransaction.CreatorID, &newTransaction.CreatedAt)
+		return recordEvent(ctx, tx, models.WalletEvent{Type: models.EventTransactionCreated, WalletID: newTransaction.WalletID, Data: transactionEventData(newTransaction, decimalPlaces)})
+	})
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "raw_error": err.Error(), "message": "Failed to insert new transaction data into the database"})
 		return models.Transaction{}, err
 	}
 
 	return newTransaction, nil
 }
 
-// Return array of all transactions from lastest snapshot
-func GetLatestTransactions(walletID int) ([]models.Transaction, error) {
-	var latestTransactions []models.Transaction
+// Replace amount, type, category, description, payee, tags, splits and occurred time of wallet transaction and record transaction.updated event. If transaction is already summed up into wallet snapshot, snapshot balance is corrected by the difference. Return ErrTransactionNotFound if wallet has no such transaction and ErrInsufficientFunds if change would make balance negative.
+func (s *transactionStore) Update(ctx context.Context, transaction models.Transaction) (models.Transaction, error) {
+	var updated models.Transaction
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var balance, decimalPlaces int
+		var lastSnapshot time.Time
+
+		// Exclusive lock keeps snapshot and other balance changes away until correction is committed
+		query := "SELECT w.balance, w.last_snapshot, cm.decimal_places FROM wallets w JOIN currency_metadata cm ON cm.code = w.currency WHERE w.id = $1 FOR UPDATE OF w"
+
+		err := tx.QueryRowContext(ctx, query, transaction.WalletID).Scan(&balance, &lastSnapshot, &decimalPlaces)
+
+		if err == sql.ErrNoRows {
+			return ErrWalletNotFound
+		} else if err != nil {
+			return err
+		}
+
+		query = "SELECT " + transactionColumns + " FROM transactions WHERE id = $1 AND wallet_id = $2"
+
+		previous, err := scanTransaction(tx.QueryRowContext(ctx, query, transaction.ID, transaction.WalletID))
+
+		if err == sql.ErrNoRows {
+			return ErrTransactionNotFound
+		} else if err != nil {
+			return err
+		}
+
+		var latestSum int
+
+		query = "SELECT COALESCE(SUM(CASE WHEN is_deposit THEN amount ELSE -amount END), 0) FROM transactions WHERE wallet_id = $1 AND created_at > $2"
+
+		if err := tx.QueryRowContext(ctx, query, transaction.WalletID, lastSnapshot).Scan(&latestSum); err != nil {
+			return err
+		}
+
+		delta := signedAmount(transaction) - signedAmount(previous)
+
+		if delta < 0 && balance+latestSum+delta < 0 {
+			return ErrInsufficientFunds
+		}
+
+		occurredAt := transaction.OccurredAt
+
+		if occurredAt.IsZero() {
+			occurredAt = previous.OccurredAt
+		}
+
+		query = "UPDATE transactions SET amount = $1, is_deposit = $2, category = $3, description = $4, payee = $5, occurred_at = $6 WHERE id = $7 RETURNING " + transactionColumns
+
+		row := tx.QueryRowContext(ctx, query, transaction.Amount, transaction.IsDeposit, transaction.Category, transaction.Description, transaction.Payee, occurredAt, transaction.ID)
 
-	wallet, err := GetWalletByID(walletID)
+		updated, err = scanTransaction(row)
+
+		if err != nil {
+			return err
+		}
+
+		if delta != 0 && !previous.CreatedAt.After(lastSnapshot) {
+			query = "UPDATE wallets SET balance = balance + $1 WHERE id = $2"
+
+			if _, err := tx.ExecContext(ctx, query, delta, transaction.WalletID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM transaction_tags WHERE transaction_id = $1", transaction.ID); err != nil {
+			return err
+		}
+
+		if err := saveTags(ctx, tx, updated.WalletID, updated.ID, transaction.Tags); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM transaction_splits WHERE transaction_id = $1", transaction.ID); err != nil {
+			return err
+		}
+
+		if err := saveSplits(ctx, tx, updated.ID, transaction.Splits); err != nil {
+			return err
+		}
+
+		if err := refreshSearchVector(ctx, tx, updated.ID); err != nil {
+			return err
+		}
+
+		updated.Tags = append([]string{}, transaction.Tags...)
+		updated.Splits = append([]models.TransactionSplit{}, transaction.Splits...)
+
+		return recordEvent(ctx, tx, models.WalletEvent{Type: models.EventTransactionUpdated, WalletID: updated.WalletID, Data: transactionEventData(updated, decimalPlaces)})
+	})
+
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	transactions := []models.Transaction{updated}
+
+	if err := loadTransactionDetails(ctx, s.db, transactions); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return transactions[0], nil
+}
+
+// Return array of all transactions from lastest snapshot. Tags and attachments aren't loaded.
+func (s *transactionStore) GetLatest(ctx context.Context, walletID int) ([]models.Transaction, error) {
+	wallet, err := walletByID(ctx, s.db, walletID)
 
 	if err != nil {
 		return []models.Transaction{}, err
 	}
 
-	query := "SELECT * FROM transactions WHERE wallet_id = $1 AND created_at > $2"
+	return latestTransactions(ctx, s.db, wallet)
+}
 
-	rows, err := database.DB.Query(query, wallet.ID, wallet.LastSnapshot)
+// Return array of wallet transactions matching filter with their tags and attachments, in order they occurred
+func (s *transactionStore) GetByWallet(ctx context.Context, walletID int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	wallet, err := walletByID(ctx, s.db, walletID)
 
 	if err != nil {
 		return []models.Transaction{}, err
 	}
 
-	defer rows.Close()
+	query := "SELECT " + transactionColumns + ` FROM transactions t WHERE t.wallet_id = $1
+		AND ($2 = '' OR t.description ILIKE $3 OR t.payee ILIKE $3 OR t.category ILIKE $3
+			OR EXISTS (SELECT 1 FROM transaction_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.transaction_id = t.id AND g.name ILIKE $3)
+			OR EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = t.id AND s.category ILIKE $3))
+		AND ($4 = '' OR EXISTS (SELECT 1 FROM transaction_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.transaction_id = t.id AND g.name = $4))
+		AND ($5 = '' OR LOWER(t.payee) = LOWER($5))
+		ORDER BY t.occurred_at, t.id`
+
+	transactions, err := queryTransactions(ctx, s.db, query, wallet.ID, filter.Query, containsPattern(filter.Query), filter.Tag, filter.Payee)
+
+	if err != nil {
+		return []models.Transaction{}, err
+	}
+
+	if err := loadTransactionDetails(ctx, s.db, transactions); err != nil {
+		return []models.Transaction{}, err
+	}
+
+	return transactions, nil
+}
+
+// Return transactions from all wallets of the user matching query, best matches first, then newest first. Archived wallets are skipped.
+func (s *transactionStore) Search(ctx context.Context, userID int, query models.SearchQuery) ([]models.SearchHit, error) {
+	var args []any
+
+	// Add argument and return its placeholder
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"wu.user_id = " + arg(userID), "w.is_archived = FALSE"}
+	rank := "0::REAL"
+
+	if query.TSQuery != "" {
+		tsquery := "to_tsquery('simple', " + arg(query.TSQuery) + ")"
+		conditions = append(conditions, "t.search_vector @@ "+tsquery)
+		rank = "ts_rank(t.search_vector, " + tsquery + ")"
+	}
+
+	if !query.TwoFactor {
+		conditions = append(conditions, "w.require_2fa = FALSE")
+	}
+
+	if len(query.WalletIDs) > 0 {
+		conditions = append(conditions, "t.wallet_id = ANY("+arg(pq.Array(query.WalletIDs))+")")
+	}
+
+	for _, amount := range query.Amounts {
+		if !amountOperators[amount.Operator] {
+			return nil, fmt.Errorf("unknown amount operator %q", amount.Operator)
+		}
+
+		conditions = append(conditions, "t.amount "+amount.Operator+" "+arg(amount.Value)+"::NUMERIC * POWER(10::NUMERIC, cm.decimal_places)")
+	}
+
+	if query.Before != nil {
+		conditions = append(conditions, "t.occurred_at < "+arg(*query.Before))
+	}
+
+	if query.After != nil {
+		conditions = append(conditions, "t.occurred_at >= "+arg(*query.After))
+	}
+
+	if query.Type != "" {
+		conditions = append(conditions, "t.is_deposit = "+arg(query.Type == models.TransactionIncome))
+	}
+
+	sqlQuery := "SELECT w.wallet_name, w.currency, cm.decimal_places, " + rank + " AS rank, t." + strings.ReplaceAll(transactionColumns, ", ", ", t.") + `
+		FROM transactions t
+		JOIN wallet_users wu ON wu.wallet_id = t.wallet_id
+		JOIN wallets w ON w.id = t.wallet_id
+		JOIN currency_metadata cm ON cm.code = w.currency
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY rank DESC, t.occurred_at DESC, t.id DESC
+		LIMIT ` + arg(query.Limit)
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []models.SearchHit{}
 
 	for rows.Next() {
-		var nextTransaction models.Transaction
-		err := rows.Scan(&nextTransaction.ID, &nextTransaction.Amount, &nextTransaction.IsDeposit, &nextTransaction.Category, &nextTransaction.WalletID, &nextTransaction.CreatorID, &nextTransaction.CreatedAt)
+		var hit models.SearchHit
+
+		hit.Transaction, err = scanTransaction(rows, &hit.WalletName, &hit.Currency, &hit.DecimalPlaces, &hit.Rank)
 
 		if err != nil {
-			return []models.Transaction{}, err
+			rows.Close()
+			return nil, err
 		}
 
-		latestTransactions = append(latestTransactions, nextTransaction)
+		hits = append(hits, hit)
 	}
 
-	return latestTransactions, nil
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	transactions := make([]models.Transaction, len(hits))
+
+	for i := range hits {
+		transactions[i] = hits[i].Transaction
+	}
+
+	if err := loadTransactionDetails(ctx, s.db, transactions); err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		hits[i].Transaction = transactions[i]
+	}
+
+	return hits, nil
 }
 
-// Return array of all wallet transactions
-func GetWalletTransactions(walletID int) ([]models.Transaction, error) {
-	var transactions []models.Transaction
+// Return income and expense of every wallet category for transactions occurred since from and before until, if it isn't nil. Split transactions are counted by split lines, others by their own category.
+func (s *transactionStore) GetCategoryTotals(ctx context.Context, walletID int, from time.Time, until *time.Time) ([]models.CategoryTotal, error) {
+	totals := []models.CategoryTotal{}
 
-	wallet, err := GetWalletByID(walletID)
+	query := `SELECT COALESCE(s.category, t.category) AS line_category,
+			COALESCE(SUM(COALESCE(s.amount, t.amount)) FILTER (WHERE t.is_deposit), 0),
+			COALESCE(SUM(COALESCE(s.amount, t.amount)) FILTER (WHERE NOT t.is_deposit), 0)
+		FROM transactions t
+		LEFT JOIN transaction_splits s ON s.transaction_id = t.id
+		WHERE t.wallet_id = $1 AND t.occurred_at >= $2 AND ($3::TIMESTAMP IS NULL OR t.occurred_at < $3)
+		GROUP BY line_category
+		ORDER BY line_category`
+
+	rows, err := s.db.QueryContext(ctx, query, walletID, from, until)
 
 	if err != nil {
-		return []models.Transaction{}, err
+		return totals, err
 	}
 
-	query := "SELECT * FROM transactions WHERE wallet_id = $1"
+	defer rows.Close()
+
+	for rows.Next() {
+		var total models.CategoryTotal
+
+		if err := rows.Scan(&total.Category, &total.Income, &total.Expense); err != nil {
+			return totals, err
+		}
+
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
+}
+
+func latestTransactions(ctx context.Context, db *sql.DB, wallet models.Wallet) ([]models.Transaction, error) {
+	query := "SELECT " + transactionColumns + " FROM transactions WHERE wallet_id = $1 AND created_at > $2"
+
+	return queryTransactions(ctx, db, query, wallet.ID, wallet.LastSnapshot)
+}
+
+func queryTransactions(ctx context.Context, db *sql.DB, query string, args ...any) ([]models.Transaction, error) {
+	var transactions []models.Transaction
 
-	rows, err := database.DB.Query(query, wallet.ID)
+	rows, err := db.QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return []models.Transaction{}, err
@@ -81,8 +405,7 @@ func GetWalletTransactions(walletID int) ([]models.Transaction, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var nextTransaction models.Transaction
-		err := rows.Scan(&nextTransaction.ID, &nextTransaction.Amount, &nextTransaction.IsDeposit, &nextTransaction.Category, &nextTransaction.WalletID, &nextTransaction.CreatorID, &nextTransaction.CreatedAt)
+		nextTransaction, err := scanTransaction(rows)
 
 		if err != nil {
 			return []models.Transaction{}, err
@@ -91,5 +414,206 @@ func GetWalletTransactions(walletID int) ([]models.Transaction, error) {
 		transactions = append(transactions, nextTransaction)
 	}
 
-	return transactions, nil
+	return transactions, rows.Err()
+}
+
+// Scan row selected with transactionColumns, optionally preceded by extra columns
+func scanTransaction(row scanner, extra ...any) (models.Transaction, error) {
+	var transaction models.Transaction
+
+	dest := append(extra, &transaction.ID, &transaction.Amount, &transaction.IsDeposit, &transaction.Category, &transaction.Description, &transaction.Payee, &transaction.WalletID, &transaction.CreatorID, &transaction.CreatedAt, &transaction.OccurredAt)
+
+	err := row.Scan(dest...)
+
+	return transaction, err
+}
+
+// Attach tags to transaction, creating ones wallet doesn't have yet
+func saveTags(ctx context.Context, tx *sql.Tx, walletID, transactionID int, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query := "INSERT INTO tags (wallet_id, name) SELECT $1, UNNEST($2::TEXT[]) ON CONFLICT (wallet_id, name) DO NOTHING"
+
+	if _, err := tx.ExecContext(ctx, query, walletID, pq.Array(tags)); err != nil {
+		return err
+	}
+
+	query = "INSERT INTO transaction_tags (transaction_id, tag_id) SELECT $1, id FROM tags WHERE wallet_id = $2 AND name = ANY($3)"
+
+	_, err := tx.ExecContext(ctx, query, transactionID, walletID, pq.Array(tags))
+
+	return err
+}
+
+// Insert split lines of transaction keeping their order
+func saveSplits(ctx context.Context, tx *sql.Tx, transactionID int, splits []models.TransactionSplit) error {
+	if len(splits) == 0 {
+		return nil
+	}
+
+	categories := make([]string, len(splits))
+	amounts := make([]int64, len(splits))
+
+	for i, split := range splits {
+		categories[i] = split.Category
+		amounts[i] = int64(split.Amount)
+	}
+
+	query := `INSERT INTO transaction_splits (transaction_id, category, amount)
+		SELECT $1, s.category, s.amount FROM UNNEST($2::TEXT[], $3::INT[]) WITH ORDINALITY AS s(category, amount, position) ORDER BY s.position`
+
+	_, err := tx.ExecContext(ctx, query, transactionID, pq.Array(categories), pq.Array(amounts))
+
+	return err
+}
+
+// Rebuild full-text search document of transaction from its description, payee, categories of transaction and its splits and tags. Must be called whenever any of them changes.
+func refreshSearchVector(ctx context.Context, tx *sql.Tx, transactionID int) error {
+	query := `UPDATE transactions t SET search_vector =
+		setweight(to_tsvector('simple', t.description || ' ' || t.payee), 'A') ||
+		setweight(to_tsvector('simple', t.category || ' ' || COALESCE((SELECT STRING_AGG(s.category, ' ') FROM transaction_splits s WHERE s.transaction_id = t.id), '') || ' ' ||
+			COALESCE((SELECT STRING_AGG(g.name, ' ') FROM transaction_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.transaction_id = t.id), '')), 'B')
+		WHERE t.id = $1`
+
+	_, err := tx.ExecContext(ctx, query, transactionID)
+
+	return err
+}
+
+// Fill tags, splits and attachments of transactions, three queries for the whole list
+func loadTransactionDetails(ctx context.Context, db *sql.DB, transactions []models.Transaction) error {
+	ids := make([]int64, len(transactions))
+	positions := make(map[int]int, len(transactions))
+
+	for i := range transactions {
+		ids[i] = int64(transactions[i].ID)
+		positions[transactions[i].ID] = i
+		transactions[i].Tags = []string{}
+		transactions[i].Splits = []models.TransactionSplit{}
+		transactions[i].Attachments = []models.Attachment{}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := "SELECT tt.transaction_id, g.name FROM transaction_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.transaction_id = ANY($1) ORDER BY g.name"
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var transactionID int
+		var tag string
+
+		if err := rows.Scan(&transactionID, &tag); err != nil {
+			rows.Close()
+			return err
+		}
+
+		i := positions[transactionID]
+		transactions[i].Tags = append(transactions[i].Tags, tag)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = "SELECT transaction_id, category, amount FROM transaction_splits WHERE transaction_id = ANY($1) ORDER BY id"
+
+	rows, err = db.QueryContext(ctx, query, pq.Array(ids))
+
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var transactionID int
+		var split models.TransactionSplit
+
+		if err := rows.Scan(&transactionID, &split.Category, &split.Amount); err != nil {
+			rows.Close()
+			return err
+		}
+
+		i := positions[transactionID]
+		transactions[i].Splits = append(transactions[i].Splits, split)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = "SELECT " + attachmentColumns + " FROM transaction_attachments WHERE transaction_id = ANY($1) ORDER BY id"
+
+	attachments, err := queryAttachments(ctx, db, query, pq.Array(ids))
+
+	if err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		i := positions[attachment.TransactionID]
+		transactions[i].Attachments = append(transactions[i].Attachments, attachment)
+	}
+
+	return nil
+}
+
+// Return ILIKE pattern matching text containing value literally
+func containsPattern(value string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+	return "%" + escaper.Replace(value) + "%"
+}
+
+// Return amount with sign it affects balance with
+func signedAmount(transaction models.Transaction) int {
+	if transaction.IsDeposit {
+		return transaction.Amount
+	}
+
+	return -transaction.Amount
+}
+
+// Return data of event about transaction with amount formatted the same way API returns it
+func transactionEventData(transaction models.Transaction, decimalPlaces int) map[string]any {
+	transactionType := models.TransactionExpense
+
+	if transaction.IsDeposit {
+		transactionType = models.TransactionIncome
+	}
+
+	return map[string]any{
+		"transaction_id": transaction.ID,
+		"type":           transactionType,
+		"amount":         logic.FormatOutputValue(transaction.Amount, decimalPlaces),
+		"category":       transaction.Category,
+		"description":    transaction.Description,
+		"payee":          transaction.Payee,
+		"tags":           transaction.Tags,
+		"splits":         splitsEventData(transaction.Splits, decimalPlaces),
+		"creator_id":     transaction.CreatorID,
+		"occurred_at":    transaction.OccurredAt,
+	}
+}
+
+// Return split lines with amounts formatted the same way API returns them
+func splitsEventData(splits []models.TransactionSplit, decimalPlaces int) []map[string]any {
+	data := make([]map[string]any, len(splits))
+
+	for i, split := range splits {
+		data[i] = map[string]any{"category": split.Category, "amount": logic.FormatOutputValue(split.Amount, decimalPlaces)}
+	}
+
+	return data
 }
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+type twoFactorStore struct {
+	db *sql.DB
+}
+
+// Store new secret waiting for confirmation. Unconfirmed secret is replaced, enabled one can't be.
+func (s *twoFactorStore) SavePending(ctx context.Context, userID int, secret string) error {
+	query := `INSERT INTO user_two_factor (user_id, totp_secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET totp_secret = EXCLUDED.totp_secret, last_used_step = NULL, created_at = CURRENT_TIMESTAMP
+		WHERE user_two_factor.is_enabled = FALSE`
+
+	result, err := s.db.ExecContext(ctx, query, userID, secret)
+
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+// Return second factor settings of user or ErrTwoFactorNotFound if user never started enrollment
+func (s *twoFactorStore) Get(ctx context.Context, userID int) (models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+	var lastUsedStep sql.NullInt64
+
+	query := "SELECT user_id, totp_secret, is_enabled, last_used_step FROM user_two_factor WHERE user_id = $1"
+
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&twoFactor.UserID, &twoFactor.Secret, &twoFactor.IsEnabled, &lastUsedStep)
+
+	if err == sql.ErrNoRows {
+		return models.TwoFactor{}, ErrTwoFactorNotFound
+	} else if err != nil {
+		return models.TwoFactor{}, err
+	}
+
+	twoFactor.LastUsedStep = lastUsedStep.Int64
+
+	return twoFactor, nil
+}
+
+// Return true if user has confirmed second factor
+func (s *twoFactorStore) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	var enabled bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM user_two_factor WHERE user_id = $1 AND is_enabled = TRUE)"
+
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&enabled)
+
+	return enabled, err
+}
+
+// Confirm enrollment with code of provided time step, replace recovery codes and revoke all previously issued tokens in one DB transaction. Return new token version.
+func (s *twoFactorStore) Enable(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (int, error) {
+	var tokenVersion int
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		enableQuery := "UPDATE user_two_factor SET is_enabled = TRUE, last_used_step = $1 WHERE user_id = $2 AND is_enabled = FALSE"
+
+		result, err := tx.ExecContext(ctx, enableQuery, step, userID)
+
+		if err != nil {
+			return err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrTwoFactorEnabled
+		}
+
+		if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+			return err
+		}
+
+		versionQuery := "UPDATE users SET token_version = token_version + 1 WHERE id = $1 RETURNING token_version"
+
+		return tx.QueryRowContext(ctx, versionQuery, userID).Scan(&tokenVersion)
+	})
+
+	if err != nil {
+		return -1, err
+	}
+
+	return tokenVersion, nil
+}
+
+// Remember code of time step as used. Return false if this or later step was already used, so the code is replayed.
+func (s *twoFactorStore) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	query := "UPDATE user_two_factor SET last_used_step = $1 WHERE user_id = $2 AND is_enabled = TRUE AND (last_used_step IS NULL OR last_used_step < $1)"
+
+	result, err := s.db.ExecContext(ctx, query, step, userID)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	return affected == 1, err
+}
+
+// Mark recovery code as used. Return false if there is no such unused code.
+func (s *twoFactorStore) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	query := "UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL"
+
+	result, err := s.db.ExecContext(ctx, query, userID, codeHash)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	return affected > 0, err
+}
+
+// Replace all recovery codes of user with new ones
+func (s *twoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes)
+	})
+}
+
+// Remove second factor and recovery codes of user and revoke all previously issued tokens in one DB transaction, so sessions that passed second factor lose access to wallets requiring it. API keys created in such sessions keep working without that access. Return new token version.
+func (s *twoFactorStore) Disable(ctx context.Context, userID int) (int, error) {
+	var tokenVersion int
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		queries := []string{
+			"DELETE FROM recovery_codes WHERE user_id = $1",
+			"DELETE FROM user_two_factor WHERE user_id = $1",
+			"UPDATE api_keys SET two_factor = FALSE WHERE user_id = $1 AND two_factor = TRUE",
+		}
+
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+				return err
+			}
+		}
+
+		versionQuery := "UPDATE users SET token_version = token_version + 1 WHERE id = $1 RETURNING token_version"
+
+		return tx.QueryRowContext(ctx, versionQuery, userID).Scan(&tokenVersion)
+	})
+
+	if err != nil {
+		return -1, err
+	}
+
+	return tokenVersion, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, recoveryCodeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, codeHash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,116 +1,285 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"net/http"
+	"fmt"
+	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/khralenok/all-wallets-api/internal/database"
 	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/lib/pq"
 )
 
+// How long deleted user can cancel the deletion before their personal data is purged by the worker.
+const DeletedUserGracePeriod = 30 * 24 * time.Hour
+
+// Postgres error code for unique constraint violation
+const uniqueViolation = "23505"
+
+type userStore struct {
+	db *sql.DB
+}
+
 // Create new user
-func AddNewUser(username, passwordHash, baseCurrency string, context *gin.Context) error {
+func (s *userStore) Create(ctx context.Context, username, passwordHash, baseCurrency string) error {
 	query := "INSERT INTO users (username, user_pwd, base_currency) VALUES ($1, $2, $3)"
 
-	_, err := database.DB.Exec(query, username, passwordHash, baseCurrency)
+	_, err := s.db.ExecContext(ctx, query, username, passwordHash, baseCurrency)
+
+	if isUniqueViolation(err) {
+		return ErrUsernameTaken
+	}
+
+	return err
+}
+
+// Return user object or ErrUserNotFound if there is no active user with such id in database
+func (s *userStore) GetByID(ctx context.Context, userID int) (models.User, error) {
+	query := "SELECT * FROM users WHERE id=$1 AND is_deleted = FALSE"
+
+	return scanUser(s.db.QueryRowContext(ctx, query, userID))
+}
+
+// Return user sruct or ErrUserNotFound if there is no active user with such username in database
+func (s *userStore) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	query := "SELECT * FROM users WHERE username=$1 AND is_deleted = FALSE"
+
+	return scanUser(s.db.QueryRowContext(ctx, query, username))
+}
+
+// Return id of active user with provided username or ErrUserNotFound.
+func (s *userStore) GetIDByUsername(ctx context.Context, username string) (int, error) {
+	user, err := s.GetByUsername(ctx, username)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return user.ID, nil
+}
+
+// Return nil if there is no active users with such username in database. Usernames of deleted users can be taken again.
+func (s *userStore) CheckUsernameUnique(ctx context.Context, username string) error {
+	var exists bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM users WHERE username=$1 AND is_deleted = FALSE)"
+
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&exists)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Failed to insert user"})
 		return err
 	}
 
+	if exists {
+		return ErrUsernameTaken
+	}
+
 	return nil
 }
 
-// Return user object or error if there is no user with such id in database
-func GetUserById(userID int, context *gin.Context) (models.User, error) {
-	var user models.User
-	var deleted_at sql.Null[string]
+// Replace user password hash and revoke all previously issued tokens. Return new token version to sign fresh token with.
+func (s *userStore) UpdatePassword(ctx context.Context, userID int, passwordHash string) (int, error) {
+	var tokenVersion int
 
-	query := "SELECT * FROM users WHERE id=$1 AND is_deleted = FALSE"
-	err := database.DB.QueryRow(query, userID).Scan(&user.ID, &user.Username, &user.Password, &user.BaseCurrency, &user.CreatedAt, &user.IsDeleted, &deleted_at)
+	query := "UPDATE users SET user_pwd = $1, token_version = token_version + 1 WHERE id = $2 AND is_deleted = FALSE RETURNING token_version"
 
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't get user data from database"})
-		return models.User{}, err
+	err := s.db.QueryRowContext(ctx, query, passwordHash, userID).Scan(&tokenVersion)
+
+	if err == sql.ErrNoRows {
+		return -1, ErrUserNotFound
+	} else if err != nil {
+		return -1, err
 	}
 
-	return user, nil
+	return tokenVersion, nil
 }
 
-// Return user sruct or error if there is no user with such username in database
-func GetUserByUsername(username string, context *gin.Context) (models.User, error) {
-	var user models.User
-	var deleted_at sql.Null[string]
+// Replace password hash with upgraded one of the same password. Tokens stay valid. Nothing changes if password was changed meanwhile.
+func (s *userStore) RehashPassword(ctx context.Context, userID int, oldHash, newHash string) error {
+	query := "UPDATE users SET user_pwd = $1 WHERE id = $2 AND user_pwd = $3 AND purged_at IS NULL"
 
-	query := "SELECT * FROM users WHERE username=$1"
-	err := database.DB.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Password, &user.BaseCurrency, &user.CreatedAt, &user.IsDeleted, &deleted_at)
+	_, err := s.db.ExecContext(ctx, query, newHash, userID, oldHash)
 
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Bad Request", "message": "Invalid input format"})
-		return models.User{}, err
+	return err
+}
+
+func (s *userStore) UpdateUsername(ctx context.Context, userID int, username string) error {
+	query := "UPDATE users SET username = $1 WHERE id = $2 AND is_deleted = FALSE"
+
+	_, err := s.db.ExecContext(ctx, query, username, userID)
+
+	if isUniqueViolation(err) {
+		return ErrUsernameTaken
 	}
 
-	if user.IsDeleted {
-		context.JSON(http.StatusGone, gin.H{"error": "Gone", "message": "This user has been deleted"})
-		return models.User{}, errors.New("Gone")
+	return err
+}
+
+func (s *userStore) UpdateBaseCurrency(ctx context.Context, userID int, baseCurrency string) error {
+	query := "UPDATE users SET base_currency = $1 WHERE id = $2 AND is_deleted = FALSE"
+
+	_, err := s.db.ExecContext(ctx, query, baseCurrency, userID)
+
+	return err
+}
+
+// Return current token version of active user. Tokens signed with other version are revoked. Return ErrUserNotFound if user doesn't exist or was deleted.
+func (s *userStore) GetTokenVersion(ctx context.Context, userID int) (int, error) {
+	var tokenVersion int
+
+	query := "SELECT token_version FROM users WHERE id = $1 AND is_deleted = FALSE"
+
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&tokenVersion)
+
+	if err == sql.ErrNoRows {
+		return -1, ErrUserNotFound
+	} else if err != nil {
+		return -1, err
 	}
 
-	return user, nil
+	return tokenVersion, nil
 }
 
-// Return userID(int) of user with provided username. In case if there is no such user, will return -1.
-func GetIdByUsername(username string, context *gin.Context) int {
-	var userToAdd models.User
-	var deleted_at sql.Null[string]
+// Mark the user as deleted and resolve wallets where they are the only admin in one DB transaction. Wallet users are kept until the purge, so deletion can be cancelled during grace period.
+func (s *userStore) ScheduleDeletion(ctx context.Context, userID int, handovers []models.WalletHandover) (time.Time, error) {
+	var deletedAt time.Time
 
-	query := "SELECT * FROM users WHERE username=$1 AND is_deleted = FALSE"
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, handover := range handovers {
+			var query string
+			var args []any
+
+			switch handover.Action {
+			case "handover":
+				query = "UPDATE wallet_users SET user_role = 'admin' WHERE wallet_id = $1 AND user_id = (SELECT id FROM users WHERE username = $2 AND is_deleted = FALSE) AND user_id <> $3"
+				args = []any{handover.WalletID, handover.NewAdmin, userID}
+			case "delete":
+				query = "UPDATE wallets SET is_archived = TRUE, archived_at = CURRENT_TIMESTAMP WHERE id = $1 AND is_archived = FALSE"
+				args = []any{handover.WalletID}
+			default:
+				return fmt.Errorf("%w: unknown action %q", ErrInvalidHandover, handover.Action)
+			}
+
+			result, err := tx.ExecContext(ctx, query, args...)
 
-	err := database.DB.QueryRow(query, username).Scan(&userToAdd.ID, &userToAdd.Username, &userToAdd.Password, &userToAdd.BaseCurrency, &userToAdd.CreatedAt, &userToAdd.IsDeleted, &deleted_at)
+			if err != nil {
+				return err
+			}
+
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return fmt.Errorf("%w: can't %s wallet %d", ErrInvalidHandover, handover.Action, handover.WalletID)
+			}
+		}
+
+		query := "UPDATE users SET is_deleted = TRUE, deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND is_deleted = FALSE RETURNING deleted_at"
+
+		err := tx.QueryRowContext(ctx, query, userID).Scan(&deletedAt)
+
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+
+		return err
+	})
+
+	return deletedAt, err
+}
+
+// Return the latest deleted user with such username which can still be restored.
+func (s *userStore) GetDeletedByUsername(ctx context.Context, username string) (models.User, error) {
+	query := "SELECT * FROM users WHERE username = $1 AND is_deleted = TRUE AND purged_at IS NULL AND deleted_at > $2 ORDER BY deleted_at DESC LIMIT 1"
+
+	return scanUser(s.db.QueryRowContext(ctx, query, username, time.Now().Add(-DeletedUserGracePeriod)))
+}
+
+// Cancel scheduled deletion. Return new token version, so tokens issued before deletion stay revoked.
+func (s *userStore) Restore(ctx context.Context, userID int) (int, error) {
+	var tokenVersion int
+
+	query := "UPDATE users SET is_deleted = FALSE, deleted_at = NULL, token_version = token_version + 1 WHERE id = $1 AND is_deleted = TRUE AND purged_at IS NULL RETURNING token_version"
+
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&tokenVersion)
 
 	if err == sql.ErrNoRows {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "There is no such user"})
-		return -1
+		return -1, ErrUserNotFound
+	} else if isUniqueViolation(err) {
+		return -1, ErrUsernameTaken
 	} else if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't fetch user data"})
-		return -1
+		return -1, err
 	}
 
-	return userToAdd.ID
+	return tokenVersion, nil
 }
 
-// Return nil if there is no users with such username in database.
-func CheckIfUsernameUnique(username string, context *gin.Context) error {
-	query := "SELECT * FROM users WHERE username=$1"
+// Erase personal data of users deleted longer than grace period ago. Users are removed from all wallets and their username is replaced, so transactions they created no longer point to an identifiable person. Return number of purged users.
+func (s *userStore) PurgeDeleted(ctx context.Context, gracePeriod time.Duration) (int, error) {
+	query := "SELECT id FROM users WHERE is_deleted = TRUE AND purged_at IS NULL AND deleted_at < $1"
 
-	rows, err := database.DB.Query(query, username)
+	userIDs, err := queryIDs(ctx, s.db, query, time.Now().Add(-gracePeriod))
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't get response from database"})
-		return err
+		return 0, err
 	}
 
-	defer rows.Close()
+	for i, userID := range userIDs {
+		err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+			return purgeUser(ctx, tx, userID)
+		})
 
-	if rows.Next() {
-		context.JSON(http.StatusConflict, gin.H{"error": "Status Conflict", "message": "This username already taken"})
-		return errors.New("status conflict")
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(userIDs), nil
+}
+
+func purgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	queries := []string{
+		"DELETE FROM wallet_users WHERE user_id = $1",
+		"DELETE FROM recovery_codes WHERE user_id = $1",
+		"DELETE FROM user_two_factor WHERE user_id = $1",
+		"DELETE FROM api_keys WHERE user_id = $1",
+		"DELETE FROM idempotency_keys WHERE user_id = $1",
+		"DELETE FROM notifications WHERE user_id = $1",
+		"DELETE FROM notification_preferences WHERE user_id = $1",
+		// Failed logins are kept by username, which must be read before it's replaced. If username was taken again meanwhile, failures belong to the new owner.
+		"DELETE FROM login_failures WHERE username = (SELECT username FROM users WHERE id = $1) AND NOT EXISTS (SELECT 1 FROM users WHERE username = login_failures.username AND is_deleted = FALSE)",
+		"UPDATE users SET username = 'deleted_user_' || id, user_pwd = '', purged_at = CURRENT_TIMESTAMP WHERE id = $1",
+	}
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func MarkUserAsDeleted(userID int, context *gin.Context) error {
-	query := "UPDATE users SET is_deleted = TRUE, deleted_at = CURRENT_TIMESTAMP WHERE id=$1"
+// Scan row selected with "SELECT * FROM users" into user struct. Return ErrUserNotFound if there is no such row.
+func scanUser(row *sql.Row) (models.User, error) {
+	var user models.User
+	var deletedAt sql.NullTime
+	var purgedAt sql.NullTime
 
-	_, err := database.DB.Exec(query, userID)
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.BaseCurrency, &user.CreatedAt, &user.IsDeleted, &deletedAt, &user.TokenVersion, &purgedAt)
 
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't update user data"})
-		return err
+	if err == sql.ErrNoRows {
+		return models.User{}, ErrUserNotFound
+	} else if err != nil {
+		return models.User{}, err
 	}
 
-	return nil
+	user.DeletedAt = deletedAt.Time
+	user.PurgedAt = purgedAt.Time
+
+	return user, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
 }
@@ -1,205 +1,438 @@
 package store
 
 import (
+	"context"
 	"database/sql"
-	"net/http"
+	"fmt"
 	"strconv"
 	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/khralenok/all-wallets-api/internal/database"
 	"github.com/khralenok/all-wallets-api/internal/logic"
 	"github.com/khralenok/all-wallets-api/internal/models"
 )
 
-func AddNewWallet(input models.NewWalletRequest, context *gin.Context) (models.Wallet, error) {
+// How long archived wallet stays restorable before the worker purges it.
+const ArchivedWalletRetention = 30 * 24 * time.Hour
+
+type walletStore struct {
+	db *sql.DB
+}
+
+func (s *walletStore) Create(ctx context.Context, input models.NewWalletRequest) (models.Wallet, error) {
 	var newWallet models.Wallet
+	var archivedAt sql.NullTime
 	query := "INSERT INTO wallets (wallet_name, currency) VALUES ($1, $2) RETURNING *"
 
-	err := database.DB.QueryRow(query, input.WalletName, input.Currency).Scan(&newWallet.ID, &newWallet.WalletName, &newWallet.Currency, &newWallet.Balance, &newWallet.LastSnapshot, &newWallet.CreatedAt)
+	err := s.db.QueryRowContext(ctx, query, input.WalletName, input.Currency).Scan(&newWallet.ID, &newWallet.WalletName, &newWallet.Currency, &newWallet.Balance, &newWallet.LastSnapshot, &newWallet.CreatedAt, &newWallet.IsArchived, &archivedAt, &newWallet.RequireTwoFactor)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Failed to insert new wallet data into the database"})
 		return models.Wallet{}, err
 	}
 
 	return newWallet, nil
 }
 
-// Remove specified wallet from database
-func RemoveWallet(walletID int, context *gin.Context) error {
-	query := "DELETE FROM wallets WHERE id=$1"
+// Hide the wallet from its users. Archived wallet can be restored until it is purged by the worker after ArchivedWalletRetention.
+func (s *walletStore) Archive(ctx context.Context, walletID int) (models.Wallet, error) {
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := "UPDATE wallets SET is_archived = TRUE, archived_at = CURRENT_TIMESTAMP WHERE id = $1 AND is_archived = FALSE"
+
+		result, err := tx.ExecContext(ctx, query, walletID)
+
+		if err != nil {
+			return err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrWalletNotFound
+		}
 
-	_, err := database.DB.Exec(query, walletID)
+		return recordEvent(ctx, tx, models.WalletEvent{Type: models.EventWalletArchived, WalletID: walletID, Data: map[string]any{"permanent": false}})
+	})
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't delete this wallet"})
-		return err
+		return models.Wallet{}, err
 	}
 
-	return nil
+	return walletByID(ctx, s.db, walletID)
 }
 
-// Return true if there is wallet with such id in database
-func IsWalletExist(id int, context *gin.Context) bool {
-	var walletID int
+// Make archived wallet visible again and record wallet.restored event in one DB transaction.
+func (s *walletStore) Restore(ctx context.Context, walletID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := "UPDATE wallets SET is_archived = FALSE, archived_at = NULL WHERE id = $1 AND is_archived = TRUE"
 
-	query := "SELECT 1 FROM wallets WHERE id = $1"
+		result, err := tx.ExecContext(ctx, query, walletID)
 
-	err := database.DB.QueryRow(query, id).Scan(&walletID)
+		if err != nil {
+			return err
+		}
 
-	if err == sql.ErrNoRows {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "There is no such wallet"})
-		return false
-	}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrWalletNotFound
+		}
 
-	return true
+		return recordEvent(ctx, tx, models.WalletEvent{Type: models.EventWalletRestored, WalletID: walletID, Data: map[string]any{}})
+	})
 }
 
-// Return wallet struct by provided id or an error
-func GetWalletByID(walletID int) (models.Wallet, error) {
-	var wallet models.Wallet
+// Permanently remove the wallet together with its transactions, recurrent payments, users and webhooks in one DB transaction. Webhooks are gone, so only open event streams learn about it.
+func (s *walletStore) HardDelete(ctx context.Context, walletID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := deleteWalletCascade(ctx, tx, walletID); err != nil {
+			return err
+		}
 
-	query := "SELECT * FROM wallets WHERE id = $1"
+		return recordEvent(ctx, tx, models.WalletEvent{Type: models.EventWalletArchived, WalletID: walletID, Data: map[string]any{"permanent": true}})
+	})
+}
 
-	err := database.DB.QueryRow(query, walletID).Scan(&wallet.ID, &wallet.WalletName, &wallet.Currency, &wallet.Balance, &wallet.LastSnapshot, &wallet.CreatedAt)
+// Permanently remove all wallets which were archived longer than retention ago. Return number of removed wallets.
+func (s *walletStore) PurgeArchived(ctx context.Context, retention time.Duration) (int, error) {
+	query := "SELECT id FROM wallets WHERE is_archived = TRUE AND archived_at < $1"
+
+	walletIDs, err := queryIDs(ctx, s.db, query, time.Now().Add(-retention))
 
 	if err != nil {
-		return models.Wallet{}, err
+		return 0, err
 	}
 
-	return wallet, nil
+	for i, walletID := range walletIDs {
+		err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+			return deleteWalletCascade(ctx, tx, walletID)
+		})
+
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(walletIDs), nil
 }
 
-// Return array of simplified wallet structs or an error.
-func GetWalletsByUser(user models.User, context *gin.Context) ([]models.WalletOutput, error) {
-	var userWallets []models.WalletOutput
+// Return ErrWalletNotFound if there is no active wallet with such id in database
+func (s *walletStore) EnsureActive(ctx context.Context, walletID int) error {
+	var exists bool
 
-	userCurrencyDecimalPlaces, err := GetCurrencyDecimalPlaces(user.BaseCurrency)
+	query := "SELECT EXISTS (SELECT 1 FROM wallets WHERE id = $1 AND is_archived = FALSE)"
+
+	err := s.db.QueryRowContext(ctx, query, walletID).Scan(&exists)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't get user currency metadata"})
-		return userWallets, err
+		return err
+	}
+
+	if !exists {
+		return ErrWalletNotFound
 	}
 
-	query := "SELECT w.id AS wallet_id, w.wallet_name, w.currency, w.balance, wu.user_role, cm.decimal_places FROM wallets w JOIN wallet_users wu ON wu.wallet_id = w.id JOIN currency_metadata cm ON w.currency = cm.code WHERE wu.user_id = $1"
+	return nil
+}
+
+// Return wallet struct by provided id or an error
+func (s *walletStore) GetByID(ctx context.Context, walletID int) (models.Wallet, error) {
+	return walletByID(ctx, s.db, walletID)
+}
 
-	rows, err := database.DB.Query(query, user.ID)
+// Return summary of all active wallets of the user with balances converted to user base currency
+func (s *walletStore) GetByUser(ctx context.Context, user models.User) ([]models.WalletOutput, error) {
+	dashboard, err := s.GetDashboard(ctx, user, logic.MonthStart(time.Now()))
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't get wallets list from database"})
-		return userWallets, err
+		return []models.WalletOutput{}, err
 	}
 
-	defer rows.Close()
+	userWallets := make([]models.WalletOutput, len(dashboard))
 
-	for rows.Next() {
-		var nextWallet models.WalletOutput
-		var rawBalance int
-		var decimalPlaces int
+	for i, wallet := range dashboard {
+		userWallets[i] = wallet.WalletOutput
+	}
 
-		err := rows.Scan(&nextWallet.WalletID, &nextWallet.WalletName, &nextWallet.Currency, &rawBalance, &nextWallet.UserRole, &decimalPlaces)
+	return userWallets, nil
+}
 
-		if err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't read next row in the wallets list"})
-			return []models.WalletOutput{}, err
-		}
+// Return all active wallets of the user with balances, last activity, income and expense since monthStart and member count. Whatever number of wallets, it takes three queries: base currency, wallet figures and exchange rates.
+func (s *walletStore) GetDashboard(ctx context.Context, user models.User, monthStart time.Time) ([]models.DashboardWallet, error) {
+	userCurrencyDecimalPlaces, err := currencyDecimalPlaces(ctx, s.db, user.BaseCurrency)
+
+	if err != nil {
+		return []models.DashboardWallet{}, err
+	}
 
-		latestTransactions, err := GetLatestTransactions(nextWallet.WalletID)
+	summaries, err := walletSummaries(ctx, s.db, user.ID, monthStart)
 
-		if err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't get transactions data for this wallet"})
-			return []models.WalletOutput{}, err
-		}
+	if err != nil {
+		return []models.DashboardWallet{}, err
+	}
 
-		rawBalance += logic.CalcSumOfTransactions(latestTransactions)
+	currencies := []string{}
 
-		nextWallet.Balance = logic.FormatOutputValue(rawBalance, decimalPlaces)
+	for _, summary := range summaries {
+		currencies = append(currencies, summary.Currency)
+	}
 
-		if nextWallet.Currency != user.BaseCurrency {
+	rates, err := exchangeRatesTo(ctx, s.db, currencies, user.BaseCurrency)
 
-			rate, err := GetRate(nextWallet.Currency, user.BaseCurrency, context)
+	if err != nil {
+		return []models.DashboardWallet{}, err
+	}
 
-			if err != nil {
-				context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't fetch exchange rates"})
-				return []models.WalletOutput{}, err
+	dashboard := make([]models.DashboardWallet, 0, len(summaries))
+
+	for _, summary := range summaries {
+		wallet := models.DashboardWallet{
+			WalletOutput: models.WalletOutput{
+				WalletID:   summary.WalletID,
+				WalletName: summary.WalletName,
+				Currency:   summary.Currency,
+				Balance:    logic.FormatOutputValue(summary.Balance, summary.DecimalPlaces),
+				UserRole:   summary.UserRole,
+			},
+			LastActivityAt: summary.LastActivityAt,
+			MonthIncome:    logic.FormatOutputValue(summary.MonthIncome, summary.DecimalPlaces),
+			MonthExpense:   logic.FormatOutputValue(summary.MonthExpense, summary.DecimalPlaces),
+			MemberCount:    summary.MemberCount,
+		}
+
+		if summary.Currency == user.BaseCurrency {
+			wallet.UserCurrencyBalance = wallet.Balance
+		} else {
+			rate, ok := rates[summary.Currency]
+
+			if !ok {
+				return []models.DashboardWallet{}, fmt.Errorf("exchange rate %s/%s %w", summary.Currency, user.BaseCurrency, ErrNotFound)
 			}
 
-			balanceToExchange, err := strconv.ParseFloat(nextWallet.Balance, 64)
+			balanceToExchange, err := strconv.ParseFloat(wallet.Balance, 64)
 
 			if err != nil {
-				context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Some problem with exchange rates occurred"})
-				return []models.WalletOutput{}, err
+				return []models.DashboardWallet{}, err
 			}
 
-			nextWallet.UserCurrencyBalance = strconv.FormatFloat(balanceToExchange*rate, 'f', userCurrencyDecimalPlaces, 64)
-		} else {
-			nextWallet.UserCurrencyBalance = nextWallet.Balance
+			wallet.UserCurrencyBalance = strconv.FormatFloat(balanceToExchange*rate, 'f', userCurrencyDecimalPlaces, 64)
 		}
 
-		userWallets = append(userWallets, nextWallet)
+		dashboard = append(dashboard, wallet)
 	}
 
-	return userWallets, nil
+	return dashboard, nil
 }
 
-// Return decimal places for specific wallet. Don't try to use it for definining currency specific decimal places — instead use specialized function GetCurrencyDecimalPlaces.
-func GetWalletDecimalPlaces(walletID int) (int, error) {
+// Return decimal places for specific wallet. Don't try to use it for definining currency specific decimal places — instead use specialized function CurrencyRepository.GetDecimalPlaces.
+func (s *walletStore) GetDecimalPlaces(ctx context.Context, walletID int) (int, error) {
 	var walletCurrency string
 	var decimalPlaces int
 
 	query := "SELECT cm.code, cm.decimal_places FROM wallets w JOIN currency_metadata cm ON cm.code = w.currency WHERE w.id = $1"
 
-	err := database.DB.QueryRow(query, walletID).Scan(&walletCurrency, &decimalPlaces)
+	err := s.db.QueryRowContext(ctx, query, walletID).Scan(&walletCurrency, &decimalPlaces)
 
-	if err != nil {
+	if err == sql.ErrNoRows {
+		return -1, ErrWalletNotFound
+	} else if err != nil {
 		return -1, err
 	}
 
 	return decimalPlaces, nil
 }
 
-// Update balance for specified sum.
-func UpdateBalance(walletID, sumOfLatestTransactions int) error {
-	wallet, err := GetWalletByID(walletID)
+// Sum up transactions recorded after latest snapshot into wallet balance and move snapshot time to now. Snapshot goes by record time rather than occurred time, so backdated transactions are summed up too. Return sum of transactions that were added.
+func (s *walletStore) TakeSnapshot(ctx context.Context, walletID int) (int, error) {
+	var sumOfLatestTransactions int
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var lastSnapshot time.Time
+
+		// Exclusive lock waits for transactions being added, so none of them is committed with record time before new snapshot time
+		query := "SELECT last_snapshot FROM wallets WHERE id = $1 FOR UPDATE"
+
+		err := tx.QueryRowContext(ctx, query, walletID).Scan(&lastSnapshot)
+
+		if err == sql.ErrNoRows {
+			return ErrWalletNotFound
+		} else if err != nil {
+			return err
+		}
+
+		newSnapshotTime := time.Now()
+
+		query = "SELECT COALESCE(SUM(CASE WHEN is_deposit THEN amount ELSE -amount END), 0) FROM transactions WHERE wallet_id = $1 AND created_at > $2 AND created_at <= $3"
+
+		if err := tx.QueryRowContext(ctx, query, walletID, lastSnapshot, newSnapshotTime).Scan(&sumOfLatestTransactions); err != nil {
+			return err
+		}
+
+		query = "UPDATE wallets SET balance = balance + $1, last_snapshot = $2 WHERE id = $3"
+
+		_, err = tx.ExecContext(ctx, query, sumOfLatestTransactions, newSnapshotTime, walletID)
 
-	if err != nil {
 		return err
+	})
+
+	return sumOfLatestTransactions, err
+}
+
+// Return current wallet balance, i.e. snapshot plus transactions made after it, in one query
+func (s *walletStore) GetBalance(ctx context.Context, walletID int) (int, error) {
+	var balance int
+
+	query := "SELECT w.balance + COALESCE((SELECT SUM(CASE WHEN t.is_deposit THEN t.amount ELSE -t.amount END) FROM transactions t WHERE t.wallet_id = w.id AND t.created_at > w.last_snapshot), 0) FROM wallets w WHERE w.id = $1"
+
+	err := s.db.QueryRowContext(ctx, query, walletID).Scan(&balance)
+
+	if err == sql.ErrNoRows {
+		return 0, ErrWalletNotFound
+	}
+
+	return balance, err
+}
+
+// Return true if wallet members must be logged in with second factor to access it
+func (s *walletStore) RequiresTwoFactor(ctx context.Context, walletID int) (bool, error) {
+	var required bool
+
+	query := "SELECT require_2fa FROM wallets WHERE id = $1"
+
+	err := s.db.QueryRowContext(ctx, query, walletID).Scan(&required)
+
+	if err == sql.ErrNoRows {
+		return false, ErrWalletNotFound
 	}
 
-	newBalance := wallet.Balance + sumOfLatestTransactions
-	newSnapshotTime := time.Now()
+	return required, err
+}
 
-	query := "UPDATE wallets SET balance = $1, last_snapshot = $2 WHERE id = $3"
+func (s *walletStore) SetRequireTwoFactor(ctx context.Context, walletID int, required bool) error {
+	query := "UPDATE wallets SET require_2fa = $1 WHERE id = $2 AND is_archived = FALSE"
 
-	_, err = database.DB.Exec(query, newBalance, newSnapshotTime, walletID)
+	result, err := s.db.ExecContext(ctx, query, required, walletID)
 
 	if err != nil {
 		return err
 	}
 
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrWalletNotFound
+	}
+
+	return nil
+}
+
+func walletByID(ctx context.Context, db *sql.DB, walletID int) (models.Wallet, error) {
+	var wallet models.Wallet
+	var archivedAt sql.NullTime
+
+	query := "SELECT * FROM wallets WHERE id = $1"
+
+	err := db.QueryRowContext(ctx, query, walletID).Scan(&wallet.ID, &wallet.WalletName, &wallet.Currency, &wallet.Balance, &wallet.LastSnapshot, &wallet.CreatedAt, &wallet.IsArchived, &archivedAt, &wallet.RequireTwoFactor)
+
+	if err == sql.ErrNoRows {
+		return models.Wallet{}, ErrWalletNotFound
+	} else if err != nil {
+		return models.Wallet{}, err
+	}
+
+	wallet.ArchivedAt = archivedAt.Time
+
+	return wallet, nil
+}
+
+// Remove every row referencing the wallet and then the wallet itself. Caller is responsible for commit or rollback.
+func deleteWalletCascade(ctx context.Context, tx *sql.Tx, walletID int) error {
+	queries := []string{
+		"DELETE FROM recurrent_payments WHERE wallet_id = $1",
+		"DELETE FROM transaction_attachments WHERE transaction_id IN (SELECT id FROM transactions WHERE wallet_id = $1)",
+		"DELETE FROM transaction_tags WHERE transaction_id IN (SELECT id FROM transactions WHERE wallet_id = $1)",
+		"DELETE FROM transaction_splits WHERE transaction_id IN (SELECT id FROM transactions WHERE wallet_id = $1)",
+		"DELETE FROM transactions WHERE wallet_id = $1",
+		"DELETE FROM tags WHERE wallet_id = $1",
+		"DELETE FROM wallet_users WHERE wallet_id = $1",
+		"DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE wallet_id = $1)",
+		"DELETE FROM webhooks WHERE wallet_id = $1",
+		"DELETE FROM notifications WHERE wallet_id = $1",
+		"DELETE FROM wallets WHERE id = $1",
+	}
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, walletID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// Return true if considering latest transactions wallet have enough funds to add expense of specified amount
-func CheckIfBalanceIsEnough(walletID, expense int) (bool, error) {
-	wallet, err := GetWalletByID(walletID)
+// Return ids selected by query which must select single integer column.
+func queryIDs(ctx context.Context, db *sql.DB, query string, args ...any) ([]int, error) {
+	var ids []int
+
+	rows, err := db.QueryContext(ctx, query, args...)
 
 	if err != nil {
-		return false, err
+		return []int{}, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+
+		if err := rows.Scan(&id); err != nil {
+			return []int{}, err
+		}
+
+		ids = append(ids, id)
 	}
 
-	latestTransactions, err := GetLatestTransactions(walletID)
+	return ids, rows.Err()
+}
+
+// Balance of wallet: snapshot plus sum of transactions made after it
+const currentBalanceQuery = `SELECT w.balance + COALESCE(delta.amount, 0)
+	FROM wallets w
+	LEFT JOIN LATERAL (SELECT SUM(CASE WHEN t.is_deposit THEN t.amount ELSE -t.amount END) AS amount FROM transactions t WHERE t.wallet_id = w.id AND t.created_at > w.last_snapshot) delta ON TRUE
+	WHERE w.id = $1`
+
+// Compute figures of all active wallets of the user in one query. Balance is the snapshot plus sum of transactions made after it.
+func walletSummaries(ctx context.Context, db *sql.DB, userID int, monthStart time.Time) ([]models.WalletSummary, error) {
+	summaries := []models.WalletSummary{}
+
+	query := `SELECT w.id, w.wallet_name, w.currency, cm.decimal_places, wu.user_role,
+			w.balance + COALESCE(delta.amount, 0), activity.last_at, COALESCE(monthly.income, 0), COALESCE(monthly.expense, 0), members.total
+		FROM wallets w
+		JOIN wallet_users wu ON wu.wallet_id = w.id AND wu.user_id = $1
+		JOIN currency_metadata cm ON cm.code = w.currency
+		LEFT JOIN LATERAL (SELECT SUM(CASE WHEN t.is_deposit THEN t.amount ELSE -t.amount END) AS amount FROM transactions t WHERE t.wallet_id = w.id AND t.created_at > w.last_snapshot) delta ON TRUE
+		LEFT JOIN LATERAL (SELECT MAX(t.created_at) AS last_at FROM transactions t WHERE t.wallet_id = w.id) activity ON TRUE
+		LEFT JOIN LATERAL (SELECT SUM(t.amount) FILTER (WHERE t.is_deposit) AS income, SUM(t.amount) FILTER (WHERE NOT t.is_deposit) AS expense FROM transactions t WHERE t.wallet_id = w.id AND t.occurred_at >= $2) monthly ON TRUE
+		LEFT JOIN LATERAL (SELECT COUNT(*) AS total FROM wallet_users m WHERE m.wallet_id = w.id) members ON TRUE
+		WHERE w.is_archived = FALSE
+		ORDER BY w.id`
+
+	rows, err := db.QueryContext(ctx, query, userID, monthStart)
 
 	if err != nil {
-		return false, err
+		return summaries, err
 	}
 
-	currentBalance := wallet.Balance + logic.CalcSumOfTransactions(latestTransactions)
+	defer rows.Close()
+
+	for rows.Next() {
+		var summary models.WalletSummary
+		var lastActivityAt sql.NullTime
+
+		err := rows.Scan(&summary.WalletID, &summary.WalletName, &summary.Currency, &summary.DecimalPlaces, &summary.UserRole, &summary.Balance, &lastActivityAt, &summary.MonthIncome, &summary.MonthExpense, &summary.MemberCount)
+
+		if err != nil {
+			return []models.WalletSummary{}, err
+		}
+
+		if lastActivityAt.Valid {
+			summary.LastActivityAt = &lastActivityAt.Time
+		}
 
-	if expense > currentBalance {
-		return false, nil
+		summaries = append(summaries, summary)
 	}
 
-	return true, nil
+	return summaries, rows.Err()
 }
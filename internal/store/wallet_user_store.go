@@ -1,90 +1,147 @@
 package store
 
 import (
+	"context"
 	"database/sql"
-	"net/http"
 
-	"github.com/gin-gonic/gin"
-	"github.com/khralenok/all-wallets-api/internal/database"
 	"github.com/khralenok/all-wallets-api/internal/models"
 )
 
-// Insert new wallet user to database. Return new wallet user sruct.
-func AddWalletUser(walletId, userId int, userRole string, context *gin.Context) (models.WalletUser, error) {
+type walletUserStore struct {
+	db *sql.DB
+}
+
+// Insert new wallet user to database and record member.added event. Return new wallet user sruct.
+func (s *walletUserStore) Add(ctx context.Context, walletID, userID int, userRole string) (models.WalletUser, error) {
 	var newWalletUser models.WalletUser
 
-	query := "INSERT INTO wallet_users (wallet_id, user_id, user_role) VALUES ($1, $2, $3) RETURNING *"
-	err := database.DB.QueryRow(query, walletId, userId, userRole).Scan(&newWalletUser.WalletID, &newWalletUser.UserID, &newWalletUser.UserRole, &newWalletUser.CreatedAt)
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := "INSERT INTO wallet_users (wallet_id, user_id, user_role) VALUES ($1, $2, $3) RETURNING *"
+		err := tx.QueryRowContext(ctx, query, walletID, userID, userRole).Scan(&newWalletUser.WalletID, &newWalletUser.UserID, &newWalletUser.UserRole, &newWalletUser.CreatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		return recordMemberEvent(ctx, tx, models.EventMemberAdded, walletID, userID, map[string]any{"user_role": userRole})
+	})
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Failed to insert new wallet user data into the database"})
 		return models.WalletUser{}, err
 	}
 
 	return newWalletUser, nil
 }
 
-// Check if there in no user with such user id in specific wallet users list. Return true if there is no user with such id.
-func CheckWalletUserUnique(userID, walletID int, context *gin.Context) bool {
-	var existingUser models.WalletUser
-	query := "SELECT * FROM wallet_users WHERE user_id=$1 and wallet_id=$2"
-
-	err := database.DB.QueryRow(query, userID, walletID).Scan(&existingUser.WalletID, &existingUser.UserID, &existingUser.UserRole, &existingUser.CreatedAt)
+// Return ErrAlreadyWalletUser if user with such id is already in specific wallet users list.
+func (s *walletUserStore) CheckUnique(ctx context.Context, userID, walletID int) error {
+	_, err := walletUserRole(ctx, s.db, userID, walletID)
 
-	if err != sql.ErrNoRows {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request", "message": "This user already have access to this wallet"})
-		return false
+	if err == ErrNotWalletUser {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	return true
+	return ErrAlreadyWalletUser
 }
 
-// Return true if user have admin role for wallet with provided ID.
-func CheckUserPermissions(userID, walletID int, context *gin.Context) bool {
-	var supplicant models.WalletUser
+// Return nil if user has any role in wallet with provided ID, otherwise ErrNotWalletUser.
+func (s *walletUserStore) CheckMember(ctx context.Context, userID, walletID int) error {
+	_, err := walletUserRole(ctx, s.db, userID, walletID)
 
-	query := "SELECT * FROM wallet_users WHERE user_id=$1 and wallet_id=$2"
+	return err
+}
 
-	err := database.DB.QueryRow(query, userID, walletID).Scan(&supplicant.WalletID, &supplicant.UserID, &supplicant.UserRole, &supplicant.CreatedAt)
+// Return nil if user have admin role for wallet with provided ID, otherwise ErrNotWalletUser or ErrNotWalletAdmin.
+func (s *walletUserStore) CheckAdmin(ctx context.Context, userID, walletID int) error {
+	role, err := walletUserRole(ctx, s.db, userID, walletID)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Failed to fetch supplicant user data"})
-		return false
+		return err
 	}
 
-	if supplicant.UserRole != "admin" {
-		context.JSON(http.StatusForbidden, gin.H{"error": "Status Forbidden", "message": "You have not enough no permission to add new users"})
-		return false
+	if role != "admin" {
+		return ErrNotWalletAdmin
 	}
 
-	return true
+	return nil
 }
 
-// Remove the row with such user_id and wallet_id from wallet_users table
-func RemoveWalletUser(walletID, userToDeleteId int, context *gin.Context) error {
-	query := "DELETE FROM wallet_users WHERE user_id=$1 AND wallet_id=$2"
+// Return true if provided user is the only admin of the wallet, so removing them would leave the wallet without admin. Admins scheduled for deletion don't count.
+func (s *walletUserStore) IsLastAdmin(ctx context.Context, userID, walletID int) (bool, error) {
+	var otherAdmins int
 
-	_, err := database.DB.Exec(query, userToDeleteId, walletID)
+	// Users scheduled for deletion are still in wallet until purged, but can't administer it
+	query := "SELECT COUNT(*) FROM wallet_users wu JOIN users u ON u.id = wu.user_id WHERE wu.wallet_id = $1 AND wu.user_role = 'admin' AND wu.user_id <> $2 AND u.is_deleted = FALSE"
+
+	err := s.db.QueryRowContext(ctx, query, walletID, userID).Scan(&otherAdmins)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't delete this user"})
-		return err
+		return false, err
 	}
 
-	context.JSON(http.StatusNoContent, gin.H{"status": "No content", "message": "Wallet User was successfully deleted"})
-	return nil
+	if otherAdmins > 0 {
+		return false, nil
+	}
+
+	role, err := walletUserRole(ctx, s.db, userID, walletID)
+
+	if err == ErrNotWalletUser {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return role == "admin", nil
 }
 
-// Remove all rows with this user from wallet_users table
-func RemoveUserFromAllWallets(userID int, context *gin.Context) error {
-	query := "DELETE FROM wallet_users WHERE user_id=$1"
+// Remove the row with such user_id and wallet_id from wallet_users table and record member.removed event
+func (s *walletUserStore) Remove(ctx context.Context, walletID, userID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := "DELETE FROM wallet_users WHERE user_id=$1 AND wallet_id=$2"
 
-	_, err := database.DB.Exec(query, userID)
+		if _, err := tx.ExecContext(ctx, query, userID, walletID); err != nil {
+			return err
+		}
 
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error", "message": "Can't remove this user from wallet users list"})
+		return recordMemberEvent(ctx, tx, models.EventMemberRemoved, walletID, userID, map[string]any{})
+	})
+}
+
+// Return ids of active wallets where provided user is the only admin. Admins scheduled for deletion don't count.
+func (s *walletUserStore) GetSolelyAdministeredWallets(ctx context.Context, userID int) ([]int, error) {
+	query := "SELECT wu.wallet_id FROM wallet_users wu JOIN wallets w ON w.id = wu.wallet_id WHERE wu.user_id = $1 AND wu.user_role = 'admin' AND w.is_archived = FALSE AND NOT EXISTS (SELECT 1 FROM wallet_users other JOIN users u ON u.id = other.user_id WHERE other.wallet_id = wu.wallet_id AND other.user_role = 'admin' AND other.user_id <> $1 AND u.is_deleted = FALSE)"
+
+	return queryIDs(ctx, s.db, query, userID)
+}
+
+func walletUserRole(ctx context.Context, db *sql.DB, userID, walletID int) (string, error) {
+	var role string
+
+	query := "SELECT user_role FROM wallet_users WHERE user_id = $1 AND wallet_id = $2"
+
+	err := db.QueryRowContext(ctx, query, userID, walletID).Scan(&role)
+
+	if err == sql.ErrNoRows {
+		return "", ErrNotWalletUser
+	} else if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// Record event about wallet member. Data is completed with user id and username.
+func recordMemberEvent(ctx context.Context, tx *sql.Tx, eventType string, walletID, userID int, data map[string]any) error {
+	var username string
+
+	if err := tx.QueryRowContext(ctx, "SELECT username FROM users WHERE id = $1", userID).Scan(&username); err != nil {
 		return err
 	}
 
-	return nil
+	data["user_id"] = userID
+	data["username"] = username
+
+	return recordEvent(ctx, tx, models.WalletEvent{Type: eventType, WalletID: walletID, Data: data})
 }
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/lib/pq"
+)
+
+// Only latest deliveries of webhook are shown in its log
+const webhookDeliveriesLimit = 100
+
+const webhookColumns = "id, wallet_id, creator_id, url, event_types, created_at"
+
+const webhookDeliveryColumns = "id, webhook_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at"
+
+type webhookStore struct {
+	db *sql.DB
+}
+
+// Insert new webhook of the wallet
+func (s *webhookStore) Create(ctx context.Context, webhook models.Webhook, secret string) (models.Webhook, error) {
+	query := "INSERT INTO webhooks (wallet_id, creator_id, url, secret, event_types) VALUES ($1, $2, $3, $4, $5) RETURNING " + webhookColumns
+
+	row := s.db.QueryRowContext(ctx, query, webhook.WalletID, webhook.CreatorID, webhook.URL, secret, pq.Array(webhook.EventTypes))
+
+	return scanWebhook(row)
+}
+
+// Return all webhooks of the wallet
+func (s *webhookStore) GetByWallet(ctx context.Context, walletID int) ([]models.Webhook, error) {
+	webhooks := []models.Webhook{}
+
+	query := "SELECT " + webhookColumns + " FROM webhooks WHERE wallet_id = $1 ORDER BY id"
+
+	rows, err := s.db.QueryContext(ctx, query, walletID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Delete webhook of the wallet together with its delivery log, deliveries that weren't sent yet are dropped. Return ErrWebhookNotFound if wallet has no such webhook.
+func (s *webhookStore) Delete(ctx context.Context, walletID, webhookID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = (SELECT id FROM webhooks WHERE id = $1 AND wallet_id = $2)", webhookID, walletID); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1 AND wallet_id = $2", webhookID, walletID)
+
+		if err != nil {
+			return err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrWebhookNotFound
+		}
+
+		return nil
+	})
+}
+
+// Return latest deliveries of webhook, newest first. Return ErrWebhookNotFound if wallet has no such webhook.
+func (s *webhookStore) GetDeliveries(ctx context.Context, walletID, webhookID int) ([]models.WebhookDelivery, error) {
+	var exists bool
+
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND wallet_id = $2)", webhookID, walletID).Scan(&exists)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+
+	deliveries := []models.WebhookDelivery{}
+
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2"
+
+	rows, err := s.db.QueryContext(ctx, query, webhookID, webhookDeliveriesLimit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var nextAttemptAt, deliveredAt sql.NullTime
+		var lastStatusCode sql.NullInt64
+		var lastError sql.NullString
+
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventType, &delivery.Payload, &delivery.Status, &delivery.Attempts, &nextAttemptAt, &lastStatusCode, &lastError, &deliveredAt, &delivery.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Attempt time means nothing once delivery is finished
+		if nextAttemptAt.Valid && delivery.Status == models.DeliveryPending {
+			delivery.NextAttemptAt = &nextAttemptAt.Time
+		}
+
+		if lastStatusCode.Valid {
+			statusCode := int(lastStatusCode.Int64)
+			delivery.LastStatusCode = &statusCode
+		}
+
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = &deliveredAt.Time
+		}
+
+		delivery.LastError = lastError.String
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Take up to limit deliveries which are due and count the attempt. They aren't due again until lease passes, so concurrent workers don't send them twice and deliveries of crashed worker are retried.
+func (s *webhookStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.PendingDelivery, error) {
+	deliveries := []models.PendingDelivery{}
+
+	query := `UPDATE webhook_deliveries d SET attempts = d.attempts + 1, next_attempt_at = $2
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= $3 ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+		RETURNING d.id, d.webhook_id, w.url, w.secret, d.event_type, d.payload, d.attempts`
+
+	now := time.Now()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, now.Add(lease), now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery models.PendingDelivery
+		var payload string
+
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.URL, &delivery.Secret, &delivery.EventType, &payload, &delivery.Attempts); err != nil {
+			return nil, err
+		}
+
+		delivery.Payload = []byte(payload)
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Mark delivery as accepted by receiver
+func (s *webhookStore) MarkDelivered(ctx context.Context, deliveryID int64, statusCode int) error {
+	query := "UPDATE webhook_deliveries SET status = 'delivered', last_status_code = $2, last_error = NULL, delivered_at = CURRENT_TIMESTAMP WHERE id = $1"
+
+	_, err := s.db.ExecContext(ctx, query, deliveryID, statusCode)
+
+	return err
+}
+
+// Save result of failed attempt. Delivery is retried at retryAt, zero retryAt means there are no attempts left and delivery is failed for good. Zero status code means receiver didn't answer.
+func (s *webhookStore) MarkFailed(ctx context.Context, deliveryID int64, statusCode int, lastError string, retryAt time.Time) error {
+	status := models.DeliveryPending
+
+	if retryAt.IsZero() {
+		status = models.DeliveryFailed
+		retryAt = time.Now()
+	}
+
+	query := "UPDATE webhook_deliveries SET status = $2, last_status_code = NULLIF($3, 0), last_error = $4, next_attempt_at = $5 WHERE id = $1"
+
+	_, err := s.db.ExecContext(ctx, query, deliveryID, status, statusCode, lastError, retryAt)
+
+	return err
+}
+
+// Delete delivered and failed deliveries created before retention ago. Return number of deleted deliveries.
+func (s *webhookStore) PurgeFinished(ctx context.Context, retention time.Duration) (int, error) {
+	query := "DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1"
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().Add(-retention))
+
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+
+	return int(purged), err
+}
+
+// Scan row with webhookColumns
+func scanWebhook(row scanner) (models.Webhook, error) {
+	var webhook models.Webhook
+
+	err := row.Scan(&webhook.ID, &webhook.WalletID, &webhook.CreatorID, &webhook.URL, pq.Array(&webhook.EventTypes), &webhook.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return models.Webhook{}, ErrWebhookNotFound
+	} else if err != nil {
+		return models.Webhook{}, err
+	}
+
+	return webhook, nil
+}
@@ -0,0 +1,136 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parameters of generated codes. They are the defaults of authenticator apps, so they are not put into otpauth URI explicitly by every app.
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// Codes of that many neighbouring periods are accepted too, so small clock drift doesn't matter
+	Skew = 1
+
+	secretBytes        = 20
+	recoveryCodeGroups = 3
+	RecoveryCodeCount  = 10
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Return new random base32 secret
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretBytes)
+
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(secret), nil
+}
+
+// Return otpauth URI authenticator apps read from QR code
+func URI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Return time step code belongs to if code is valid at provided time. Caller must remember the step and refuse codes of the same or earlier steps, so code can't be used twice.
+func Validate(secret, code string, now time.Time) (int64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(Period.Seconds())
+
+	for step := current - Skew; step <= current+Skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(generate(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// Return true if value looks like TOTP code rather than recovery code
+func IsCode(value string) bool {
+	if len(value) != Digits {
+		return false
+	}
+
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Return new single-use recovery codes in xxxx-xxxx-xxxx format. Only their hashes should be stored.
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, recoveryCodeGroups*5/2+1)
+
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+
+		encoded := strings.ToLower(encoding.EncodeToString(raw))[:recoveryCodeGroups*4]
+		groups := make([]string, recoveryCodeGroups)
+
+		for group := range groups {
+			groups[group] = encoded[group*4 : group*4+4]
+		}
+
+		codes[i] = strings.Join(groups, "-")
+	}
+
+	return codes, nil
+}
+
+// Return hash recovery code is stored as. Codes are random, so fast hash is enough. Case and dashes are ignored.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// RFC 6238 code for time step
+func generate(key []byte, step int64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%1000000)
+}
@@ -0,0 +1,172 @@
+package totp
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Base32 of ASCII "12345678901234567890", SHA-1 key of RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateRFC6238Vectors(t *testing.T) {
+	// RFC 6238 appendix B lists 8 digit codes, 6 digit ones are their last digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, test := range tests {
+		now := time.Unix(test.unix, 0)
+
+		step, ok := Validate(rfcSecret, test.code, now)
+
+		if !ok || step != test.unix/30 {
+			t.Errorf("Validate(%s at %d) = %d, %t, want %d, true", test.code, test.unix, step, ok, test.unix/30)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	key, _ := encoding.DecodeString(rfcSecret)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / 30
+
+	tests := []struct {
+		name  string
+		step  int64
+		valid bool
+	}{
+		{"two periods ago", current - 2, false},
+		{"previous period", current - 1, true},
+		{"current period", current, true},
+		{"next period", current + 1, true},
+		{"two periods ahead", current + 2, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, generate(key, test.step), now)
+
+			if ok != test.valid || (ok && step != test.step) {
+				t.Errorf("Validate = %d, %t, want %d, %t", step, ok, test.step, test.valid)
+			}
+		})
+	}
+}
+
+func TestValidateReturnsStepToRefuseReuse(t *testing.T) {
+	key, _ := encoding.DecodeString(rfcSecret)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / 30
+
+	// Store accepts only steps after the last used one, the same way user_two_factor.last_used_step does
+	var lastUsed int64
+
+	use := func(code string, at time.Time) bool {
+		step, ok := Validate(rfcSecret, code, at)
+
+		if !ok || step <= lastUsed {
+			return false
+		}
+
+		lastUsed = step
+
+		return true
+	}
+
+	code := generate(key, current)
+
+	if !use(code, now) {
+		t.Fatal("Fresh code refused")
+	}
+
+	// Code stays valid during skew window, only its step tells it was used
+	if use(code, now.Add(Period)) {
+		t.Error("Code used twice")
+	}
+
+	if use(generate(key, current-1), now) {
+		t.Error("Code of earlier period accepted after later one was used")
+	}
+
+	if !use(generate(key, current+1), now.Add(Period)) {
+		t.Error("Code of next period refused")
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		valid  bool
+	}{
+		{"lower case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", true},
+		{"secret isn't base32", "not base32!", "287082", false},
+		{"8 digit code", rfcSecret, "94287082", false},
+		{"short code", rfcSecret, "28708", false},
+		{"empty code", rfcSecret, "", false},
+		{"wrong code", rfcSecret, "287083", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, ok := Validate(test.secret, test.code, now); ok != test.valid {
+				t.Errorf("Validate(%q, %q) = %t, want %t", test.secret, test.code, ok, test.valid)
+			}
+		})
+	}
+}
+
+func TestGenerateSecretAndRecoveryCodes(t *testing.T) {
+	secret, err := GenerateSecret()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := encoding.DecodeString(secret)
+
+	if err != nil || len(key) != secretBytes {
+		t.Errorf("secret %q decodes to %d bytes (%v), want %d", secret, len(key), err, secretBytes)
+	}
+
+	codes, err := GenerateRecoveryCodes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`)
+	seen := make(map[string]bool)
+
+	for _, code := range codes {
+		if !format.MatchString(code) || IsCode(code) {
+			t.Errorf("recovery code %q isn't in xxxx-xxxx-xxxx format", code)
+		}
+
+		if seen[code] {
+			t.Errorf("recovery code %q repeats", code)
+		}
+
+		seen[code] = true
+	}
+
+	if len(codes) != RecoveryCodeCount {
+		t.Errorf("got %d recovery codes, want %d", len(codes), RecoveryCodeCount)
+	}
+
+	if HashRecoveryCode(" ABCD-efgh-2345 ") != HashRecoveryCode("abcdefgh2345") {
+		t.Error("Recovery code hash depends on case, dashes or spaces")
+	}
+}
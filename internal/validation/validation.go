@@ -0,0 +1,137 @@
+package validation
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/khralenok/all-wallets-api/internal/password"
+)
+
+const (
+	MaxWalletNameLength = 64
+	MaxCategoryLength   = 64
+	MaxTagLength        = 32
+	// Amounts are stored in INT columns in minor units
+	MaxAmount = math.MaxInt32
+	// Transactions may be dated that far in the future, so users in time zones ahead of server can record today's purchases
+	MaxOccurredAtAhead = 24 * time.Hour
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3,5}$`)
+)
+
+// Register custom validation rules in gin binding engine, so they can be used in "binding" struct tags. Must be called once before the router starts.
+func Register() error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+
+	if !ok {
+		return errors.New("unexpected binding validator engine")
+	}
+
+	// Report fields by their JSON names
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	rules := map[string]validator.Func{
+		"username":    validateUsername,
+		"password":    validatePassword,
+		"currency":    validateCurrency,
+		"wallet_name": validateWalletName,
+		"category":    validateCategory,
+		"tag":         validateTag,
+		"occurred_at": validateOccurredAt,
+	}
+
+	for tag, rule := range rules {
+		if err := engine.RegisterValidation(tag, rule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return true if amount has no more fractional digits than currency allows.
+func FitsPrecision(amount float64, decimalPlaces int) bool {
+	scaled := amount * math.Pow10(decimalPlaces)
+
+	return math.Abs(scaled-math.Round(scaled)) < 1e-6
+}
+
+func validateUsername(field validator.FieldLevel) bool {
+	return usernamePattern.MatchString(field.Field().String())
+}
+
+// Only rejects passwords no policy would accept. Configurable rules are checked by password.Policy, which also needs username.
+func validatePassword(field validator.FieldLevel) bool {
+	value := strings.TrimSpace(field.Field().String())
+
+	return value != "" && len(value) <= password.MaxBytes
+}
+
+func validateCurrency(field validator.FieldLevel) bool {
+	return currencyPattern.MatchString(field.Field().String())
+}
+
+func validateWalletName(field validator.FieldLevel) bool {
+	name := strings.TrimSpace(field.Field().String())
+
+	return name != "" && utf8.RuneCountInString(name) <= MaxWalletNameLength
+}
+
+func validateCategory(field validator.FieldLevel) bool {
+	category := strings.TrimSpace(field.Field().String())
+
+	return category != "" && utf8.RuneCountInString(category) <= MaxCategoryLength
+}
+
+func validateOccurredAt(field validator.FieldLevel) bool {
+	occurredAt, ok := field.Field().Interface().(time.Time)
+
+	return ok && !occurredAt.IsZero() && occurredAt.Before(time.Now().Add(MaxOccurredAtAhead))
+}
+
+// Tags are short one-line labels without commas, so they read well in lists
+func validateTag(field validator.FieldLevel) bool {
+	tag := strings.TrimSpace(field.Field().String())
+
+	return tag != "" && utf8.RuneCountInString(tag) <= MaxTagLength && !strings.ContainsAny(tag, ",\n\r\t")
+}
+
+// Return tags trimmed, lowercased, without duplicates and sorted, the way they are stored
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	sort.Strings(normalized)
+
+	return normalized
+}
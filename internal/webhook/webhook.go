@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Headers sent with every delivery
+const (
+	SignatureHeader = "X-AllWallets-Signature"
+	EventHeader     = "X-AllWallets-Event"
+	DeliveryHeader  = "X-AllWallets-Delivery"
+)
+
+// Delivery is given up after that many attempts, which with Backoff spans about 29 hours
+const MaxAttempts = 12
+
+const (
+	firstRetryDelay = time.Minute
+	maxRetryDelay   = 12 * time.Hour
+	secretPrefix    = "whsec_"
+	// Response body is read only to reuse connection, longer one is dropped
+	maxDrainedResponse = 64 << 10
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrAddressNotPublic = errors.New("webhook receiver address isn't public")
+)
+
+// Carrier-grade NAT range, some clouds serve instance metadata from it
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Return random secret for webhook created without one
+func GenerateSecret() (string, error) {
+	raw := make([]byte, 32)
+
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Return signature header value in t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<payload>"> format. Time is signed too, so receiver can refuse replayed deliveries.
+func Sign(secret string, at time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac(secret, timestamp, payload))
+}
+
+// Return nil if header is valid signature of payload made not earlier than tolerance before now. Receivers may use it to check deliveries.
+func Verify(secret, header string, payload []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp string
+	var signatures [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if signature, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := mac(secret, timestamp, payload)
+
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// Return delay before attempt following the failed one: a minute after the first failure, doubling every next time up to 12 hours
+func Backoff(failedAttempt int) time.Duration {
+	delay := firstRetryDelay
+
+	for i := 1; i < failedAttempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetryDelay)
+}
+
+// Sender posts deliveries to webhook URLs.
+type Sender struct {
+	client *http.Client
+}
+
+// Constructor function for sender. Redirects and proxies aren't used, receiver must answer on the URL it was registered with. Receivers resolving to address that isn't public are refused, unless the address is in one of allowed networks, e.g. LAN of home server.
+func NewSender(timeout time.Duration, allowedNetworks []netip.Prefix) *Sender {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// Address is checked after DNS resolution, so host name can't point to internal address
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address, allowedNetworks)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Sender{client: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Parse networks in CIDR notation or single IPs
+func ParseNetworks(values []string) ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(values))
+
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		network, err := netip.ParsePrefix(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("network %q must be IP or CIDR", value)
+		}
+
+		networks = append(networks, network.Masked())
+	}
+
+	return networks, nil
+}
+
+// Return ErrAddressNotPublic if address is loopback, private, link-local including cloud metadata 169.254.169.254, multicast, unspecified or carrier-grade NAT one and none of allowed networks contains it
+func checkAddress(address string, allowedNetworks []netip.Prefix) error {
+	addrPort, err := netip.ParseAddrPort(address)
+
+	if err != nil {
+		return err
+	}
+
+	addr := addrPort.Addr().Unmap()
+
+	for _, network := range allowedNetworks {
+		if network.Contains(addr) {
+			return nil
+		}
+	}
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: %s", ErrAddressNotPublic, addr)
+	}
+
+	return nil
+}
+
+// Post signed delivery. Return status code of the response, which is 0 if there was none, and error unless receiver answered with 2xx.
+func (s *Sender) Send(ctx context.Context, delivery models.PendingDelivery) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "AllWallets-Webhooks/1")
+	request.Header.Set(EventHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	response, err := s.client.Do(request)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer response.Body.Close()
+
+	// Body isn't kept: delivery log is shown to wallet admins and answer could reveal what the server can reach
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxDrainedResponse))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("receiver answered %d", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+func mac(secret, timestamp string, payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(payload)
+
+	return h.Sum(nil)
+}
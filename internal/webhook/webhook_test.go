@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret := "whsec_test"
+	payload := []byte(`{"type":"transaction.created","wallet_id":1}`)
+	signedAt := time.Unix(1767225600, 0)
+	header := Sign(secret, signedAt, payload)
+
+	if !strings.HasPrefix(header, "t=1767225600,v1=") {
+		t.Fatalf("Sign = %q, want t=1767225600,v1=<signature>", header)
+	}
+
+	// Flip last hex digit of signature
+	flipped := "0"
+
+	if strings.HasSuffix(header, "0") {
+		flipped = "1"
+	}
+
+	tamperedSignature := header[:len(header)-1] + flipped
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		now     time.Time
+		valid   bool
+	}{
+		{"valid", secret, header, payload, signedAt, true},
+		{"at tolerance", secret, header, payload, signedAt.Add(5 * time.Minute), true},
+		{"too old", secret, header, payload, signedAt.Add(5*time.Minute + time.Second), false},
+		{"clock behind within tolerance", secret, header, payload, signedAt.Add(-5 * time.Minute), true},
+		{"too far in future", secret, header, payload, signedAt.Add(-5*time.Minute - time.Second), false},
+		{"other secret", "whsec_other", header, payload, signedAt, false},
+		{"tampered payload", secret, header, []byte(`{"type":"transaction.created","wallet_id":2}`), signedAt, false},
+		{"tampered time", secret, strings.Replace(header, "t=1767225600", "t=1767225601", 1), payload, signedAt, false},
+		{"tampered signature", secret, tamperedSignature, payload, signedAt, false},
+		{"one of rotated signatures", secret, header + ",v1=" + strings.Repeat("ab", 32), payload, signedAt, true},
+		{"missing time", secret, header[strings.Index(header, ",")+1:], payload, signedAt, false},
+		{"missing signature", secret, "t=1767225600", payload, signedAt, false},
+		{"empty header", secret, "", payload, signedAt, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Verify(test.secret, test.header, test.payload, 5*time.Minute, test.now)
+
+			if test.valid && err != nil {
+				t.Errorf("Verify = %v, want nil", err)
+			} else if !test.valid && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failedAttempt int
+		want          time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{10, 512 * time.Minute},
+		{11, 12 * time.Hour},
+		{MaxAttempts, 12 * time.Hour},
+		{1000, 12 * time.Hour},
+	}
+
+	var total time.Duration
+
+	for _, test := range tests {
+		if got := Backoff(test.failedAttempt); got != test.want {
+			t.Errorf("Backoff(%d) = %v, want %v", test.failedAttempt, got, test.want)
+		}
+	}
+
+	for attempt := 1; attempt < MaxAttempts; attempt++ {
+		total += Backoff(attempt)
+	}
+
+	// Documented as about 29 hours between the first and the last attempt
+	if total < 28*time.Hour || total > 30*time.Hour {
+		t.Errorf("retries span %v, want about 29h", total)
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	allowed, err := ParseNetworks([]string{"192.168.1.0/24", "fd00::1"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1::]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.2.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::2]:80", false},
+		{"100.100.100.200:80", false},
+		{"0.0.0.0:80", false},
+		{"224.0.0.1:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		// Allowed networks
+		{"192.168.1.10:8123", true},
+		{"[fd00::1]:80", true},
+	}
+
+	for _, test := range tests {
+		err := checkAddress(test.address, allowed)
+
+		if test.public && err != nil {
+			t.Errorf("checkAddress(%s) = %v, want nil", test.address, err)
+		} else if !test.public && !errors.Is(err, ErrAddressNotPublic) {
+			t.Errorf("checkAddress(%s) = %v, want %v", test.address, err, ErrAddressNotPublic)
+		}
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.0.0.7", "192.168.1.1/24"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.7/32"), netip.MustParsePrefix("192.168.1.0/24")}
+
+	if len(networks) != len(want) || networks[0] != want[0] || networks[1] != want[1] {
+		t.Errorf("ParseNetworks = %v, want %v", networks, want)
+	}
+
+	if _, err := ParseNetworks([]string{"home"}); err == nil {
+		t.Error("ParseNetworks(home) succeeded")
+	}
+}
+
+func TestSenderRefusesAddressesThatArentPublic(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	delivery := models.PendingDelivery{ID: 1, URL: receiver.URL, Secret: "whsec_test", EventType: models.EventTransactionCreated, Payload: []byte(`{}`)}
+
+	statusCode, err := NewSender(time.Second, nil).Send(context.Background(), delivery)
+
+	if statusCode != 0 || !errors.Is(err, ErrAddressNotPublic) {
+		t.Errorf("Send to loopback = %d, %v, want 0, %v", statusCode, err, ErrAddressNotPublic)
+	}
+
+	allowed, _ := ParseNetworks([]string{"127.0.0.0/8"})
+
+	if statusCode, err := NewSender(time.Second, allowed).Send(context.Background(), delivery); err != nil || statusCode != http.StatusNoContent {
+		t.Errorf("Send to allowed loopback = %d, %v, want %d, nil", statusCode, err, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Create personal API key. Return its metadata and the key itself, which can't be received again.
+func (c *Client) CreateAPIKey(ctx context.Context, input models.NewAPIKeyInput) (models.APIKey, string, error) {
+	var response struct {
+		APIKey models.APIKey `json:"api_key"`
+		Key    string        `json:"key"`
+	}
+
+	err := c.do(ctx, http.MethodPost, apiPrefix+"/me/api-keys", input, &response)
+
+	return response.APIKey, response.Key, err
+}
+
+// Return API keys of current user
+func (c *Client) APIKeys(ctx context.Context) ([]models.APIKey, error) {
+	var response struct {
+		APIKeys []models.APIKey `json:"api_keys"`
+	}
+
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/me/api-keys", nil, &response)
+
+	return response.APIKeys, err
+}
+
+// Revoke API key
+func (c *Client) RevokeAPIKey(ctx context.Context, keyID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("%s/me/api-keys/%d", apiPrefix, keyID), nil, nil)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Attach file read from r to transaction. Server detects file type from content and refuses anything but images and PDF documents.
+func (c *Client) UploadAttachment(ctx context.Context, walletID, transactionID int, fileName string, r io.Reader) (models.Attachment, error) {
+	var response struct {
+		Attachment models.Attachment `json:"attachment"`
+	}
+
+	var form bytes.Buffer
+
+	writer := multipart.NewWriter(&form)
+
+	part, err := writer.CreateFormFile("file", fileName)
+
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	if _, err := io.Copy(part, r); err != nil {
+		return models.Attachment{}, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return models.Attachment{}, err
+	}
+
+	body := rawBody{contentType: writer.FormDataContentType(), data: form.Bytes()}
+
+	err = c.do(ctx, http.MethodPost, attachmentsPath(walletID, transactionID), body, &response)
+
+	return response.Attachment, err
+}
+
+// Write attachment file to w
+func (c *Client) DownloadAttachment(ctx context.Context, walletID, transactionID, attachmentID int, w io.Writer) error {
+	return c.do(ctx, http.MethodGet, fmt.Sprintf("%s/%d", attachmentsPath(walletID, transactionID), attachmentID), nil, w)
+}
+
+// Delete attachment with its file
+func (c *Client) DeleteAttachment(ctx context.Context, walletID, transactionID, attachmentID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("%s/%d", attachmentsPath(walletID, transactionID), attachmentID), nil, nil)
+}
+
+func attachmentsPath(walletID, transactionID int) string {
+	return fmt.Sprintf("%s/transactions/%d/attachments", walletPath(walletID), transactionID)
+}
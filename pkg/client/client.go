@@ -0,0 +1,256 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix of all versioned API routes
+const apiPrefix = "/api/v1"
+
+// Client doesn't import server packages, so it doesn't pull gin and database drivers into programs using it
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	codeInvalidToken     = "invalid_token"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Returned when token has to be refreshed, but user has second factor enabled, so it can't be done without a code
+var ErrTwoFactorRequired = errors.New("second factor required to log in")
+
+type idempotencyKey struct{}
+
+// Request body sent as is instead of being encoded as JSON. It's kept in memory, so request can be retried after token refresh.
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+// Client calls AllWallets API. It's safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	token    string
+	username string
+	password string
+	// Serializes token refreshes, so concurrent requests with expired token log in once
+	refreshMu sync.Mutex
+}
+
+type Option func(*Client)
+
+// Use provided HTTP client instead of default one with 30 seconds timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Authenticate requests with JWT token or personal API key
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// Log in with provided credentials whenever token is missing, expired or revoked. Requests are retried once after that.
+func WithCredentials(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// Constructor function for client. baseURL is server address without /api/v1, e.g. https://wallets.example.com
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Return token client currently authenticates with
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.token
+}
+
+// Replace token client authenticates with
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = token
+}
+
+// Return copy of ctx making mutating request sent with it safe to retry: server answers retries with the same key with the first response.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// Send authenticated request. Token is refreshed with stored credentials and request is retried once if server rejects the token.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	token := c.Token()
+
+	if token == "" && c.hasCredentials() {
+		if err := c.refresh(ctx, ""); err != nil {
+			return err
+		}
+
+		token = c.Token()
+	}
+
+	err := c.send(ctx, method, path, token, body, out)
+
+	var apiErr *Error
+
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Code != codeInvalidToken || !c.hasCredentials() {
+		return err
+	}
+
+	if err := c.refresh(ctx, token); err != nil {
+		return err
+	}
+
+	return c.send(ctx, method, path, c.Token(), body, out)
+}
+
+// Send request without token
+func (c *Client) doPublic(ctx context.Context, method, path string, body, out any) error {
+	return c.send(ctx, method, path, "", body, out)
+}
+
+// Send request. Body is encoded as JSON unless it's rawBody. Response is decoded from JSON into out unless out is io.Writer, which gets response body as is.
+func (c *Client) send(ctx context.Context, method, path, token string, body, out any) error {
+	var payload io.Reader
+	var contentType string
+
+	switch body := body.(type) {
+	case nil:
+	case rawBody:
+		payload = bytes.NewReader(body.data)
+		contentType = body.contentType
+	default:
+		encoded, err := json.Marshal(body)
+
+		if err != nil {
+			return err
+		}
+
+		payload = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, payload)
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok && key != "" {
+		request.Header.Set(idempotencyKeyHeader, key)
+	}
+
+	response, err := c.httpClient.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return decodeError(response)
+	}
+
+	if out == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if w, ok := out.(io.Writer); ok {
+		_, err := io.Copy(w, response.Body)
+		return err
+	}
+
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// Log in again with stored credentials unless another request already did it, i.e. token differs from the rejected one
+func (c *Client) refresh(ctx context.Context, rejectedToken string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if token := c.Token(); token != "" && token != rejectedToken {
+		return nil
+	}
+
+	c.mu.Lock()
+	username, password := c.username, c.password
+	c.mu.Unlock()
+
+	// Idempotency key belongs to the request being retried, not to log in
+	result, err := c.Login(WithIdempotencyKey(ctx, ""), username, password)
+
+	if err != nil {
+		return err
+	}
+
+	if result.TwoFactorRequired {
+		return ErrTwoFactorRequired
+	}
+
+	return nil
+}
+
+func (c *Client) hasCredentials() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.username != ""
+}
+
+// Update stored credentials after user changed them, so token refresh keeps working
+func (c *Client) updateCredentials(username, password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.username == "" {
+		return
+	}
+
+	if username != "" {
+		c.username = username
+	}
+
+	if password != "" {
+		c.password = password
+	}
+}
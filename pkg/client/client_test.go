@@ -0,0 +1,245 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khralenok/all-wallets-api/internal/api/handlers"
+	"github.com/khralenok/all-wallets-api/internal/api/middleware"
+	"github.com/khralenok/all-wallets-api/internal/api/routes"
+	"github.com/khralenok/all-wallets-api/internal/config"
+	"github.com/khralenok/all-wallets-api/internal/models"
+	"github.com/khralenok/all-wallets-api/internal/password"
+	"github.com/khralenok/all-wallets-api/internal/ratelimit"
+	"github.com/khralenok/all-wallets-api/internal/store/storetest"
+	"github.com/khralenok/all-wallets-api/internal/validation"
+	"github.com/khralenok/all-wallets-api/pkg/client"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	if err := validation.Register(); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+// Start API server with real routes on top of in-memory store and return its URL
+func startServer(t *testing.T) string {
+	t.Helper()
+
+	cfg := config.Default()
+	appStore := storetest.New()
+	jwt := middleware.NewJWT("test-secret", time.Hour)
+	limiter := ratelimit.NewMemory()
+
+	// Minimal cost keeps tests fast
+	passwords, err := password.NewHasher(password.Bcrypt, cfg.Password.Argon2, bcrypt.MinCost)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passwordPolicy, err := password.NewPolicy(cfg.Password.MinLength, cfg.Password.MaxBytes, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := handlers.New(appStore, jwt, limiter, passwords, passwordPolicy, nil, nil, cfg)
+
+	router := gin.New()
+	routes.Register(router, h, jwt.AuthMiddleware(appStore.Users, appStore.APIKeys), middleware.Idempotency(appStore.Idempotency), limiter, cfg.RateLimit)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// Create user and return client logging in with their credentials
+func newUser(t *testing.T, baseURL, username string) *client.Client {
+	t.Helper()
+
+	userPassword := "correct horse battery"
+	input := models.SigninInputs{Username: username, Password: userPassword, BaseCurrency: "USD"}
+
+	if err := client.New(baseURL).CreateUser(context.Background(), input); err != nil {
+		t.Fatalf("Can't create user %s: %v", username, err)
+	}
+
+	return client.New(baseURL, client.WithCredentials(username, userPassword))
+}
+
+// Fail unless err is API error with provided status and code
+func assertAPIError(t *testing.T, err error, status int, code string) {
+	t.Helper()
+
+	var apiErr *client.Error
+
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want API error %d %s", err, status, code)
+	}
+
+	if apiErr.Status != status || apiErr.Code != code {
+		t.Errorf("API error = %d %s, want %d %s", apiErr.Status, apiErr.Code, status, code)
+	}
+
+	if client.ErrorCode(err) != code {
+		t.Errorf("ErrorCode = %q, want %q", client.ErrorCode(err), code)
+	}
+}
+
+func TestLoginAndTokenRefresh(t *testing.T) {
+	baseURL := startServer(t)
+	ctx := context.Background()
+	alice := newUser(t, baseURL, "alice")
+
+	_, err := client.New(baseURL).Login(ctx, "alice", "wrong horse battery")
+	assertAPIError(t, err, http.StatusUnauthorized, "invalid_credentials")
+
+	// Client without token logs in with credentials before the first request
+	if _, err := alice.CreateWallet(ctx, models.NewWalletRequest{WalletName: "Family", Currency: "USD"}); err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+
+	if alice.Token() == "" {
+		t.Fatal("Client didn't log in")
+	}
+
+	// Rejected token is refreshed and request is retried
+	alice.SetToken("rejected")
+
+	if _, err := alice.CreateWallet(ctx, models.NewWalletRequest{WalletName: "Trip", Currency: "EUR"}); err != nil {
+		t.Fatalf("CreateWallet with rejected token: %v", err)
+	}
+
+	if alice.Token() == "rejected" {
+		t.Error("Rejected token wasn't refreshed")
+	}
+
+	// Client without credentials can't refresh token
+	anonymous := client.New(baseURL, client.WithToken("rejected"))
+
+	_, err = anonymous.GetWallet(ctx, 1)
+	assertAPIError(t, err, http.StatusUnauthorized, "invalid_token")
+}
+
+func TestWalletsMembersAndTransactions(t *testing.T) {
+	baseURL := startServer(t)
+	ctx := context.Background()
+	alice := newUser(t, baseURL, "alice")
+	bob := newUser(t, baseURL, "bob")
+
+	_, err := alice.CreateWallet(ctx, models.NewWalletRequest{WalletName: "Family", Currency: "XXX"})
+	assertAPIError(t, err, http.StatusUnprocessableEntity, "unsupported_currency")
+
+	_, err = alice.CreateWallet(ctx, models.NewWalletRequest{WalletName: "", Currency: "USD"})
+	assertAPIError(t, err, http.StatusUnprocessableEntity, "validation_failed")
+
+	var apiErr *client.Error
+
+	if errors.As(err, &apiErr) && (len(apiErr.Errors) != 1 || apiErr.Errors[0].Field != "wallet_name") {
+		t.Errorf("field errors = %+v, want one about wallet_name", apiErr.Errors)
+	}
+
+	wallet, err := alice.CreateWallet(ctx, models.NewWalletRequest{WalletName: "Family", Currency: "USD"})
+
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+
+	_, err = bob.GetWallet(ctx, wallet.ID)
+	assertAPIError(t, err, http.StatusForbidden, "forbidden")
+
+	_, err = alice.GetWallet(ctx, wallet.ID+100)
+	assertAPIError(t, err, http.StatusNotFound, "wallet_not_found")
+
+	// Members
+	if _, err := alice.AddWalletMember(ctx, wallet.ID, "bob", "spectator"); err != nil {
+		t.Fatalf("AddWalletMember: %v", err)
+	}
+
+	_, err = alice.AddWalletMember(ctx, wallet.ID, "bob", "spectator")
+	assertAPIError(t, err, http.StatusConflict, "already_wallet_user")
+
+	_, err = alice.AddWalletMember(ctx, wallet.ID, "carol", "spectator")
+	assertAPIError(t, err, http.StatusNotFound, "user_not_found")
+
+	if _, err := bob.GetWallet(ctx, wallet.ID); err != nil {
+		t.Fatalf("GetWallet by member: %v", err)
+	}
+
+	_, err = bob.AddIncome(ctx, wallet.ID, 10, "Salary")
+	assertAPIError(t, err, http.StatusForbidden, "forbidden")
+
+	// Transactions
+	_, err = alice.AddExpense(ctx, wallet.ID, 10, "Food")
+	assertAPIError(t, err, http.StatusConflict, "insufficient_funds")
+
+	// Retry with the same idempotency key gets the first response instead of adding income again
+	keyCtx := client.WithIdempotencyKey(ctx, "salary-2026-10")
+
+	income, err := alice.AddIncome(keyCtx, wallet.ID, 100, "Salary")
+
+	if err != nil {
+		t.Fatalf("AddIncome: %v", err)
+	}
+
+	retried, err := alice.AddIncome(keyCtx, wallet.ID, 100, "Salary")
+
+	if err != nil {
+		t.Fatalf("AddIncome retry: %v", err)
+	}
+
+	if retried.ID != income.ID {
+		t.Errorf("retried income id = %d, want %d", retried.ID, income.ID)
+	}
+
+	if _, err := alice.AddExpense(ctx, wallet.ID, 30.5, "Food"); err != nil {
+		t.Fatalf("AddExpense: %v", err)
+	}
+
+	_, err = alice.AddExpense(ctx, wallet.ID, 0.005, "Food")
+	assertAPIError(t, err, http.StatusUnprocessableEntity, "amount_precision")
+
+	transactions, err := bob.Transactions(ctx, wallet.ID)
+
+	if err != nil {
+		t.Fatalf("Transactions: %v", err)
+	}
+
+	if len(transactions) != 2 || transactions[0].Amount != "100.00" || transactions[1].Amount != "30.50" {
+		t.Errorf("transactions = %+v, want income of 100.00 and expense of 30.50", transactions)
+	}
+
+	details, err := bob.GetWallet(ctx, wallet.ID)
+
+	if err != nil {
+		t.Fatalf("GetWallet: %v", err)
+	}
+
+	if details.Balance != "69.50" || details.Currency != "USD" {
+		t.Errorf("balance = %s %s, want 69.50 USD", details.Balance, details.Currency)
+	}
+
+	// Removing members
+	err = alice.RemoveWalletMember(ctx, wallet.ID, "alice")
+	assertAPIError(t, err, http.StatusConflict, "last_admin")
+
+	if err := alice.RemoveWalletMember(ctx, wallet.ID, "bob"); err != nil {
+		t.Fatalf("RemoveWalletMember: %v", err)
+	}
+
+	_, err = bob.Transactions(ctx, wallet.ID)
+	assertAPIError(t, err, http.StatusForbidden, "forbidden")
+}
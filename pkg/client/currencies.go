@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Return currencies wallets and users can use
+func (c *Client) Currencies(ctx context.Context) ([]models.CurrencyMetadata, error) {
+	var response struct {
+		Currencies []models.CurrencyMetadata `json:"currencies"`
+	}
+
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/currencies", nil, &response)
+
+	return response.Currencies, err
+}
+
+// Return latest exchange rate between currencies
+func (c *Client) ExchangeRate(ctx context.Context, from, to string) (models.ExchangeRate, error) {
+	var response struct {
+		ExchangeRate models.ExchangeRate `json:"exchange_rate"`
+	}
+
+	query := url.Values{"from": {from}, "to": {to}}
+
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/exchange-rates?"+query.Encode(), nil, &response)
+
+	return response.ExchangeRate, err
+}
@@ -0,0 +1,15 @@
+// Package client is Go SDK for AllWallets API /api/v1 routes.
+//
+// Client authenticates either with token or API key passed with WithToken, or with credentials passed with WithCredentials, in which case it logs in on first request and again whenever token expires or is revoked:
+//
+//	c := client.New("https://wallets.example.com", client.WithCredentials("alice", "secret"))
+//
+//	ctx = client.WithIdempotencyKey(ctx, uuid)
+//	transaction, err := c.AddExpense(ctx, walletID, 12.5, "Food")
+//
+//	if client.ErrorCode(err) == "insufficient_funds" {
+//		...
+//	}
+//
+// Errors returned by API are *Error values carrying stable code.
+package client
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error returned by API. It mirrors RFC 7807 problem object server responds with, Code is stable and safe to branch on.
+type Error struct {
+	Status   int          `json:"status"`
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors"`
+	// Broken password rules of weak_password error
+	Violations []string `json:"violations"`
+	// How long to wait before retrying rate limited request
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("all wallets api: %d %s", e.Status, e.Code)
+	}
+
+	return fmt.Sprintf("all wallets api: %d %s: %s", e.Status, e.Code, e.Detail)
+}
+
+// Invalid field of request body
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param"`
+}
+
+// Return code of API error, e.g. "insufficient_funds", or empty string if err isn't API error
+func ErrorCode(err error) string {
+	var apiErr *Error
+
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+
+	return ""
+}
+
+func decodeError(response *http.Response) error {
+	apiErr := &Error{}
+
+	// Responses which aren't problem objects, e.g. from proxy, still produce Error with status
+	_ = json.NewDecoder(response.Body).Decode(apiErr)
+
+	apiErr.Status = response.StatusCode
+
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(response.StatusCode)
+	}
+
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return apiErr
+}
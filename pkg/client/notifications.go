@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Return latest notifications, newest first, and number of unread ones. With unreadOnly only unread notifications are returned.
+func (c *Client) GetNotifications(ctx context.Context, unreadOnly bool) ([]models.Notification, int, error) {
+	var response struct {
+		Notifications []models.Notification `json:"notifications"`
+		UnreadCount   int                   `json:"unread_count"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/notifications?unread=%t", apiPrefix, unreadOnly), nil, &response)
+
+	return response.Notifications, response.UnreadCount, err
+}
+
+// Mark notification as read
+func (c *Client) MarkNotificationRead(ctx context.Context, notificationID int64) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("%s/notifications/%d/read", apiPrefix, notificationID), nil, nil)
+}
+
+// Mark all notifications as read. Return number of notifications that were unread.
+func (c *Client) MarkAllNotificationsRead(ctx context.Context) (int, error) {
+	var response struct {
+		Marked int `json:"marked"`
+	}
+
+	err := c.do(ctx, http.MethodPost, apiPrefix+"/notifications/read", nil, &response)
+
+	return response.Marked, err
+}
+
+// Return notification preferences of current user
+func (c *Client) GetNotificationPreferences(ctx context.Context) (models.NotificationPreferences, error) {
+	var preferences models.NotificationPreferences
+
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/me/notification-preferences", nil, &preferences)
+
+	return preferences, err
+}
+
+// Replace notification preferences of current user
+func (c *Client) UpdateNotificationPreferences(ctx context.Context, preferences models.NotificationPreferences) (models.NotificationPreferences, error) {
+	var saved models.NotificationPreferences
+
+	err := c.do(ctx, http.MethodPut, apiPrefix+"/me/notification-preferences", preferences, &saved)
+
+	return saved, err
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Return income and expense of every wallet category between from and to dates, both included. Zero from means start of current month, zero to doesn't limit period.
+func (c *Client) CategoryReport(ctx context.Context, walletID int, from, to time.Time) ([]models.CategoryTotalOutput, error) {
+	var response struct {
+		Categories []models.CategoryTotalOutput `json:"categories"`
+	}
+
+	query := url.Values{}
+
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.DateOnly))
+	}
+
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.DateOnly))
+	}
+
+	path := walletPath(walletID) + "/reports/categories"
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	err := c.do(ctx, http.MethodGet, path, nil, &response)
+
+	return response.Categories, err
+}
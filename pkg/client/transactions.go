@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Return wallet transactions with amounts formatted according to wallet currency
+func (c *Client) Transactions(ctx context.Context, walletID int) ([]models.TransactionOutput, error) {
+	var response struct {
+		Transactions []models.TransactionOutput `json:"transactions"`
+	}
+
+	err := c.do(ctx, http.MethodGet, walletPath(walletID)+"/transactions", nil, &response)
+
+	return response.Transactions, err
+}
+
+// Return wallet transactions matching filter, e.g. ones with given tag
+func (c *Client) FilterTransactions(ctx context.Context, walletID int, filter models.TransactionFilter) ([]models.TransactionOutput, error) {
+	var response struct {
+		Transactions []models.TransactionOutput `json:"transactions"`
+	}
+
+	query := url.Values{}
+
+	if filter.Query != "" {
+		query.Set("q", filter.Query)
+	}
+
+	if filter.Tag != "" {
+		query.Set("tag", filter.Tag)
+	}
+
+	if filter.Payee != "" {
+		query.Set("payee", filter.Payee)
+	}
+
+	path := walletPath(walletID) + "/transactions"
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	err := c.do(ctx, http.MethodGet, path, nil, &response)
+
+	return response.Transactions, err
+}
+
+// Add transaction with optional description, payee and tags. Amount is in wallet currency units.
+func (c *Client) CreateTransaction(ctx context.Context, walletID int, input models.TransactionInput) (models.Transaction, error) {
+	var response struct {
+		Transaction models.Transaction `json:"new_transaction"`
+	}
+
+	input.WalletID = walletID
+
+	err := c.do(ctx, http.MethodPost, walletPath(walletID)+"/transactions", input, &response)
+
+	return response.Transaction, err
+}
+
+// Replace all fields of transaction, type is required. Nil OccurredAt keeps the previous one.
+func (c *Client) UpdateTransaction(ctx context.Context, walletID, transactionID int, input models.TransactionInput) (models.Transaction, error) {
+	var response struct {
+		Transaction models.Transaction `json:"transaction"`
+	}
+
+	input.WalletID = walletID
+
+	err := c.do(ctx, http.MethodPut, walletPath(walletID)+"/transactions/"+strconv.Itoa(transactionID), input, &response)
+
+	return response.Transaction, err
+}
+
+// Add income to wallet. Amount is in wallet currency units, e.g. 12.5 dollars.
+func (c *Client) AddIncome(ctx context.Context, walletID int, amount float64, category string) (models.Transaction, error) {
+	return c.addTransaction(ctx, walletID, models.TransactionIncome, amount, category)
+}
+
+// Add expense to wallet. Amount is in wallet currency units, e.g. 12.5 dollars.
+func (c *Client) AddExpense(ctx context.Context, walletID int, amount float64, category string) (models.Transaction, error) {
+	return c.addTransaction(ctx, walletID, models.TransactionExpense, amount, category)
+}
+
+func (c *Client) addTransaction(ctx context.Context, walletID int, transactionType string, amount float64, category string) (models.Transaction, error) {
+	return c.CreateTransaction(ctx, walletID, models.TransactionInput{Type: transactionType, Amount: amount, Category: category})
+}
+
+// Search transactions in all wallets of the user, e.g. "pharmacy amount>100 before:2026-05-01". Zero limit means server default.
+func (c *Client) Search(ctx context.Context, query string, limit int) ([]models.SearchResult, error) {
+	var response struct {
+		Results []models.SearchResult `json:"results"`
+	}
+
+	params := url.Values{"q": {query}}
+
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/search?"+params.Encode(), nil, &response)
+
+	return response.Results, err
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+type TwoFactorEnrollment struct {
+	// To be shown as QR code for authenticator app
+	OTPAuthURI string `json:"otpauth_uri"`
+	Secret     string `json:"secret"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Token         string   `json:"token"`
+}
+
+// Start second factor enrollment
+func (c *Client) EnrollTwoFactor(ctx context.Context) (TwoFactorEnrollment, error) {
+	var enrollment TwoFactorEnrollment
+
+	err := c.do(ctx, http.MethodPost, apiPrefix+"/me/2fa", nil, &enrollment)
+
+	return enrollment, err
+}
+
+// Enable second factor with the first code. Return recovery codes, client switches to the new token confirmed with second factor.
+func (c *Client) ConfirmTwoFactor(ctx context.Context, code string) ([]string, error) {
+	var response recoveryCodesResponse
+
+	if err := c.do(ctx, http.MethodPost, apiPrefix+"/me/2fa/confirm", models.TwoFactorCodeInput{Code: code}, &response); err != nil {
+		return nil, err
+	}
+
+	c.SetToken(response.Token)
+
+	return response.RecoveryCodes, nil
+}
+
+// Disable second factor. Client switches to the new token, other sessions are revoked.
+func (c *Client) DisableTwoFactor(ctx context.Context, password, code string) error {
+	var response struct {
+		Token string `json:"token"`
+	}
+
+	if err := c.do(ctx, http.MethodDelete, apiPrefix+"/me/2fa", models.DisableTwoFactorInput{Password: password, Code: code}, &response); err != nil {
+		return err
+	}
+
+	c.SetToken(response.Token)
+
+	return nil
+}
+
+// Replace recovery codes with new ones
+func (c *Client) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	var response recoveryCodesResponse
+
+	err := c.do(ctx, http.MethodPost, apiPrefix+"/me/2fa/recovery-codes", models.TwoFactorCodeInput{Code: code}, &response)
+
+	return response.RecoveryCodes, err
+}
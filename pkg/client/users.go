@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Result of log in. If user has second factor enabled, client isn't authenticated yet and ChallengeToken must be exchanged with CompleteTwoFactorLogin.
+type LoginResult struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	Token             string `json:"token"`
+}
+
+type Profile struct {
+	User    models.UserOutput     `json:"user"`
+	Wallets []models.WalletOutput `json:"wallets"`
+}
+
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+// Create user
+func (c *Client) CreateUser(ctx context.Context, input models.SigninInputs) error {
+	return c.doPublic(ctx, http.MethodPost, apiPrefix+"/users", input, nil)
+}
+
+// Log in and authenticate client with received token
+func (c *Client) Login(ctx context.Context, username, password string) (LoginResult, error) {
+	var result LoginResult
+
+	input := models.LoginInputs{Username: username, Password: password}
+
+	if err := c.doPublic(ctx, http.MethodPost, apiPrefix+"/sessions", input, &result); err != nil {
+		return LoginResult{}, err
+	}
+
+	if result.Token != "" {
+		c.SetToken(result.Token)
+	}
+
+	return result, nil
+}
+
+// Finish log in of user with second factor and authenticate client with received token
+func (c *Client) CompleteTwoFactorLogin(ctx context.Context, challengeToken, code string) error {
+	var response tokenResponse
+
+	input := models.TwoFactorLoginInput{ChallengeToken: challengeToken, Code: code}
+
+	if err := c.doPublic(ctx, http.MethodPost, apiPrefix+"/sessions/2fa", input, &response); err != nil {
+		return err
+	}
+
+	c.SetToken(response.Token)
+
+	return nil
+}
+
+// Cancel user deletion and authenticate client with received token
+func (c *Client) RestoreUser(ctx context.Context, input models.RestoreUserInputs) error {
+	var response tokenResponse
+
+	if err := c.doPublic(ctx, http.MethodPost, apiPrefix+"/users/restore", input, &response); err != nil {
+		return err
+	}
+
+	c.SetToken(response.Token)
+
+	return nil
+}
+
+// Return current user with summary of wallets user participates in
+func (c *Client) Profile(ctx context.Context) (Profile, error) {
+	var profile Profile
+
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/me", nil, &profile)
+
+	return profile, err
+}
+
+// Delete current user. Return time until user can be restored.
+func (c *Client) DeleteUser(ctx context.Context, input models.DeleteUserInputs) (time.Time, error) {
+	var response struct {
+		RestorableUntil time.Time `json:"restorable_until"`
+	}
+
+	err := c.do(ctx, http.MethodDelete, apiPrefix+"/me", input, &response)
+
+	return response.RestorableUntil, err
+}
+
+// Change password. All other sessions are revoked and client switches to the new token.
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	var response tokenResponse
+
+	input := models.ChangePasswordInputs{CurrentPassword: currentPassword, NewPassword: newPassword}
+
+	if err := c.do(ctx, http.MethodPut, apiPrefix+"/me/password", input, &response); err != nil {
+		return err
+	}
+
+	c.SetToken(response.Token)
+	c.updateCredentials("", newPassword)
+
+	return nil
+}
+
+// Change username
+func (c *Client) ChangeUsername(ctx context.Context, username string) error {
+	if err := c.do(ctx, http.MethodPut, apiPrefix+"/me/username", models.ChangeUsernameInputs{Username: username}, nil); err != nil {
+		return err
+	}
+
+	c.updateCredentials(username, "")
+
+	return nil
+}
+
+// Change currency user balance is calculated in
+func (c *Client) ChangeBaseCurrency(ctx context.Context, currency string) error {
+	return c.do(ctx, http.MethodPut, apiPrefix+"/me/base-currency", models.ChangeBaseCurrencyInputs{BaseCurrency: currency}, nil)
+}
+
+type Dashboard struct {
+	User models.UserOutput `json:"user"`
+	// Monthly income and expense of wallets are counted since this time
+	MonthStart time.Time                `json:"month_start"`
+	Wallets    []models.DashboardWallet `json:"wallets"`
+}
+
+// Return all wallets of current user with balances, last activity, monthly income and expense and member count
+func (c *Client) Dashboard(ctx context.Context) (Dashboard, error) {
+	var dashboard Dashboard
+
+	err := c.do(ctx, http.MethodGet, apiPrefix+"/me/dashboard", nil, &dashboard)
+
+	return dashboard, err
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Wallet as returned by GetWallet. Balance is formatted according to wallet currency.
+type WalletDetails struct {
+	ID               int       `json:"id"`
+	WalletName       string    `json:"wallet_name"`
+	Balance          string    `json:"balance"`
+	Currency         string    `json:"currency"`
+	LastSnapshot     time.Time `json:"last_snapshot"`
+	CreatedAt        time.Time `json:"created_at"`
+	RequireTwoFactor bool      `json:"require_2fa"`
+}
+
+// Create wallet. Current user becomes its admin.
+func (c *Client) CreateWallet(ctx context.Context, input models.NewWalletRequest) (models.Wallet, error) {
+	var response struct {
+		Wallet models.Wallet `json:"new_wallet"`
+	}
+
+	err := c.do(ctx, http.MethodPost, apiPrefix+"/wallets", input, &response)
+
+	return response.Wallet, err
+}
+
+// Return wallet
+func (c *Client) GetWallet(ctx context.Context, walletID int) (WalletDetails, error) {
+	var wallet WalletDetails
+
+	err := c.do(ctx, http.MethodGet, walletPath(walletID), nil, &wallet)
+
+	return wallet, err
+}
+
+// Archive wallet. Return time until it can be restored.
+func (c *Client) ArchiveWallet(ctx context.Context, walletID int) (time.Time, error) {
+	var response struct {
+		RestorableUntil time.Time `json:"restorable_until"`
+	}
+
+	err := c.do(ctx, http.MethodDelete, walletPath(walletID), nil, &response)
+
+	return response.RestorableUntil, err
+}
+
+// Delete wallet with all its data right away
+func (c *Client) DeleteWallet(ctx context.Context, walletID int) error {
+	return c.do(ctx, http.MethodDelete, walletPath(walletID)+"?permanent=true", nil, nil)
+}
+
+// Restore archived wallet
+func (c *Client) RestoreWallet(ctx context.Context, walletID int) error {
+	return c.do(ctx, http.MethodPost, walletPath(walletID)+"/restore", nil, nil)
+}
+
+// Make second factor mandatory for wallet users or optional again
+func (c *Client) SetWalletRequireTwoFactor(ctx context.Context, walletID int, required bool) error {
+	return c.do(ctx, http.MethodPut, walletPath(walletID)+"/require-2fa", models.RequireTwoFactorInput{Required: &required}, nil)
+}
+
+// Share wallet with user
+func (c *Client) AddWalletMember(ctx context.Context, walletID int, username, role string) (models.WalletUser, error) {
+	var response struct {
+		WalletUser models.WalletUser `json:"new_wallet_user"`
+	}
+
+	input := models.NewWalletUserRequest{WalletID: walletID, Username: username, UserRole: role}
+
+	err := c.do(ctx, http.MethodPost, walletPath(walletID)+"/members", input, &response)
+
+	return response.WalletUser, err
+}
+
+// Remove user from wallet
+func (c *Client) RemoveWalletMember(ctx context.Context, walletID int, username string) error {
+	return c.do(ctx, http.MethodDelete, walletPath(walletID)+"/members/"+url.PathEscape(username), nil, nil)
+}
+
+func walletPath(walletID int) string {
+	return fmt.Sprintf("%s/wallets/%d", apiPrefix, walletID)
+}
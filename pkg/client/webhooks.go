@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/khralenok/all-wallets-api/internal/models"
+)
+
+// Subscribe URL to wallet events. Return webhook and secret payloads are signed with, which is shown only once.
+func (c *Client) CreateWebhook(ctx context.Context, walletID int, input models.NewWebhookInput) (models.Webhook, string, error) {
+	var response struct {
+		Webhook models.Webhook `json:"webhook"`
+		Secret  string         `json:"secret"`
+	}
+
+	err := c.do(ctx, http.MethodPost, walletPath(walletID)+"/webhooks", input, &response)
+
+	return response.Webhook, response.Secret, err
+}
+
+// Return all webhooks of the wallet
+func (c *Client) GetWebhooks(ctx context.Context, walletID int) ([]models.Webhook, error) {
+	var response struct {
+		Webhooks []models.Webhook `json:"webhooks"`
+	}
+
+	err := c.do(ctx, http.MethodGet, walletPath(walletID)+"/webhooks", nil, &response)
+
+	return response.Webhooks, err
+}
+
+// Delete webhook
+func (c *Client) DeleteWebhook(ctx context.Context, walletID, webhookID int) error {
+	return c.do(ctx, http.MethodDelete, webhookPath(walletID, webhookID), nil, nil)
+}
+
+// Return latest deliveries of webhook, newest first
+func (c *Client) GetWebhookDeliveries(ctx context.Context, walletID, webhookID int) ([]models.WebhookDelivery, error) {
+	var response struct {
+		Deliveries []models.WebhookDelivery `json:"deliveries"`
+	}
+
+	err := c.do(ctx, http.MethodGet, webhookPath(walletID, webhookID)+"/deliveries", nil, &response)
+
+	return response.Deliveries, err
+}
+
+func webhookPath(walletID, webhookID int) string {
+	return fmt.Sprintf("%s/webhooks/%d", walletPath(walletID), webhookID)
+}